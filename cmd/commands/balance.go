@@ -62,8 +62,11 @@ type balanceRunner struct {
 	cpuprofile string
 
 	// journal structure
-	close     bool
-	valuation flags.CommodityFlag
+	close       bool
+	valuation   flags.CommodityFlag
+	prefer      flags.PreferFlag
+	splitFX     bool
+	maxPriceAge int
 
 	// mapping
 	mapping flags.MappingFlag
@@ -110,6 +113,9 @@ func (r *balanceRunner) setupFlags(c *cobra.Command) {
 	c.Flags().BoolVarP(&r.sortAlphabetically, "sort", "a", false, "Sort accounts alphabetically")
 	c.Flags().VarP(&r.showCommodities, "show-commodities", "s", "<regex>")
 	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.prefer.Setup(c)
+	c.Flags().BoolVar(&r.splitFX, "split-fx", false, "split valuation gains into price and FX effects")
+	c.Flags().IntVar(&r.maxPriceAge, "max-price-age", 0, "fail if a price used for valuation is older than this many days (0: no limit)")
 	c.Flags().VarP(&r.mapping, "map", "m", "<level>,<regex>")
 	c.Flags().VarP(&r.remap, "remap", "r", "<regex>")
 	c.Flags().Var(&r.accounts, "account", "filter accounts with a regex")
@@ -125,6 +131,10 @@ func (r balanceRunner) execute(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	preferred, err := r.prefer.Value(reg)
+	if err != nil {
+		return err
+	}
 	j, err := journal.FromPath(cmd.Context(), reg, args[0])
 	if err != nil {
 		return err
@@ -133,8 +143,9 @@ func (r balanceRunner) execute(cmd *cobra.Command, args []string) error {
 	report := balance.NewReport(reg, partition)
 	procs := []*journal.Processor{
 		check.Check(),
-		journal.ComputePrices(valuation),
-		journal.Valuate(reg, valuation),
+		journal.PriceComputer{Valuation: valuation, Preferred: preferred}.Compute(),
+		journal.CorporateActions(reg),
+		r.valuate(reg, valuation),
 		journal.Filter(partition),
 		journal.CloseAccounts(j, reg, r.close, partition),
 		journal.Query{
@@ -182,3 +193,12 @@ func (r balanceRunner) execute(cmd *cobra.Command, args []string) error {
 type Renderer interface {
 	Render(*table.Table, io.Writer) error
 }
+
+func (r balanceRunner) valuate(reg *model.Registry, valuation *model.Commodity) *journal.Processor {
+	return journal.Valuator{
+		Registry:    reg,
+		Valuation:   valuation,
+		SplitFX:     r.splitFX,
+		MaxPriceAge: r.maxPriceAge,
+	}.Valuate()
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/sboehler/knut/cmd/flags"
 	"github.com/sboehler/knut/lib/journal"
 	"github.com/sboehler/knut/lib/journal/check"
 	"github.com/sboehler/knut/lib/model"
@@ -36,17 +37,29 @@ func CreateCheckCommand() *cobra.Command {
 	c := &cobra.Command{
 		Use:   "check",
 		Short: "check the journal",
-		Long:  `Check the journal.`,
-		Args:  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
-		Run:   r.run,
+		Long: `Check the journal.
+
+With --prices, list the commodities held in asset and liability accounts whose most recent
+price is older than --max-age days at the end of each reporting period. Prices derived from
+other prices are as old as the oldest of them, e.g. a stock quoted in USD is stale if the
+USD price is stale. A valuation commodity is required in this mode.`,
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Run:  r.run,
 	}
 	r.setupFlags(c)
 	return c
 }
 
 type checkRunner struct {
+	flags.Multiperiod
+
 	write   bool
 	noCheck bool
+
+	prices    bool
+	maxAge    int
+	valuation flags.CommodityFlag
+	prefer    flags.PreferFlag
 }
 
 func (r *checkRunner) run(cmd *cobra.Command, args []string) {
@@ -60,6 +73,11 @@ func (r *checkRunner) run(cmd *cobra.Command, args []string) {
 func (r *checkRunner) setupFlags(c *cobra.Command) {
 	c.Flags().BoolVar(&r.write, "write", false, "create a complete set of assertions")
 	c.Flags().BoolVar(&r.noCheck, "no-check", false, "do not check assertions")
+	c.Flags().BoolVar(&r.prices, "prices", false, "check for stale prices")
+	c.Flags().IntVar(&r.maxAge, "max-age", 7, "maximum age of prices in days")
+	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.prefer.Setup(c)
+	r.Multiperiod.Setup(c)
 }
 
 func (r *checkRunner) execute(cmd *cobra.Command, args []string) error {
@@ -73,6 +91,9 @@ func (r *checkRunner) execute(cmd *cobra.Command, args []string) error {
 		Write:   r.write,
 		NoCheck: r.noCheck,
 	}
+	if r.prices {
+		return r.checkPrices(cmd, reg, j, &checker)
+	}
 
 	err = j.Build().Process(
 		checker.Check(),
@@ -88,6 +109,50 @@ func (r *checkRunner) execute(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func (r *checkRunner) checkPrices(cmd *cobra.Command, reg *model.Registry, j *journal.Builder, checker *check.Checker) error {
+	valuation, err := r.valuation.Value(reg)
+	if err != nil {
+		return err
+	}
+	preferred, err := r.prefer.Value(reg)
+	if err != nil {
+		return err
+	}
+	if valuation == nil {
+		return fmt.Errorf("checking prices requires a valuation commodity")
+	}
+	partition := r.Multiperiod.Partition(j.Period())
+	priceChecker := check.PriceChecker{
+		Valuation: valuation,
+		MaxAge:    r.maxAge,
+		Dates:     partition.EndDates(),
+	}
+	j.Days(partition.EndDates())
+	err = j.Build().Process(
+		checker.Check(),
+		journal.PriceComputer{Valuation: valuation, Preferred: preferred}.Compute(),
+		journal.CorporateActions(reg),
+		priceChecker.Check(),
+	)
+	if err != nil {
+		return err
+	}
+	stale := priceChecker.StalePrices()
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	for _, sp := range stale {
+		if sp.LastPrice.IsZero() {
+			fmt.Fprintf(out, "%s %s: no price\n", sp.Date.Format("2006-01-02"), sp.Commodity.Name())
+			continue
+		}
+		fmt.Fprintf(out, "%s %s: last price on %s (%d days old)\n", sp.Date.Format("2006-01-02"), sp.Commodity.Name(), sp.LastPrice.Format("2006-01-02"), sp.Age)
+	}
+	if len(stale) > 0 {
+		return fmt.Errorf("found %d stale prices", len(stale))
+	}
+	return nil
+}
+
 func (r *checkRunner) writeFile(assertions []*model.Assertion) error {
 	out := bufio.NewWriter(os.Stdout)
 	defer out.Flush()
@@ -16,15 +16,25 @@ package commands
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/compare"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/dict"
 	"github.com/sboehler/knut/lib/journal"
 	"github.com/sboehler/knut/lib/model"
 	"github.com/sboehler/knut/lib/model/price"
 	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/quotes"
+	"github.com/sboehler/knut/lib/quotes/ecb"
+	"github.com/sboehler/knut/lib/quotes/generic"
 	"github.com/sboehler/knut/lib/quotes/yahoo2"
 	"github.com/sboehler/knut/lib/syntax"
 	"github.com/shopspring/decimal"
@@ -39,19 +49,52 @@ import (
 
 // CreateFetchCommand creates the command.
 func CreateFetchCommand() *cobra.Command {
-	var runner fetchRunner
-	return &cobra.Command{
+	runner := fetchRunner{backoff: retryBackoff}
+	c := &cobra.Command{
 		Use:   "fetch",
-		Short: "Fetch quotes from Yahoo! Finance",
-		Long:  `Fetch quotes from Yahoo! Finance based on the supplied configuration in yaml format. See doc/prices.yaml for an example.`,
+		Short: "Fetch quotes from Yahoo! Finance or other providers",
+		Long: `Fetch quotes based on the supplied configuration in yaml format. See doc/prices.yaml for an example.
+
+Each entry selects a provider with the provider key. The default provider is yahoo. The
+provider http reads quotes from a JSON or CSV endpoint described by the http key. The
+provider ecb reads the ECB euro foreign exchange reference rates from the URL or file given
+in ecb.source (default: the full history), where the quote for a currency symbol is the
+price of one EUR in that currency. Entries can list fallback providers, which are tried in
+order if a provider fails.
+
+By default, the last year is fetched. Entries can define the range with the start and end
+keys (YYYY-MM-DD), which are overridden by --from and --to. With --incremental, entries
+are fetched from the last date in their prices file.`,
 
 		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 
 		Run: runner.run,
 	}
+	runner.setupFlags(c)
+	return c
 }
 
-type fetchRunner struct{}
+type fetchRunner struct {
+	from, to    flags.DateFlag
+	incremental bool
+	retries     int
+
+	// backoff is the delay before the first retry. It doubles with
+	// every retry.
+	backoff time.Duration
+
+	// ecb caches the ECB clients by source, so that every source
+	// is read only once.
+	mutex sync.Mutex
+	ecb   map[string]*ecb.Client
+}
+
+func (r *fetchRunner) setupFlags(c *cobra.Command) {
+	c.Flags().Var(&r.from, "from", "fetch from this date, overriding the configuration")
+	c.Flags().Var(&r.to, "to", "fetch until this date, overriding the configuration")
+	c.Flags().BoolVar(&r.incremental, "incremental", false, "fetch from the last date in the prices file")
+	c.Flags().IntVar(&r.retries, "retries", 3, "number of retries for requests which fail with a network error, status 429 or 5xx")
+}
 
 func (r *fetchRunner) run(cmd *cobra.Command, args []string) {
 	if err := r.execute(cmd, args); err != nil {
@@ -62,7 +105,7 @@ func (r *fetchRunner) run(cmd *cobra.Command, args []string) {
 
 const fetchConcurrency = 5
 
-func (r *fetchRunner) execute(_ *cobra.Command, args []string) error {
+func (r *fetchRunner) execute(cmd *cobra.Command, args []string) error {
 	reg := registry.New()
 	configs, err := r.readConfig(args[0])
 	if err != nil {
@@ -70,30 +113,89 @@ func (r *fetchRunner) execute(_ *cobra.Command, args []string) error {
 	}
 	p := pool.New().WithMaxGoroutines(fetchConcurrency).WithErrors()
 	bar := pb.StartNew(len(configs))
+	results := make([]fetchResult, len(configs))
 
-	for _, cfg := range configs {
-		cfg := cfg
+	for i, cfg := range configs {
+		i, cfg := i, cfg
 		p.Go(func() error {
 			defer bar.Increment()
-			return r.fetch(reg, args[0], cfg)
+			res, err := r.fetch(cmd.Context(), reg, args[0], cfg)
+			results[i] = res
+			return err
 		})
 	}
-	return multierr.Combine(p.Wait())
+	err = multierr.Combine(p.Wait())
+	bar.Finish()
+	for _, res := range results {
+		if res.symbol == "" {
+			continue
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: %d added, %d changed (%s - %s)\n",
+			res.symbol, res.added, res.changed, res.period.Start.Format("2006-01-02"), res.period.End.Format("2006-01-02"))
+	}
+	return err
+}
+
+// fetchResult summarizes the changes to the prices of a symbol.
+type fetchResult struct {
+	symbol         string
+	period         date.Period
+	added, changed int
 }
 
-func (r *fetchRunner) fetch(reg *registry.Registry, f string, cfg fetchConfig) error {
+func (r *fetchRunner) fetch(ctx context.Context, reg *registry.Registry, f string, cfg fetchConfig) (fetchResult, error) {
 	absPath := filepath.Join(filepath.Dir(f), cfg.File)
 	pricesByDate, err := r.readFile(reg, absPath)
 	if err != nil {
-		return err
+		return fetchResult{}, err
 	}
-	if err := r.fetchPrices(reg, cfg, time.Now().AddDate(-1, 0, 0), time.Now(), pricesByDate); err != nil {
-		return err
+	period, err := r.period(cfg, pricesByDate, time.Now())
+	if err != nil {
+		return fetchResult{}, err
+	}
+	prices, err := r.fetchPrices(ctx, reg, filepath.Dir(f), cfg, period.Start, period.End)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	res := fetchResult{symbol: cfg.Symbol, period: period}
+	for _, p := range prices {
+		if existing, ok := pricesByDate[p.Date]; !ok {
+			res.added++
+		} else if !existing.Price.Equal(p.Price) || existing.Target != p.Target || existing.Commodity != p.Commodity {
+			res.changed++
+		}
+		pricesByDate[p.Date] = p
 	}
 	if err := r.writeFile(pricesByDate, absPath); err != nil {
-		return err
+		return fetchResult{}, err
 	}
-	return nil
+	return res, nil
+}
+
+// period determines the period to be fetched. Flags take precedence over
+// the incremental mode, which takes precedence over the configuration.
+func (r *fetchRunner) period(cfg fetchConfig, prices map[time.Time]*model.Price, now time.Time) (date.Period, error) {
+	period := date.Period{Start: now.AddDate(-1, 0, 0), End: now}
+	if cfg.Start != "" {
+		t, err := time.Parse("2006-01-02", cfg.Start)
+		if err != nil {
+			return period, fmt.Errorf("invalid start date for symbol %s: %w", cfg.Symbol, err)
+		}
+		period.Start = t
+	}
+	if cfg.End != "" {
+		t, err := time.Parse("2006-01-02", cfg.End)
+		if err != nil {
+			return period, fmt.Errorf("invalid end date for symbol %s: %w", cfg.Symbol, err)
+		}
+		period.End = t
+	}
+	if r.incremental && len(prices) > 0 {
+		period.Start = dict.SortedKeys(prices, compare.Time)[len(prices)-1]
+	}
+	period.Start = r.from.ValueOr(period.Start)
+	period.End = r.to.ValueOr(period.End)
+	return period, nil
 }
 
 func (r *fetchRunner) readConfig(path string) ([]fetchConfig, error) {
@@ -131,31 +233,65 @@ func (r *fetchRunner) readFile(ctx *registry.Registry, filepath string) (res map
 	return prices, nil
 }
 
-func (r *fetchRunner) fetchPrices(reg *registry.Registry, cfg fetchConfig, t0, t1 time.Time, results map[time.Time]*model.Price) error {
+func (r *fetchRunner) fetchPrices(ctx context.Context, reg *registry.Registry, dir string, cfg fetchConfig, t0, t1 time.Time) ([]*model.Price, error) {
 	var (
-		c                 = yahoo2.New()
-		quotes            []yahoo2.Quote
+		qs                []quotes.Quote
 		commodity, target *model.Commodity
 		err               error
 	)
-	if quotes, err = c.Fetch(cfg.Symbol, t0, t1); err != nil {
-		return fmt.Errorf("error fetching symbol %s: %v", cfg.Symbol, err)
+	for _, src := range append([]providerConfig{cfg.providerConfig}, cfg.Fallback...) {
+		if src.Symbol == "" {
+			src.Symbol = cfg.Symbol
+		}
+		var p quotes.Provider
+		if p, err = r.provider(dir, src); err != nil {
+			return nil, err
+		}
+		if qs, err = r.fetchWithRetry(ctx, p, src.Symbol, t0, t1); err != nil {
+			err = fmt.Errorf("error fetching symbol %s: %v", src.Symbol, err)
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return nil, err
 	}
 	if commodity, err = reg.Commodities().Get(cfg.Commodity); err != nil {
-		return err
+		return nil, err
 	}
 	if target, err = reg.Commodities().Get(cfg.TargetCommodity); err != nil {
-		return err
+		return nil, err
 	}
-	for _, quote := range quotes {
-		results[quote.Date] = &model.Price{
+	var res []*model.Price
+	for _, quote := range qs {
+		res = append(res, &model.Price{
 			Date:      quote.Date,
 			Commodity: commodity,
 			Target:    target,
 			Price:     decimal.NewFromFloat(quote.Close),
+		})
+	}
+	return res, nil
+}
+
+// retryBackoff is the default delay before the first retry.
+const retryBackoff = time.Second
+
+// fetchWithRetry fetches the quotes, retrying transient errors.
+func (r *fetchRunner) fetchWithRetry(ctx context.Context, p quotes.Provider, sym string, t0, t1 time.Time) ([]quotes.Quote, error) {
+	backoff := r.backoff
+	for i := 0; ; i++ {
+		qs, err := p.Fetch(ctx, sym, t0, t1)
+		if err == nil || i >= r.retries || !quotes.IsTransient(err) {
+			return qs, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
 		}
+		backoff *= 2
 	}
-	return nil
 }
 
 func (r *fetchRunner) writeFile(prices map[time.Time]*model.Price, filepath string) error {
@@ -172,8 +308,53 @@ func (r *fetchRunner) writeFile(prices map[time.Time]*model.Price, filepath stri
 }
 
 type fetchConfig struct {
-	Symbol          string `yaml:"symbol"`
-	File            string `yaml:"file"`
-	Commodity       string `yaml:"commodity"`
-	TargetCommodity string `yaml:"target_commodity"`
+	providerConfig  `yaml:",inline"`
+	File            string           `yaml:"file"`
+	Commodity       string           `yaml:"commodity"`
+	TargetCommodity string           `yaml:"target_commodity"`
+	Start           string           `yaml:"start"`
+	End             string           `yaml:"end"`
+	Fallback        []providerConfig `yaml:"fallback"`
+}
+
+// providerConfig configures the provider for a symbol.
+type providerConfig struct {
+	Symbol   string          `yaml:"symbol"`
+	Provider string          `yaml:"provider"`
+	HTTP     *generic.Config `yaml:"http"`
+	ECB      *ecbConfig      `yaml:"ecb"`
+}
+
+// ecbConfig configures the source of ECB reference rates. The source is
+// a URL or a path relative to the configuration file.
+type ecbConfig struct {
+	Source string `yaml:"source"`
+}
+
+func (r *fetchRunner) provider(dir string, cfg providerConfig) (quotes.Provider, error) {
+	switch cfg.Provider {
+	case "", "yahoo":
+		c := yahoo2.New()
+		return &c, nil
+	case "http":
+		if cfg.HTTP == nil {
+			return nil, fmt.Errorf("provider http for symbol %s requires an http configuration", cfg.Symbol)
+		}
+		return generic.New(*cfg.HTTP)
+	case "ecb":
+		var src string
+		if cfg.ECB != nil {
+			src = cfg.ECB.Source
+		}
+		if src != "" && !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+			src = filepath.Join(dir, src)
+		}
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		if r.ecb == nil {
+			r.ecb = make(map[string]*ecb.Client)
+		}
+		return dict.GetDefault(r.ecb, src, func() *ecb.Client { return ecb.New(src) }), nil
+	}
+	return nil, fmt.Errorf("unknown provider %q for symbol %s", cfg.Provider, cfg.Symbol)
 }
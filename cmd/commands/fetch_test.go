@@ -0,0 +1,236 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/quotes"
+)
+
+func TestFetchPeriod(t *testing.T) {
+	now := date.Date(2023, 6, 15)
+	prices := map[time.Time]*model.Price{
+		date.Date(2023, 1, 31): nil,
+		date.Date(2023, 3, 31): nil,
+		date.Date(2023, 2, 28): nil,
+	}
+	tests := []struct {
+		desc        string
+		cfg         fetchConfig
+		prices      map[time.Time]*model.Price
+		from, to    string
+		incremental bool
+		want        date.Period
+		wantErr     bool
+	}{
+		{
+			desc: "default",
+			want: date.Period{Start: date.Date(2022, 6, 15), End: now},
+		},
+		{
+			desc: "config",
+			cfg:  fetchConfig{Start: "2010-01-01", End: "2020-12-31"},
+			want: date.Period{Start: date.Date(2010, 1, 1), End: date.Date(2020, 12, 31)},
+		},
+		{
+			desc: "flags override config",
+			cfg:  fetchConfig{Start: "2010-01-01", End: "2020-12-31"},
+			from: "2015-01-01",
+			to:   "2016-01-01",
+			want: date.Period{Start: date.Date(2015, 1, 1), End: date.Date(2016, 1, 1)},
+		},
+		{
+			desc:        "incremental",
+			cfg:         fetchConfig{Start: "2010-01-01"},
+			prices:      prices,
+			incremental: true,
+			want:        date.Period{Start: date.Date(2023, 3, 31), End: now},
+		},
+		{
+			desc:        "incremental without prices",
+			cfg:         fetchConfig{Start: "2010-01-01"},
+			incremental: true,
+			want:        date.Period{Start: date.Date(2010, 1, 1), End: now},
+		},
+		{
+			desc:        "flags override incremental",
+			prices:      prices,
+			incremental: true,
+			from:        "2020-01-01",
+			want:        date.Period{Start: date.Date(2020, 1, 1), End: now},
+		},
+		{
+			desc:    "invalid start",
+			cfg:     fetchConfig{Start: "2010-13-01"},
+			wantErr: true,
+		},
+		{
+			desc:    "invalid end",
+			cfg:     fetchConfig{End: "yesterday"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			r := fetchRunner{incremental: test.incremental}
+			if test.from != "" {
+				if err := r.from.Set(test.from); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.to != "" {
+				if err := r.to.Set(test.to); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := r.period(test.cfg, test.prices, now)
+
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("period() returned no error, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("period() returned unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Fatalf("period() returned unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// fakeProvider returns the given errors in order, and quotes afterwards.
+type fakeProvider struct {
+	errs  []error
+	calls int
+}
+
+func (p *fakeProvider) Fetch(_ context.Context, sym string, t0, t1 time.Time) ([]quotes.Quote, error) {
+	p.calls++
+	if p.calls <= len(p.errs) {
+		return nil, p.errs[p.calls-1]
+	}
+	return []quotes.Quote{{Date: t0, Close: 1}}, nil
+}
+
+func TestFetchWithRetry(t *testing.T) {
+	var (
+		netErr      = &url.Error{Op: "Get", URL: "http://localhost", Err: fmt.Errorf("connection refused")}
+		unavailable = fmt.Errorf("error fetching data: %w", &quotes.StatusError{StatusCode: http.StatusServiceUnavailable})
+		tooMany     = &quotes.StatusError{StatusCode: http.StatusTooManyRequests}
+		notFound    = fmt.Errorf("error fetching data: %w", &quotes.StatusError{StatusCode: http.StatusNotFound})
+		unknown     = fmt.Errorf("no rates found for currency XYZ")
+		canceled    = &url.Error{Op: "Get", URL: "http://localhost", Err: context.Canceled}
+	)
+	tests := []struct {
+		desc      string
+		errs      []error
+		retries   int
+		backoff   time.Duration
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			desc:      "success",
+			retries:   3,
+			wantCalls: 1,
+		},
+		{
+			desc:      "transient errors",
+			errs:      []error{netErr, unavailable, tooMany},
+			retries:   3,
+			wantCalls: 4,
+		},
+		{
+			desc:      "retries exhausted",
+			errs:      []error{netErr, netErr, netErr},
+			retries:   2,
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			desc:      "no retries",
+			errs:      []error{netErr},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			desc:      "client error",
+			errs:      []error{notFound},
+			retries:   3,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			desc:      "permanent error",
+			errs:      []error{unknown},
+			retries:   3,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			desc:      "canceled",
+			errs:      []error{canceled},
+			retries:   3,
+			backoff:   time.Hour,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			p := &fakeProvider{errs: test.errs}
+			r := fetchRunner{retries: test.retries, backoff: test.backoff}
+
+			qs, err := r.fetchWithRetry(context.Background(), p, "AAPL", date.Date(2023, 1, 1), date.Date(2023, 1, 2))
+
+			if test.wantErr && err == nil {
+				t.Errorf("fetchWithRetry() returned no error, want an error")
+			}
+			if !test.wantErr && (err != nil || len(qs) != 1) {
+				t.Errorf("fetchWithRetry() = %v, %v, want one quote", qs, err)
+			}
+			if p.calls != test.wantCalls {
+				t.Errorf("fetchWithRetry() called Fetch %d times, want %d", p.calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestFetchWithRetryCanceledDuringBackoff(t *testing.T) {
+	p := &fakeProvider{errs: []error{&quotes.StatusError{StatusCode: http.StatusBadGateway}}}
+	r := fetchRunner{retries: 3, backoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := r.fetchWithRetry(ctx, p, "AAPL", date.Date(2023, 1, 1), date.Date(2023, 1, 2)); err == nil {
+		t.Fatalf("fetchWithRetry() returned no error, want an error")
+	}
+	if p.calls != 1 {
+		t.Errorf("fetchWithRetry() called Fetch %d times, want 1", p.calls)
+	}
+}
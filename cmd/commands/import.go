@@ -15,18 +15,74 @@
 package commands
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/cmd/importer/external"
 	"github.com/spf13/cobra"
 )
 
 // CreateImportCommand is the import command.
 func CreateImportCommand() *cobra.Command {
+	r := importRunner{builtin: make(map[string]bool)}
 	cmd := cobra.Command{
 		Use:   "import",
 		Short: "Import financial account statements",
+
+		// The arguments of external importers are parsed by the importer.
+		DisableFlagParsing: true,
+		ValidArgsFunction:  r.complete,
+
+		Run: r.run,
 	}
+	importer.SetupFlags(&cmd)
 	for _, constructor := range importer.GetImporters() {
-		cmd.AddCommand(constructor())
+		c := constructor()
+		r.builtin[c.Name()] = true
+		cmd.AddCommand(c)
 	}
 	return &cmd
 }
+
+type importRunner struct {
+	builtin map[string]bool
+}
+
+func (r *importRunner) run(cmd *cobra.Command, args []string) {
+	if err := r.execute(cmd, args); err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		os.Exit(1)
+	}
+}
+
+// execute runs if no built-in importer matches the arguments. External
+// importers are only discovered on PATH at this point, to avoid scanning
+// PATH for other commands.
+func (r *importRunner) execute(cmd *cobra.Command, args []string) error {
+	cmd.AddCommand(external.CreateCmds(r.builtin)...)
+	c, rest, err := cmd.Find(args)
+	if err != nil {
+		return err
+	}
+	if c == cmd {
+		if len(args) == 0 || args[0] == "--help" || args[0] == "-h" {
+			return cmd.Help()
+		}
+		return fmt.Errorf("no importer found in %q, see knut import --help", args)
+	}
+	c.SetContext(cmd.Context())
+	c.Run(c, rest)
+	return nil
+}
+
+func (r *importRunner) complete(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	var res []string
+	for _, c := range external.CreateCmds(r.builtin) {
+		res = append(res, c.Name()+"\t"+c.Short)
+	}
+	return res, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/cmdtest"
+)
+
+func TestImportExternal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	script := `#!/bin/sh
+[ "$*" = "--account Assets:Bank" ] || exit 1
+echo '{"version": 1, "transactions": [{"date": "2023-05-01", "description": "Salary", "postings": [{"credit": "Income:Salary", "debit": "Assets:Bank", "amount": "5000", "commodity": "CHF"}]}]}'
+`
+	if err := os.WriteFile(filepath.Join(dir, "knut-import-bank"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	cmd := CreateImportCommand()
+	for _, c := range cmd.Commands() {
+		if c.Name() == "bank" {
+			t.Fatalf("external importer was discovered before the import command ran")
+		}
+	}
+	root := &cobra.Command{Use: "knut"}
+	root.AddCommand(cmd)
+
+	got := cmdtest.Run(t, root, "import", "--assert", "none", "bank", "--account", "Assets:Bank")
+
+	want := "2023-05-01 \"Salary\"\nIncome:Salary Assets:Bank         5000 CHF\n\n"
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Fatalf("unexpected diff (-want, +got):\n%s", diff)
+	}
+}
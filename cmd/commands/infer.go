@@ -19,13 +19,20 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/natefinch/atomic"
 	"github.com/sourcegraph/conc/pool"
 	"github.com/spf13/cobra"
 
+	"github.com/sboehler/knut/cmd/flags"
 	"github.com/sboehler/knut/lib/common/cpr"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal/rules"
 	"github.com/sboehler/knut/lib/syntax"
 	"github.com/sboehler/knut/lib/syntax/bayes"
 )
@@ -37,8 +44,15 @@ func CreateInferCmd() *cobra.Command {
 		Use:   "infer",
 		Short: "Auto-assign accounts in a journal",
 		Long: `Build a Bayes model using the supplied training file and apply it to replace
-		the indicated account in the target file. Training file and target file may be the same.`,
-		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		the indicated account in the target file. Training file and target file may be the same.
+		If a rules file is given, the rules are applied first, and the Bayes model is only
+		used for the remaining bookings. The trained model can be saved and reused
+		instead of a training file.
+
+		With --evaluate, a part of the transactions in the training file is held out,
+		the model is trained on the rest and the accuracy of the predicted accounts
+		for the held out transactions is reported.`,
+		Args: cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
 		Run:  r.run,
 	}
 	r.setupFlags(cmd)
@@ -46,16 +60,39 @@ func CreateInferCmd() *cobra.Command {
 }
 
 type inferRunner struct {
-	account      string
-	trainingFile string
-	inplace      bool
+	account       string
+	trainingFile  string
+	modelFile     string
+	saveModelFile string
+	rulesFile     string
+	inplace       bool
+	interactive   bool
+	explain       int
+	minConfidence float64
+
+	evaluate               bool
+	holdout                float64
+	holdoutFrom, holdoutTo flags.DateFlag
+	worst                  int
 }
 
 func (r *inferRunner) setupFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&r.account, "account", "a", "Expenses:TBD", "account name")
 	cmd.Flags().BoolVarP(&r.inplace, "inplace", "i", false, "infer the accounts inplace")
+	cmd.Flags().BoolVar(&r.interactive, "interactive", false, "choose the accounts interactively and write the target file inplace")
 	cmd.Flags().StringVarP(&r.trainingFile, "training-file", "t", "", "the journal file with existing data")
-	cmd.MarkFlagRequired("training-file")
+	cmd.Flags().StringVarP(&r.modelFile, "model", "m", "", "a model file saved with --save-model, instead of a training file")
+	cmd.Flags().StringVar(&r.saveModelFile, "save-model", "", "save the model trained from the training file")
+	cmd.Flags().StringVarP(&r.rulesFile, "rules", "r", "", "a YAML file with rules which take precedence over the Bayes model")
+	cmd.Flags().IntVar(&r.explain, "explain", 0, "print the given number of best candidate accounts for every booking to stderr")
+	cmd.Flags().Float64Var(&r.minConfidence, "min-confidence", 0, "minimum probability (0-1) of the inferred account, otherwise the account is kept")
+	cmd.Flags().BoolVar(&r.evaluate, "evaluate", false, "evaluate the model on held out transactions of the training file")
+	cmd.Flags().Float64Var(&r.holdout, "holdout", 0.2, "the fraction of transactions to hold out for --evaluate")
+	cmd.Flags().Var(&r.holdoutFrom, "holdout-from", "hold out the transactions from this date for --evaluate, instead of a fraction")
+	cmd.Flags().Var(&r.holdoutTo, "holdout-to", "hold out the transactions until this date for --evaluate, instead of a fraction")
+	cmd.Flags().IntVar(&r.worst, "worst", 10, "the number of worst misclassifications to print for --evaluate")
+	cmd.MarkFlagsMutuallyExclusive("training-file", "model")
+	cmd.MarkFlagsMutuallyExclusive("model", "save-model")
 }
 
 func (r *inferRunner) run(cmd *cobra.Command, args []string) {
@@ -66,19 +103,47 @@ func (r *inferRunner) run(cmd *cobra.Command, args []string) {
 }
 
 func (r *inferRunner) execute(cmd *cobra.Command, args []string) (errors error) {
-	var (
-		targetFile = args[0]
-		err        error
-	)
-	model, err := r.train(cmd.Context(), r.trainingFile, r.account)
+	if r.evaluate {
+		return r.evaluateModel(cmd, args)
+	}
+	if r.trainingFile == "" && r.modelFile == "" {
+		return fmt.Errorf("either a training file or a model file is required")
+	}
+	if len(args) == 0 && r.saveModelFile == "" {
+		return fmt.Errorf("missing target file")
+	}
+	model, err := r.loadModel(cmd.Context())
 	if err != nil {
 		return err
 	}
-	file, err := r.parseAndInfer(cmd.Context(), model, targetFile)
+	if r.saveModelFile != "" {
+		var buf bytes.Buffer
+		if err := model.Save(&buf); err != nil {
+			return err
+		}
+		if err := atomic.WriteFile(r.saveModelFile, &buf); err != nil {
+			return err
+		}
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	targetFile := args[0]
+	model.MinConfidence = r.minConfidence
+	if r.explain > 0 {
+		model.Explain = r.explainer(cmd.ErrOrStderr())
+	}
+	var rs rules.Rules
+	if r.rulesFile != "" {
+		if rs, err = rules.Read(r.rulesFile); err != nil {
+			return err
+		}
+	}
+	file, err := r.parseAndInfer(cmd, rs, model, targetFile)
 	if err != nil {
 		return err
 	}
-	if r.inplace {
+	if r.inplace || r.interactive {
 		var buf bytes.Buffer
 		if err := syntax.FormatFile(&buf, file); err != nil {
 			return err
@@ -91,6 +156,133 @@ func (r *inferRunner) execute(cmd *cobra.Command, args []string) (errors error)
 	}
 }
 
+func (r *inferRunner) evaluateModel(cmd *cobra.Command, args []string) error {
+	if r.trainingFile == "" || len(args) > 0 {
+		return fmt.Errorf("--evaluate requires a training file and no target file")
+	}
+	if r.holdout <= 0 || r.holdout >= 1 {
+		return fmt.Errorf("invalid holdout fraction %v, must be between 0 and 1", r.holdout)
+	}
+	ts, err := r.readTransactions(cmd.Context(), r.trainingFile)
+	if err != nil {
+		return err
+	}
+	var (
+		model = bayes.NewModel(r.account)
+		test  []*syntax.Transaction
+	)
+	model.MinConfidence = r.minConfidence
+	for i, t := range ts {
+		if r.isHeldOut(i, t) {
+			test = append(test, t)
+		} else {
+			model.Update(t)
+		}
+	}
+	if len(test) == 0 {
+		return fmt.Errorf("no transactions held out")
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Trained on %d transactions, evaluated on %d transactions\n", len(ts)-len(test), len(test))
+	e := bayes.Evaluation{Predictions: model.Evaluate(test)}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	var tr table.TextRenderer
+	if err := tr.Render(e.Summary(), out); err != nil {
+		return err
+	}
+	if r.worst == 0 {
+		return nil
+	}
+	return tr.Render(e.Misclassifications(r.worst), out)
+}
+
+// isHeldOut returns whether the i-th transaction is held out. If no date
+// range is given, the held out transactions are spread evenly.
+func (r *inferRunner) isHeldOut(i int, t *syntax.Transaction) bool {
+	from, to := r.holdoutFrom.Value(), r.holdoutTo.Value()
+	if from.IsZero() && to.IsZero() {
+		return int(float64(i+1)*r.holdout) > int(float64(i)*r.holdout)
+	}
+	d, err := t.Date.Parse()
+	if err != nil {
+		return false
+	}
+	return !d.Before(from) && (to.IsZero() || !d.After(to))
+}
+
+// readTransactions reads the transactions of a journal, ordered by date
+// and description.
+func (inferRunner) readTransactions(ctx context.Context, file string) ([]*syntax.Transaction, error) {
+	var (
+		ts    []*syntax.Transaction
+		dates = make(map[*syntax.Transaction]time.Time)
+	)
+	p := pool.New().WithErrors().WithFirstError().WithContext(ctx)
+	ch, worker := syntax.ParseFileRecursively(file)
+	p.Go(worker)
+	p.Go(func(ctx context.Context) error {
+		return cpr.ForEach(ctx, ch, func(res syntax.File) error {
+			for _, d := range res.Directives {
+				if t, ok := d.Directive.(syntax.Transaction); ok {
+					date, err := t.Date.Parse()
+					if err != nil {
+						return err
+					}
+					ts = append(ts, &t)
+					dates[&t] = date
+				}
+			}
+			return nil
+		})
+	})
+	if err := p.Wait(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(ts, func(i, j int) bool {
+		if !dates[ts[i]].Equal(dates[ts[j]]) {
+			return dates[ts[i]].Before(dates[ts[j]])
+		}
+		return ts[i].Description.Content.Extract() < ts[j].Description.Content.Extract()
+	})
+	return ts, nil
+}
+
+func (r *inferRunner) loadModel(ctx context.Context) (*bayes.Model, error) {
+	if r.modelFile == "" {
+		return r.train(ctx, r.trainingFile, r.account)
+	}
+	f, err := os.Open(r.modelFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	model, err := bayes.Load(bufio.NewReader(f), r.account)
+	if err != nil {
+		return nil, fmt.Errorf("error reading model %s: %w", r.modelFile, err)
+	}
+	return model, nil
+}
+
+func (r *inferRunner) explainer(w io.Writer) func(*syntax.Transaction, *syntax.Booking, []bayes.Candidate) {
+	return func(t *syntax.Transaction, b *syntax.Booking, cs []bayes.Candidate) {
+		fmt.Fprintf(w, "%s \"%s\" %s %s %s %s\n", t.Date.Extract(), t.Description.Content.Extract(),
+			b.Credit.Extract(), b.Debit.Extract(), b.Quantity.Extract(), b.Commodity.Extract())
+		for i, c := range cs {
+			if i == r.explain {
+				break
+			}
+			var tokens []string
+			for j, t := range c.Tokens {
+				if j == 3 {
+					break
+				}
+				tokens = append(tokens, fmt.Sprintf("%s %+.2f", t.Token, t.Score))
+			}
+			fmt.Fprintf(w, "  %5.1f%% %-30s %10.2f  %s\n", 100*c.Probability, c.Account, c.Score, strings.Join(tokens, ", "))
+		}
+	}
+}
+
 func (inferRunner) train(ctx context.Context, file string, account string) (*bayes.Model, error) {
 	model := bayes.NewModel(account)
 	p := pool.New().WithErrors().WithFirstError().WithContext(ctx)
@@ -109,14 +301,26 @@ func (inferRunner) train(ctx context.Context, file string, account string) (*bay
 	return model, p.Wait()
 }
 
-func (r *inferRunner) parseAndInfer(ctx context.Context, model *bayes.Model, targetFile string) (syntax.File, error) {
+func (r *inferRunner) parseAndInfer(cmd *cobra.Command, rs rules.Rules, model *bayes.Model, targetFile string) (syntax.File, error) {
 	f, err := syntax.ParseFile(targetFile)
 	if err != nil {
 		return syntax.File{}, err
 	}
+	var rv *reviewer
+	if r.interactive {
+		rv = newReviewer(model, r.account, f, cmd.InOrStdin(), cmd.OutOrStdout())
+	}
 	for i := range f.Directives {
 		if t, ok := f.Directives[i].Directive.(syntax.Transaction); ok {
-			model.Infer(&t)
+			rs.Infer(&t, r.account)
+			if rv != nil {
+				if err := rv.review(&t); err != nil {
+					return syntax.File{}, err
+				}
+			} else {
+				model.Infer(&t)
+			}
+			f.Directives[i].Directive = t
 		}
 	}
 	return f, nil
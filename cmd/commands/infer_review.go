@@ -0,0 +1,186 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sboehler/knut/lib/syntax/bayes"
+)
+
+const (
+	maxSuggestions = 5
+	maxCompletions = 10
+)
+
+// reviewer lets the user choose the accounts of bookings interactively.
+type reviewer struct {
+	model    *bayes.Model
+	accounts *account.Registry
+	account  string
+	in       *bufio.Scanner
+	out      io.Writer
+	quit     bool
+}
+
+func newReviewer(model *bayes.Model, tbd string, f syntax.File, in io.Reader, out io.Writer) *reviewer {
+	rv := &reviewer{
+		model:    model,
+		accounts: account.NewRegistry(),
+		account:  tbd,
+		in:       bufio.NewScanner(in),
+		out:      out,
+	}
+	// Accounts which are not valid are not offered for completion.
+	for _, a := range model.Accounts() {
+		rv.accounts.Get(a)
+	}
+	for _, d := range f.Directives {
+		if t, ok := d.Directive.(syntax.Transaction); ok {
+			for _, b := range t.Bookings {
+				rv.accounts.Create(b.Credit)
+				rv.accounts.Create(b.Debit)
+			}
+		}
+	}
+	return rv
+}
+
+// review asks the user for the accounts of the bookings of t which are
+// booked against the account to be inferred. Confirmed accounts update the
+// model.
+func (rv *reviewer) review(t *syntax.Transaction) error {
+	for i := range t.Bookings {
+		if rv.quit {
+			return nil
+		}
+		var (
+			b      = &t.Bookings[i]
+			target *syntax.Account
+			other  string
+		)
+		switch rv.account {
+		case b.Credit.Extract():
+			target, other = &b.Credit, b.Debit.Extract()
+		case b.Debit.Extract():
+			target, other = &b.Debit, b.Credit.Extract()
+		default:
+			continue
+		}
+		a, err := rv.ask(t, b, other)
+		if err != nil {
+			return err
+		}
+		if a == "" {
+			continue
+		}
+		*target = syntax.Account{
+			Range: syntax.Range{Start: 0, End: len(a), Text: a},
+		}
+		rv.model.UpdateBooking(t, b)
+	}
+	return nil
+}
+
+// ask returns the account chosen by the user, or an empty string if the
+// booking is skipped.
+func (rv *reviewer) ask(t *syntax.Transaction, b *syntax.Booking, other string) (string, error) {
+	cs := rv.model.Candidates(t, b, other)
+	if len(cs) > maxSuggestions {
+		cs = cs[:maxSuggestions]
+	}
+	fmt.Fprintf(rv.out, "\n%s \"%s\"\n", t.Date.Extract(), t.Description.Content.Extract())
+	fmt.Fprintf(rv.out, "  %s %s %s %s\n", b.Credit.Extract(), b.Debit.Extract(), b.Quantity.Extract(), b.Commodity.Extract())
+	for i, c := range cs {
+		fmt.Fprintf(rv.out, "  %d) %-40s %5.1f%%\n", i+1, c.Account, 100*c.Probability)
+	}
+	def := "s"
+	if len(cs) > 0 {
+		def = "1"
+	}
+	for {
+		fmt.Fprintf(rv.out, "Number or account, s to skip, q to quit [%s]: ", def)
+		if !rv.in.Scan() {
+			fmt.Fprintln(rv.out)
+			rv.quit = true
+			return "", rv.in.Err()
+		}
+		input := strings.TrimSpace(rv.in.Text())
+		if input == "" {
+			input = def
+		}
+		switch input {
+		case "s":
+			return "", nil
+		case "q":
+			rv.quit = true
+			return "", nil
+		}
+		if n, err := strconv.Atoi(input); err == nil {
+			if n >= 1 && n <= len(cs) {
+				return cs[n-1].Account, nil
+			}
+			fmt.Fprintf(rv.out, "Invalid choice %d.\n", n)
+			continue
+		}
+		if a, ok := rv.complete(input, other); ok {
+			return a, nil
+		}
+	}
+}
+
+// complete completes the given input to an account. New accounts must be
+// confirmed.
+func (rv *reviewer) complete(input, other string) (string, bool) {
+	var matches []string
+	for _, a := range rv.accounts.Complete(input) {
+		if a.Name() == input {
+			return input, true
+		}
+		if a.Name() != rv.account && a.Name() != other {
+			matches = append(matches, a.Name())
+		}
+	}
+	switch {
+	case len(matches) == 1:
+		fmt.Fprintf(rv.out, "  -> %s\n", matches[0])
+		return matches[0], true
+	case len(matches) > maxCompletions:
+		fmt.Fprintf(rv.out, "Ambiguous, %d accounts match: %s, ...\n", len(matches), strings.Join(matches[:maxCompletions], ", "))
+		return "", false
+	case len(matches) > 1:
+		fmt.Fprintf(rv.out, "Ambiguous, %d accounts match: %s\n", len(matches), strings.Join(matches, ", "))
+		return "", false
+	}
+	if !strings.Contains(input, ":") {
+		fmt.Fprintf(rv.out, "Unknown account %q.\n", input)
+		return "", false
+	}
+	fmt.Fprintf(rv.out, "Create new account %s? [y/N]: ", input)
+	if !rv.in.Scan() || strings.ToLower(strings.TrimSpace(rv.in.Text())) != "y" {
+		return "", false
+	}
+	if _, err := rv.accounts.Get(input); err != nil {
+		fmt.Fprintln(rv.out, err)
+		return "", false
+	}
+	return input, true
+}
@@ -15,6 +15,9 @@
 package commands
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/sboehler/knut/cmd/cmdtest"
@@ -28,3 +31,49 @@ func TestInfer(t *testing.T) {
 
 	goldie.New(t, goldie.WithFixtureDir("testdata/infer")).Assert(t, "target", got)
 }
+
+func TestInferWithModel(t *testing.T) {
+	model := filepath.Join(t.TempDir(), "model.json")
+	cmdtest.Run(t, CreateInferCmd(), "--training-file", "testdata/infer/training.knut", "--save-model", model)
+
+	got := cmdtest.Run(t, CreateInferCmd(), "--model", model, "testdata/infer/target.knut")
+
+	goldie.New(t, goldie.WithFixtureDir("testdata/infer")).Assert(t, "target", got)
+}
+
+func TestInferMinConfidence(t *testing.T) {
+
+	got := cmdtest.Run(t, CreateInferCmd(), "--training-file", "testdata/infer/training.knut", "--min-confidence", "0.99", "testdata/infer/target.knut")
+
+	goldie.New(t, goldie.WithFixtureDir("testdata/infer")).Assert(t, "target_min_confidence", got)
+}
+
+func TestInferEvaluate(t *testing.T) {
+
+	got := cmdtest.Run(t, CreateInferCmd(), "--training-file", "testdata/infer/evaluate.knut", "--evaluate", "--holdout", "0.4")
+
+	goldie.New(t, goldie.WithFixtureDir("testdata/infer")).Assert(t, "evaluate", got)
+}
+
+func TestInferInteractive(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "target.knut")
+	content, err := os.ReadFile("testdata/infer/target.knut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := CreateInferCmd()
+	cmd.SetIn(strings.NewReader("2\nfoo\nfooba\n"))
+
+	prompts := cmdtest.Run(t, cmd, "--training-file", "testdata/infer/training.knut", "--interactive", target)
+
+	got, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := goldie.New(t, goldie.WithFixtureDir("testdata/infer"))
+	g.Assert(t, "interactive_prompts", prompts)
+	g.Assert(t, "interactive", got)
+}
@@ -29,5 +29,6 @@ func CreatePortfolioCommand() *cobra.Command {
 	}
 	c.AddCommand(returns.CreateReturnsCommand())
 	c.AddCommand(returns.CreateWeightsCommand())
+	c.AddCommand(returns.CreateCurrencyCommand())
 	return c
 }
@@ -0,0 +1,184 @@
+// Copyright 2020 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package portfolio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/check"
+	"github.com/sboehler/knut/lib/journal/performance"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/reports/exposure"
+	"github.com/sboehler/knut/lib/reports/weights"
+)
+
+// CreateCurrencyCommand creates the command.
+func CreateCurrencyCommand() *cobra.Command {
+
+	var r currencyRunner
+	// Cmd is the balance command.
+	c := &cobra.Command{
+		Use:   "currency",
+		Short: "compute portfolio currency exposure",
+		Long: `Compute the portfolio weights by underlying currency exposure.
+
+Commodities are mapped to currencies using a YAML file, where each entry is either
+a single currency or a map of currencies to fractions:
+
+  AAPL: USD
+  VWRL:
+    USD: 0.6
+    EUR: 0.4
+
+Commodities which are not mapped are treated as currencies if they are the valuation
+commodity or other commodities are quoted in them. Other unmapped commodities are listed
+as (unmapped) and do not contribute to the FX returns. knut journals have no commodity
+metadata, so the mapping is only read from this file.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		Run: r.run,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type currencyRunner struct {
+	flags.Multiperiod
+
+	valuation             flags.CommodityFlag
+	prefer                flags.PreferFlag
+	accounts, commodities flags.RegexFlag
+
+	// formatting
+	color  bool
+	digits int32
+
+	sortAlphabetically bool
+
+	currencies string
+	returns    bool
+
+	csv bool
+}
+
+func (r *currencyRunner) setupFlags(cmd *cobra.Command) {
+	r.Multiperiod.Setup(cmd)
+	cmd.Flags().StringVarP(&r.currencies, "currencies", "", "", "currency exposure file")
+	cmd.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.prefer.Setup(cmd)
+	cmd.Flags().Var(&r.accounts, "account", "filter accounts with a regex")
+	cmd.Flags().Var(&r.commodities, "commodity", "filter commodities with a regex")
+	cmd.Flags().BoolVar(&r.returns, "returns", false, "show the contribution of exchange rates to returns")
+
+	cmd.Flags().BoolVarP(&r.sortAlphabetically, "sort", "a", false, "Sort accounts alphabetically")
+	cmd.Flags().BoolVar(&r.csv, "csv", false, "render csv")
+	cmd.Flags().Int32Var(&r.digits, "digits", 0, "round to number of digits")
+	cmd.Flags().BoolVar(&r.color, "color", true, "print output in color")
+	cmd.MarkFlagRequired("val")
+}
+
+func (r *currencyRunner) run(cmd *cobra.Command, args []string) {
+	if err := r.execute(cmd, args); err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		os.Exit(1)
+	}
+}
+
+func (r *currencyRunner) execute(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	reg := registry.New()
+	var exposures performance.Exposures
+	if len(r.currencies) > 0 {
+		var err error
+		exposures, err = performance.LoadExposuresFromFile(reg.Commodities(), r.currencies)
+		if err != nil {
+			return err
+		}
+	}
+	valuation, err := r.valuation.Value(reg)
+	if err != nil {
+		return err
+	}
+	preferred, err := r.prefer.Value(reg)
+	if err != nil {
+		return err
+	}
+	j, err := journal.FromPath(ctx, reg, args[0])
+	if err != nil {
+		return err
+	}
+	partition := r.Multiperiod.Partition(j.Period())
+	calculator := &performance.Calculator{
+		Context:         reg,
+		Valuation:       valuation,
+		AccountFilter:   predicate.ByName[*model.Account](r.accounts.Regex()),
+		CommodityFilter: predicate.ByName[*model.Commodity](r.commodities.Regex()),
+	}
+	j.Days(partition.EndDates())
+	rep := weights.NewReport()
+	var fxRep *exposure.Report
+	if r.returns {
+		fxRep = exposure.NewReport()
+	}
+	err = j.Build().Process(
+		journal.PriceComputer{Valuation: valuation, Preferred: preferred}.Compute(),
+		check.Check(),
+		journal.CorporateActions(reg),
+		journal.Valuate(reg, valuation),
+		calculator.ComputeValues(),
+		exposure.Query{
+			Exposures: exposures,
+			Partition: partition,
+			Valuation: valuation,
+		}.Execute(j, rep, fxRep),
+	)
+	if err != nil {
+		return err
+	}
+	reportRenderer := weights.Renderer{
+		SortAlphabetically: r.sortAlphabetically,
+	}
+	var tableRenderer Renderer
+	if r.csv {
+		tableRenderer = &table.CSVRenderer{}
+	} else {
+		tableRenderer = &table.TextRenderer{
+			Color: r.color,
+			Round: r.digits,
+		}
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	if err := tableRenderer.Render(reportRenderer.Render(rep), out); err != nil {
+		return err
+	}
+	if fxRep == nil {
+		return nil
+	}
+	if _, err := io.WriteString(out, "\n"); err != nil {
+		return err
+	}
+	return tableRenderer.Render(fxRep.Render(), out)
+}
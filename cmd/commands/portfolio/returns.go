@@ -52,6 +52,7 @@ type returnsRunner struct {
 	flags.Multiperiod
 	cpuprofile            string
 	valuation             flags.CommodityFlag
+	prefer                flags.PreferFlag
 	accounts, commodities flags.RegexFlag
 }
 
@@ -59,6 +60,7 @@ func (r *returnsRunner) setupFlags(cmd *cobra.Command) {
 	r.Multiperiod.Setup(cmd)
 	cmd.Flags().StringVar(&r.cpuprofile, "cpuprofile", "", "file to write profile")
 	cmd.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.prefer.Setup(cmd)
 	cmd.Flags().Var(&r.accounts, "account", "filter accounts with a regex")
 	cmd.Flags().Var(&r.commodities, "commodity", "filter commodities with a regex")
 }
@@ -85,6 +87,10 @@ func (r *returnsRunner) execute(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	preferred, err := r.prefer.Value(reg)
+	if err != nil {
+		return err
+	}
 	j, err := journal.FromPath(ctx, reg, args[0])
 	if err != nil {
 		return err
@@ -97,8 +103,9 @@ func (r *returnsRunner) execute(cmd *cobra.Command, args []string) error {
 		CommodityFilter: predicate.ByName[*model.Commodity](r.commodities.Regex()),
 	}
 	err = j.Build().Process(
-		journal.ComputePrices(valuation),
+		journal.PriceComputer{Valuation: valuation, Preferred: preferred}.Compute(),
 		check.Check(),
+		journal.CorporateActions(reg),
 		journal.Valuate(reg, valuation),
 		calculator.ComputeValues(),
 		calculator.ComputeFlows(),
@@ -54,6 +54,7 @@ type weightsRunner struct {
 	flags.Multiperiod
 
 	valuation             flags.CommodityFlag
+	prefer                flags.PreferFlag
 	accounts, commodities flags.RegexFlag
 
 	// formatting
@@ -73,6 +74,7 @@ func (r *weightsRunner) setupFlags(cmd *cobra.Command) {
 	r.Multiperiod.Setup(cmd)
 	cmd.Flags().StringVarP(&r.universe, "universe", "", "", "universe file")
 	cmd.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.prefer.Setup(cmd)
 	cmd.Flags().Var(&r.accounts, "account", "filter accounts with a regex")
 	cmd.Flags().Var(&r.commodities, "commodity", "filter commodities with a regex")
 
@@ -107,6 +109,10 @@ func (r *weightsRunner) execute(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	preferred, err := r.prefer.Value(reg)
+	if err != nil {
+		return err
+	}
 	j, err := journal.FromPath(ctx, reg, args[0])
 	if err != nil {
 		return err
@@ -121,8 +127,9 @@ func (r *weightsRunner) execute(cmd *cobra.Command, args []string) error {
 	j.Days(partition.EndDates())
 	rep := weights.NewReport()
 	err = j.Build().Process(
-		journal.ComputePrices(valuation),
+		journal.PriceComputer{Valuation: valuation, Preferred: preferred}.Compute(),
 		check.Check(),
+		journal.CorporateActions(reg),
 		journal.Valuate(reg, valuation),
 		calculator.ComputeValues(),
 		weights.Query{
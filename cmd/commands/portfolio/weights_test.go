@@ -0,0 +1,44 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package portfolio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWeightsRejectsIncompleteAllocations(t *testing.T) {
+	dir := t.TempDir()
+	universe := filepath.Join(dir, "universe.yaml")
+	if err := os.WriteFile(universe, []byte("Equities:\n  - VWRL: 0.6\nBonds:\n  - VWRL: 0.3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	journal := filepath.Join(dir, "journal.knut")
+	if err := os.WriteFile(journal, []byte("2020-01-01 price VWRL 10 CHF\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := CreateWeightsCommand()
+	cmd.SetContext(context.Background())
+	r := weightsRunner{universe: universe}
+
+	err := r.execute(cmd, []string{journal})
+
+	if err == nil || !strings.Contains(err.Error(), "VWRL sum up to") {
+		t.Fatalf("execute() returned error %v, want an error about the sum of the allocations", err)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/commands/prices"
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+	pricesreport "github.com/sboehler/knut/lib/reports/prices"
+)
+
+// CreatePricesCommand creates the command.
+func CreatePricesCommand() *cobra.Command {
+	var r pricesRunner
+	c := &cobra.Command{
+		Use:   "prices",
+		Short: "list prices",
+		Long: `List the effective prices of commodities in the valuation commodity at the end
+of every period.
+
+By default, the most recent price is used, as in valuations. With --interpolate linear,
+prices are interpolated linearly between the days on which a commodity is priced.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		Run: r.run,
+	}
+	r.setupFlags(c)
+	c.AddCommand(prices.CreateExplainCommand())
+	return c
+}
+
+type pricesRunner struct {
+	flags.Multiperiod
+
+	valuation   flags.CommodityFlag
+	prefer      flags.PreferFlag
+	commodities flags.RegexFlag
+	interpolate string
+
+	// formatting
+	csv, json bool
+	digits    int32
+	color     bool
+}
+
+func (r *pricesRunner) setupFlags(c *cobra.Command) {
+	r.Multiperiod.Setup(c)
+	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.prefer.Setup(c)
+	c.Flags().Var(&r.commodities, "commodity", "filter commodities with a regex")
+	c.Flags().StringVar(&r.interpolate, "interpolate", "previous", "interpolation of missing prices (previous, linear)")
+	c.Flags().BoolVar(&r.csv, "csv", false, "render csv")
+	c.Flags().BoolVar(&r.json, "json", false, "render json")
+	c.Flags().Int32Var(&r.digits, "digits", 4, "round to number of digits")
+	c.Flags().BoolVar(&r.color, "color", true, "print output in color")
+	c.MarkFlagRequired("val")
+	c.MarkFlagsMutuallyExclusive("csv", "json")
+}
+
+func (r *pricesRunner) run(cmd *cobra.Command, args []string) {
+	if err := r.execute(cmd, args); err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		os.Exit(1)
+	}
+}
+
+func (r *pricesRunner) execute(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	valuation, err := r.valuation.Value(reg)
+	if err != nil {
+		return err
+	}
+	preferred, err := r.prefer.Value(reg)
+	if err != nil {
+		return err
+	}
+	interpolation, err := pricesreport.ParseInterpolation(r.interpolate)
+	if err != nil {
+		return err
+	}
+	j, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	partition := r.Multiperiod.Partition(j.Period())
+	j.Days(partition.EndDates())
+	report := pricesreport.NewReport(valuation, interpolation)
+	err = j.Build().Process(
+		journal.PriceComputer{Valuation: valuation, Preferred: preferred}.Compute(),
+		pricesreport.Query{
+			Partition:   partition,
+			Commodities: predicate.ByName[*model.Commodity](r.commodities.Regex()),
+			Valuation:   valuation,
+		}.Execute(j, report),
+	)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	if r.json {
+		return report.WriteJSON(out)
+	}
+	var tableRenderer Renderer
+	if r.csv {
+		tableRenderer = &table.CSVRenderer{}
+	} else {
+		tableRenderer = &table.TextRenderer{
+			Color: r.color,
+			Round: r.digits,
+		}
+	}
+	return tableRenderer.Render(report.Render(), out)
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prices
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/price"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/shopspring/decimal"
+)
+
+// CreateExplainCommand creates the command.
+func CreateExplainCommand() *cobra.Command {
+	var r explainRunner
+	c := &cobra.Command{
+		Use:   "explain <journal> <commodity>",
+		Short: "explain the valuation of a commodity",
+		Long: `Explain the path in the price graph and the prices used to value a commodity
+in the valuation commodity on a date (default: the last date of the journal).`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(2), cobra.OnlyValidArgs),
+
+		Run: r.run,
+	}
+	r.setupFlags(c)
+	return c
+}
+
+type explainRunner struct {
+	valuation flags.CommodityFlag
+	prefer    flags.PreferFlag
+	date      flags.DateFlag
+}
+
+func (r *explainRunner) setupFlags(c *cobra.Command) {
+	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.prefer.Setup(c)
+	c.Flags().Var(&r.date, "date", "the date of the valuation")
+	c.MarkFlagRequired("val")
+}
+
+func (r *explainRunner) run(cmd *cobra.Command, args []string) {
+	if err := r.execute(cmd, args); err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		os.Exit(1)
+	}
+}
+
+func (r *explainRunner) execute(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	valuation, err := r.valuation.Value(reg)
+	if err != nil {
+		return err
+	}
+	preferred, err := r.prefer.Value(reg)
+	if err != nil {
+		return err
+	}
+	j, err := journal.FromPath(cmd.Context(), reg, args[0])
+	if err != nil {
+		return err
+	}
+	com, err := reg.Commodities().Get(args[1])
+	if err != nil {
+		return err
+	}
+	date := r.date.ValueOr(j.Period().End)
+	graph := price.NewGraph()
+	for c, quote := range preferred {
+		graph.Prefer(c, quote)
+	}
+	err = j.Build().Process(&journal.Processor{
+		Price: func(p *model.Price) error {
+			if p.Date.After(date) {
+				return nil
+			}
+			return graph.Add(p)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	steps, err := graph.Explain(valuation, com)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	for _, s := range steps {
+		fmt.Fprintf(out, "%s: 1 %s = %s %s (%s)\n", date.Format("2006-01-02"), s.Commodity.Name(), s.Price, s.Quote.Name(), s.Date.Format("2006-01-02"))
+	}
+	// multiply in the same order as the normalization, starting at the valuation commodity
+	total := decimal.NewFromInt(1)
+	for i := len(steps) - 1; i >= 0; i-- {
+		total = price.Multiply(steps[i].Price, total)
+	}
+	fmt.Fprintf(out, "%s: 1 %s = %s %s\n", date.Format("2006-01-02"), com.Name(), total, valuation.Name())
+	return nil
+}
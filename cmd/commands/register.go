@@ -68,6 +68,9 @@ type registerRunner struct {
 	mapping                       flags.MappingFlag
 	remap                         flags.RegexFlag
 	valuation                     flags.CommodityFlag
+	prefer                        flags.PreferFlag
+	splitFX                       bool
+	maxPriceAge                   int
 	accounts, others, commodities flags.RegexFlag
 
 	// formatting
@@ -100,6 +103,9 @@ func (r *registerRunner) setupFlags(c *cobra.Command) {
 	c.Flags().BoolVarP(&r.showDescriptions, "show-descriptions", "d", false, "Show descriptions")
 	c.Flags().BoolVarP(&r.showSource, "show-source", "a", false, "Show the source accounts")
 	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.prefer.Setup(c)
+	c.Flags().BoolVar(&r.splitFX, "split-fx", false, "split valuation gains into price and FX effects")
+	c.Flags().IntVar(&r.maxPriceAge, "max-price-age", 0, "fail if a price used for valuation is older than this many days (0: no limit)")
 	c.Flags().VarP(&r.mapping, "map", "m", "<level>,<regex>")
 	c.Flags().VarP(&r.remap, "remap", "r", "<regex>")
 	c.Flags().Var(&r.accounts, "source", "filter source accounts with a regex")
@@ -117,6 +123,10 @@ func (r registerRunner) execute(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	preferred, err := r.prefer.Value(reg)
+	if err != nil {
+		return err
+	}
 	r.showCommodities = r.showCommodities || valuation == nil
 	b, err := journal.FromPath(ctx, reg, args[0])
 	if err != nil {
@@ -131,9 +141,10 @@ func (r registerRunner) execute(cmd *cobra.Command, args []string) error {
 	j := b.Build()
 	err = j.Process(
 		journal.Sort(),
-		journal.ComputePrices(valuation),
+		journal.PriceComputer{Valuation: valuation, Preferred: preferred}.Compute(),
 		check.Check(),
-		journal.Valuate(reg, valuation),
+		journal.CorporateActions(reg),
+		r.valuate(reg, valuation),
 		journal.Filter(partition),
 		journal.Query{
 			Select: amounts.KeyMapper{
@@ -173,3 +184,12 @@ func (r registerRunner) execute(cmd *cobra.Command, args []string) error {
 	defer out.Flush()
 	return tableRenderer.Render(reportRenderer.Render(rep), out)
 }
+
+func (r registerRunner) valuate(reg *model.Registry, valuation *model.Commodity) *journal.Processor {
+	return journal.Valuator{
+		Registry:    reg,
+		Valuation:   valuation,
+		SplitFX:     r.splitFX,
+		MaxPriceAge: r.maxPriceAge,
+	}.Valuate()
+}
@@ -50,10 +50,12 @@ func CreateTranscodeCommand() *cobra.Command {
 
 type transcodeRunner struct {
 	valuation flags.CommodityFlag
+	prefer    flags.PreferFlag
 }
 
 func (r *transcodeRunner) setupFlags(c *cobra.Command) {
 	c.Flags().VarP(&r.valuation, "val", "v", "valuate in the given commodity")
+	r.prefer.Setup(c)
 }
 
 func (r *transcodeRunner) run(cmd *cobra.Command, args []string) {
@@ -72,6 +74,10 @@ func (r *transcodeRunner) execute(cmd *cobra.Command, args []string) (errors err
 	if valuation, err = r.valuation.Value(reg); err != nil {
 		return err
 	}
+	preferred, err := r.prefer.Value(reg)
+	if err != nil {
+		return err
+	}
 	b, err := journal.FromPath(cmd.Context(), reg, args[0])
 	if err != nil {
 		return err
@@ -79,8 +85,9 @@ func (r *transcodeRunner) execute(cmd *cobra.Command, args []string) (errors err
 	j := b.Build()
 	err = j.Process(
 		journal.Sort(),
-		journal.ComputePrices(valuation),
+		journal.PriceComputer{Valuation: valuation, Preferred: preferred}.Compute(),
 		check.Check(),
+		journal.CorporateActions(reg),
 		journal.Valuate(reg, valuation),
 	)
 	if err != nil {
@@ -235,6 +235,54 @@ func (cf CommodityFlag) Value(reg *model.Registry) (*model.Commodity, error) {
 	return nil, nil
 }
 
+// PreferFlag manages a repeatable flag of type <commodity>:<quote>.
+type PreferFlag struct {
+	vals []string
+}
+
+var _ pflag.Value = (*PreferFlag)(nil)
+
+// Setup configures the flag.
+func (pf *PreferFlag) Setup(cmd *cobra.Command) {
+	cmd.Flags().Var(pf, "prefer", "price the commodity in the quote commodity, even if a shorter path exists")
+}
+
+// Set implements pflag.Value.
+func (pf *PreferFlag) Set(v string) error {
+	if s := strings.Split(v, ":"); len(s) != 2 || s[0] == "" || s[1] == "" {
+		return fmt.Errorf("expected <commodity>:<quote>, got %q", v)
+	}
+	pf.vals = append(pf.vals, v)
+	return nil
+}
+
+// Type implements pflag.Value.
+func (pf PreferFlag) Type() string {
+	return "<commodity>:<quote>"
+}
+
+func (pf PreferFlag) String() string {
+	return strings.Join(pf.vals, ",")
+}
+
+// Value returns the quote commodities by commodity.
+func (pf PreferFlag) Value(reg *model.Registry) (map[*model.Commodity]*model.Commodity, error) {
+	res := make(map[*model.Commodity]*model.Commodity)
+	for _, v := range pf.vals {
+		s := strings.Split(v, ":")
+		c, err := reg.Commodities().Get(s[0])
+		if err != nil {
+			return nil, err
+		}
+		quote, err := reg.Commodities().Get(s[1])
+		if err != nil {
+			return nil, err
+		}
+		res[c] = quote
+	}
+	return res, nil
+}
+
 // AccountFlag manages a flag to parse a commodity.
 type AccountFlag struct {
 	val string
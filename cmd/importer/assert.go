@@ -0,0 +1,162 @@
+package importer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+)
+
+// Values of the --assert flag.
+const (
+	// AssertNone imports no balance assertions.
+	AssertNone = "none"
+	// AssertLast imports the last balance of every account and commodity.
+	AssertLast = "last"
+	// AssertDaily imports the balance at the end of every day.
+	AssertDaily = "daily"
+)
+
+// NoBalances is the comment which is printed if an import has no balance
+// assertions.
+const NoBalances = "# no balance assertions: the statement contains no balances"
+
+// Balances collects the balances of an account from a balance column of a
+// statement, in file order.
+type Balances struct {
+	account *model.Account
+
+	// balances holds the first and the last balance seen for every date
+	// and commodity.
+	balances map[amounts.Key][2]decimal.Decimal
+	dates    []amounts.Key
+
+	// previous holds the amount and the balance of the previous row of
+	// every commodity.
+	previous map[*model.Commodity][2]decimal.Decimal
+
+	// ascending and descending count the consecutive rows whose balances
+	// are consistent with the respective order of the statement.
+	ascending, descending int
+
+	last              time.Time
+	descendingByDates bool
+}
+
+// NewBalances creates balances for the given account.
+func NewBalances(account *model.Account) *Balances {
+	return &Balances{
+		account:  account,
+		balances: make(map[amounts.Key][2]decimal.Decimal),
+		previous: make(map[*model.Commodity][2]decimal.Decimal),
+	}
+}
+
+// Add adds the balance after a booking of the given amount.
+func (b *Balances) Add(date time.Time, commodity *model.Commodity, amount, balance decimal.Decimal) {
+	if date.Before(b.last) {
+		b.descendingByDates = true
+	}
+	b.last = date
+	if prev, ok := b.previous[commodity]; ok {
+		asc := prev[1].Add(amount).Equal(balance)
+		desc := balance.Add(prev[0]).Equal(prev[1])
+		switch {
+		case asc && !desc:
+			b.ascending++
+		case desc && !asc:
+			b.descending++
+		}
+	}
+	b.previous[commodity] = [2]decimal.Decimal{amount, balance}
+	k := amounts.DateCommodityKey(date, commodity)
+	if bs, ok := b.balances[k]; ok {
+		b.balances[k] = [2]decimal.Decimal{bs[0], balance}
+	} else {
+		b.balances[k] = [2]decimal.Decimal{balance, balance}
+		b.dates = append(b.dates, k)
+	}
+}
+
+// isDescending returns whether the statement is sorted newest first. The
+// order is determined by the running balance, which also works for bookings
+// on the same day, and by the dates if the balances are inconclusive.
+func (b *Balances) isDescending() bool {
+	if b.ascending != b.descending {
+		return b.descending > b.ascending
+	}
+	return b.descendingByDates
+}
+
+// Assertions returns an assertion with the balance after the last booking
+// of every day. Statements are often sorted newest first, in which case
+// this is the first booking of the day in the file.
+func (b *Balances) Assertions() []*model.Assertion {
+	var res []*model.Assertion
+	descending := b.isDescending()
+	for _, k := range b.dates {
+		bs := b.balances[k]
+		bal := bs[1]
+		if descending {
+			bal = bs[0]
+		}
+		res = append(res, &model.Assertion{
+			Date: k.Date,
+			Balances: []model.Balance{
+				{
+					Account:   b.account,
+					Commodity: k.Commodity,
+					Quantity:  bal,
+				},
+			},
+		})
+	}
+	return res
+}
+
+// FilterAssertions removes the balance assertions of the journal which are
+// not selected by mode. It returns whether any assertions are left.
+func FilterAssertions(j *journal.Journal, mode string) (bool, error) {
+	var last map[amounts.Key]time.Time
+	switch mode {
+	case AssertDaily:
+	case AssertNone:
+		for _, d := range j.Days {
+			d.Assertions = nil
+		}
+		return false, nil
+	case AssertLast:
+		last = make(map[amounts.Key]time.Time)
+		for _, d := range j.Days {
+			for _, a := range d.Assertions {
+				for _, bal := range a.Balances {
+					last[amounts.AccountCommodityKey(bal.Account, bal.Commodity)] = a.Date
+				}
+			}
+		}
+	default:
+		return false, fmt.Errorf("invalid value %q for --assert, want %s, %s or %s", mode, AssertNone, AssertLast, AssertDaily)
+	}
+	var found bool
+	for _, d := range j.Days {
+		var as []*model.Assertion
+		for _, a := range d.Assertions {
+			var bals []model.Balance
+			for _, bal := range a.Balances {
+				if last == nil || last[amounts.AccountCommodityKey(bal.Account, bal.Commodity)].Equal(a.Date) {
+					bals = append(bals, bal)
+				}
+			}
+			if len(bals) > 0 {
+				as = append(as, &model.Assertion{Src: a.Src, Date: a.Date, Balances: bals, Comments: a.Comments})
+			}
+		}
+		d.Assertions = as
+		found = found || len(as) > 0
+	}
+	return found, nil
+}
@@ -0,0 +1,134 @@
+package importer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+func TestBalancesAssertions(t *testing.T) {
+	reg := registry.New()
+	var (
+		bank = reg.Accounts().MustGet("Assets:Bank")
+		chf  = reg.Commodities().MustGet("CHF")
+	)
+	type row struct {
+		day             int
+		amount, balance int64
+	}
+	tests := []struct {
+		desc string
+		rows []row
+		want map[int]int64
+	}{
+		{
+			desc: "oldest first on one day",
+			rows: []row{{2, 10, 20}, {2, -5, 15}},
+			want: map[int]int64{2: 15},
+		},
+		{
+			desc: "newest first on one day",
+			rows: []row{{2, -5, 15}, {2, 10, 20}},
+			want: map[int]int64{2: 15},
+		},
+		{
+			desc: "newest first with inconsistent balances",
+			rows: []row{{2, 1, 30}, {2, 1, 20}, {1, 1, 10}},
+			want: map[int]int64{1: 10, 2: 30},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			bs := NewBalances(bank)
+			for _, r := range test.rows {
+				bs.Add(date.Date(2023, 1, r.day), chf, decimal.NewFromInt(r.amount), decimal.NewFromInt(r.balance))
+			}
+
+			got := make(map[int]int64)
+			for _, a := range bs.Assertions() {
+				got[a.Date.Day()] = a.Balances[0].Quantity.IntPart()
+			}
+
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Assertions() returned unexpected diff (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFilterAssertions(t *testing.T) {
+	reg := registry.New()
+	var (
+		bank = reg.Accounts().MustGet("Assets:Bank")
+		chf  = reg.Commodities().MustGet("CHF")
+		usd  = reg.Commodities().MustGet("USD")
+	)
+	build := func() *journal.Journal {
+		// newest first, with two bookings on the 2nd
+		bs := NewBalances(bank)
+		bs.Add(date.Date(2023, 1, 3), usd, decimal.NewFromInt(30), decimal.NewFromInt(30))
+		bs.Add(date.Date(2023, 1, 2), chf, decimal.NewFromInt(5), decimal.NewFromInt(20))
+		bs.Add(date.Date(2023, 1, 2), chf, decimal.NewFromInt(5), decimal.NewFromInt(15))
+		bs.Add(date.Date(2023, 1, 1), chf, decimal.NewFromInt(10), decimal.NewFromInt(10))
+		b := journal.New()
+		for _, a := range bs.Assertions() {
+			b.Add(a)
+		}
+		return b.Build()
+	}
+	tests := []struct {
+		mode  string
+		want  string
+		found bool
+	}{
+		{
+			mode:  AssertDaily,
+			found: true,
+			want: "2023-01-01 balance Assets:Bank 10 CHF\n\n" +
+				"2023-01-02 balance Assets:Bank 20 CHF\n\n" +
+				"2023-01-03 balance Assets:Bank 30 USD\n\n",
+		},
+		{
+			mode:  AssertLast,
+			found: true,
+			want: "2023-01-02 balance Assets:Bank 20 CHF\n\n" +
+				"2023-01-03 balance Assets:Bank 30 USD\n\n",
+		},
+		{
+			mode: AssertNone,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			j := build()
+
+			found, err := FilterAssertions(j, test.mode)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != test.found {
+				t.Errorf("FilterAssertions() = %t, want %t", found, test.found)
+			}
+			var got bytes.Buffer
+			if err := journal.Print(&got, j); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got.String(), test.want)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := FilterAssertions(build(), "weekly"); err == nil {
+			t.Error("FilterAssertions() returned no error")
+		}
+	})
+}
@@ -0,0 +1,507 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beancount
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/cmd/importer/plaintext"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+// CreateCmd creates the cobra command.
+func CreateCmd() *cobra.Command {
+
+	var r runner
+
+	cmd := &cobra.Command{
+		Use:   "beancount",
+		Short: "Import a beancount journal",
+		Long: `Import a beancount journal, including the files it includes. Transactions, open, close,
+balance, pad and price directives are converted. Transactions with more than two postings are
+converted to bookings between two accounts by pairing the postings of every commodity. Amounts
+which cannot be paired, e.g. the two sides of a currency exchange or of a purchase at cost, are
+booked against the balancing account. Metadata, tags and links are kept as comments, and notes
+as comments of the open directive of their account.
+
+Account names are converted to valid knut account names, and balance assertions, which beancount
+checks at the beginning of the day, are moved to the end of the previous day. Other directives,
+such as commodity, event or document, are skipped and reported on stderr.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		Run: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	balancing flags.AccountFlag
+}
+
+func (r *runner) setupFlags(c *cobra.Command) {
+	c.Flags().VarP(&r.balancing, "balancing", "b", "account for amounts which cannot be paired (default Equity:Conversions)")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) {
+	if err := r.runE(cmd, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func (r *runner) runE(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	balancing, err := r.balancing.ValueWithDefault(reg.Accounts(), reg.Accounts().MustGet("Equity:Conversions"))
+	if err != nil {
+		return err
+	}
+	p := parser{skipped: make(map[string]int)}
+	if err := p.parseFile(args[0]); err != nil {
+		return err
+	}
+	p.reportSkipped(cmd)
+	c := plaintext.Converter{Registry: reg, Balancing: balancing}
+	j, err := c.Convert(p.entries)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return importer.Print(cmd, out, j)
+}
+
+type parser struct {
+	entries []plaintext.Entry
+	skipped map[string]int
+	tags    []string
+
+	// the current entry and posting, to which indented lines belong
+	trx      *plaintext.Transaction
+	comments *[]string
+	account  string
+}
+
+func (p *parser) reportSkipped(cmd *cobra.Command) {
+	var ds []string
+	for d := range p.skipped {
+		ds = append(ds, d)
+	}
+	sort.Strings(ds)
+	for _, d := range ds {
+		fmt.Fprintf(cmd.ErrOrStderr(), "skipped %d %s directives\n", p.skipped[d], d)
+	}
+}
+
+func (p *parser) parseFile(path string) error {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(string(text), "\n") {
+		pos := fmt.Sprintf("%s:%d", path, i+1)
+		if err := p.parseLine(path, pos, strings.TrimRight(line, "\r")); err != nil {
+			return fmt.Errorf("%s: %w", pos, err)
+		}
+	}
+	p.end()
+	return nil
+}
+
+// end ends the current entry.
+func (p *parser) end() {
+	p.trx, p.comments, p.account = nil, nil, ""
+}
+
+func (p *parser) parseLine(path, pos, line string) error {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "":
+		p.end()
+		return nil
+	case line[0] == ' ' || line[0] == '\t':
+		return p.parseIndented(trimmed)
+	}
+	p.end()
+	if line[0] >= '0' && line[0] <= '9' {
+		return p.parseEntry(pos, line)
+	}
+	fields := tokenize(line)
+	switch fields[0] {
+	case "include":
+		if len(fields) != 2 {
+			return fmt.Errorf("invalid include: %s", line)
+		}
+		inc := unquote(fields[1])
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		return p.parseFile(inc)
+	case "pushtag":
+		if len(fields) == 2 {
+			p.tags = append(p.tags, fields[1])
+		}
+	case "poptag":
+		for i := len(p.tags) - 1; i >= 0; i-- {
+			if len(fields) == 2 && p.tags[i] == fields[1] {
+				p.tags = append(p.tags[:i], p.tags[i+1:]...)
+				break
+			}
+		}
+	}
+	// options, plugins, comments and org-mode headings
+	return nil
+}
+
+// parseIndented parses metadata, comments and postings.
+func (p *parser) parseIndented(line string) error {
+	if p.comments == nil {
+		// lines of a skipped directive
+		return nil
+	}
+	if strings.HasPrefix(line, ";") {
+		*p.comments = append(*p.comments, p.annotate(strings.TrimSpace(strings.TrimPrefix(line, ";"))))
+		return nil
+	}
+	if m := metadataRegex.FindStringSubmatch(line); m != nil {
+		*p.comments = append(*p.comments, p.annotate(fmt.Sprintf("%s: %s", m[1], unquote(stripComment(m[2])))))
+		return nil
+	}
+	if p.trx == nil {
+		return fmt.Errorf("unexpected line: %s", line)
+	}
+	return p.parsePosting(line)
+}
+
+// annotate adds the account of the current posting to a comment.
+func (p *parser) annotate(c string) string {
+	if p.account == "" {
+		return c
+	}
+	return fmt.Sprintf("%s (%s)", c, p.account)
+}
+
+var metadataRegex = regexp.MustCompile(`^([a-z][a-zA-Z0-9_-]*):\s*(.*)$`)
+
+func (p *parser) parsePosting(line string) error {
+	line, comment := splitComment(line)
+	if len(line) > 1 && strings.ContainsRune("*!PSTCURM#?%&", rune(line[0])) && line[1] == ' ' {
+		line = strings.TrimSpace(line[1:])
+	}
+	account, rest, _ := strings.Cut(line, " ")
+	var (
+		pst = plaintext.Posting{Account: account}
+		err error
+	)
+	rest, price, total := cutPrice(rest)
+	rest, cost, totalCost, err := cutCost(rest)
+	if err != nil {
+		return err
+	}
+	if pst.Amount, err = parseAmount(rest); err != nil {
+		return err
+	}
+	if pst.Cost, err = perUnit(cost, totalCost, pst.Amount); err != nil {
+		return err
+	}
+	if pst.Price, err = perUnit(price, total, pst.Amount); err != nil {
+		return err
+	}
+	p.trx.Postings = append(p.trx.Postings, pst)
+	p.account = account
+	if comment != "" {
+		p.trx.Comments = append(p.trx.Comments, p.annotate(comment))
+	}
+	return nil
+}
+
+// parseEntry parses a line starting with a date.
+func (p *parser) parseEntry(pos, line string) error {
+	line, comment := splitComment(line)
+	fields := tokenize(line)
+	if len(fields) < 2 {
+		return fmt.Errorf("invalid directive: %s", line)
+	}
+	date, err := time.Parse("2006-01-02", strings.ReplaceAll(fields[0], "/", "-"))
+	if err != nil {
+		return fmt.Errorf("invalid date %q", fields[0])
+	}
+	var comments []string
+	if comment != "" {
+		comments = append(comments, comment)
+	}
+	switch fields[1] {
+	case "open":
+		if len(fields) < 3 {
+			return fmt.Errorf("invalid open directive: %s", line)
+		}
+		for _, f := range fields[3:] {
+			if strings.HasPrefix(f, `"`) {
+				comments = append(comments, fmt.Sprintf("booking: %s", unquote(f)))
+			} else {
+				comments = append(comments, fmt.Sprintf("currencies: %s", f))
+			}
+		}
+		o := &plaintext.Open{Pos: pos, Date: date, Account: fields[2], Comments: comments}
+		p.entries = append(p.entries, o)
+		p.comments = &o.Comments
+	case "close":
+		if len(fields) != 3 {
+			return fmt.Errorf("invalid close directive: %s", line)
+		}
+		c := &plaintext.Close{Pos: pos, Date: date, Account: fields[2], Comments: comments}
+		p.entries = append(p.entries, c)
+		p.comments = &c.Comments
+	case "balance":
+		if len(fields) < 5 {
+			return fmt.Errorf("invalid balance directive: %s", line)
+		}
+		// the tolerance, if any, is ignored
+		amount, err := parseAmount(fields[3] + " " + fields[len(fields)-1])
+		if err != nil {
+			return err
+		}
+		b := &plaintext.Balance{Pos: pos, Date: date, Account: fields[2], Amount: *amount, Comments: comments}
+		p.entries = append(p.entries, b)
+		p.comments = &b.Comments
+	case "price":
+		if len(fields) != 5 {
+			return fmt.Errorf("invalid price directive: %s", line)
+		}
+		amount, err := parseAmount(fields[3] + " " + fields[4])
+		if err != nil {
+			return err
+		}
+		pr := &plaintext.Price{Pos: pos, Date: date, Commodity: fields[2], Price: *amount, Comments: comments}
+		p.entries = append(p.entries, pr)
+		p.comments = &pr.Comments
+	case "pad":
+		if len(fields) != 4 {
+			return fmt.Errorf("invalid pad directive: %s", line)
+		}
+		pd := &plaintext.Pad{Pos: pos, Date: date, Account: fields[2], Source: fields[3], Comments: comments}
+		p.entries = append(p.entries, pd)
+		p.comments = &pd.Comments
+	case "note":
+		if len(fields) != 4 {
+			return fmt.Errorf("invalid note directive: %s", line)
+		}
+		n := &plaintext.Note{Pos: pos, Date: date, Account: fields[2], Text: unquote(fields[3]), Comments: comments}
+		p.entries = append(p.entries, n)
+		p.comments = &n.Comments
+	case "commodity", "event", "document", "query", "custom":
+		p.skipped[fields[1]]++
+	default:
+		return p.parseTransaction(pos, date, fields, comments)
+	}
+	return nil
+}
+
+func (p *parser) parseTransaction(pos string, date time.Time, fields []string, comments []string) error {
+	flag := fields[1]
+	if flag != "txn" && len(flag) != 1 {
+		return fmt.Errorf("unknown directive %q", flag)
+	}
+	var strs, tags []string
+	for _, f := range fields[2:] {
+		switch {
+		case strings.HasPrefix(f, `"`):
+			strs = append(strs, unquote(f))
+		case strings.HasPrefix(f, "#"), strings.HasPrefix(f, "^"):
+			tags = append(tags, f)
+		default:
+			return fmt.Errorf("unexpected token %q", f)
+		}
+	}
+	var desc string
+	switch len(strs) {
+	case 1:
+		desc = strs[0]
+	case 2:
+		desc = strings.Join(nonEmpty(strs), " / ")
+	default:
+		return fmt.Errorf("invalid payee and narration: %v", strs)
+	}
+	if flag == "!" {
+		comments = append([]string{"flag: !"}, comments...)
+	}
+	tags = append(tags, p.tags...)
+	if len(tags) > 0 {
+		comments = append([]string{strings.Join(tags, " ")}, comments...)
+	}
+	t := &plaintext.Transaction{Pos: pos, Date: date, Description: desc, Comments: comments}
+	p.entries = append(p.entries, t)
+	p.trx = t
+	p.comments = &t.Comments
+	return nil
+}
+
+func nonEmpty(ss []string) []string {
+	var res []string
+	for _, s := range ss {
+		if s != "" {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// tokenize splits a line into fields, keeping quoted strings together.
+func tokenize(line string) []string {
+	var (
+		res             []string
+		b               strings.Builder
+		quoted, escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+			b.WriteRune(r)
+		case r == '\\' && quoted:
+			escaped = true
+			b.WriteRune(r)
+		case r == '"':
+			quoted = !quoted
+			b.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t'):
+			if b.Len() > 0 {
+				res = append(res, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() > 0 {
+		res = append(res, b.String())
+	}
+	return res
+}
+
+func unquote(s string) string {
+	s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), `"`), `"`)
+	return strings.ReplaceAll(s, `\"`, `"`)
+}
+
+// splitComment splits a line into its content and a trailing comment.
+func splitComment(line string) (string, string) {
+	var quoted, escaped bool
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == ';' && !quoted:
+			return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		}
+	}
+	return strings.TrimSpace(line), ""
+}
+
+func stripComment(s string) string {
+	s, _ = splitComment(s)
+	return s
+}
+
+// cutPrice cuts a price annotation, @ per unit or @@ in total.
+func cutPrice(s string) (string, string, bool) {
+	if before, after, ok := strings.Cut(s, "@@"); ok {
+		return strings.TrimSpace(before), strings.TrimSpace(after), true
+	}
+	if before, after, ok := strings.Cut(s, "@"); ok {
+		return strings.TrimSpace(before), strings.TrimSpace(after), false
+	}
+	return strings.TrimSpace(s), "", false
+}
+
+// cutCost cuts a cost specification, {} per unit or {{}} in total. Only
+// the amount of the cost is kept.
+func cutCost(s string) (string, string, bool, error) {
+	start := strings.Index(s, "{")
+	if start < 0 {
+		return s, "", false, nil
+	}
+	end := strings.LastIndex(s, "}")
+	if end < start {
+		return "", "", false, fmt.Errorf("invalid cost: %s", s)
+	}
+	cost := s[start+1 : end]
+	total := strings.HasPrefix(cost, "{")
+	cost = strings.Trim(cost, "{}")
+	var amount []string
+	for _, f := range strings.Split(cost, ",") {
+		f = strings.TrimSpace(f)
+		if f != "" && (f[0] == '-' || f[0] == '.' || f[0] >= '0' && f[0] <= '9') {
+			amount = append(amount, f)
+		}
+	}
+	if len(amount) == 0 {
+		// e.g. {} or {2020-01-01}
+		return strings.TrimSpace(s[:start]), "", false, nil
+	}
+	return strings.TrimSpace(s[:start]), amount[0], total, nil
+}
+
+func parseAmount(s string) (*plaintext.Amount, error) {
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 0:
+		return nil, nil
+	case 2:
+		q, err := decimal.NewFromString(strings.ReplaceAll(fields[0], ",", ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", fields[0])
+		}
+		return &plaintext.Amount{Quantity: q, Commodity: fields[1]}, nil
+	}
+	return nil, fmt.Errorf("invalid amount %q", s)
+}
+
+// perUnit parses a cost or price, which is converted to the price per unit
+// if it is given in total.
+func perUnit(s string, total bool, amount *plaintext.Amount) (*plaintext.Amount, error) {
+	if s == "" || amount == nil {
+		return nil, nil
+	}
+	res, err := parseAmount(s)
+	if err != nil || res == nil {
+		return nil, err
+	}
+	if total && !amount.Quantity.IsZero() {
+		res.Quantity = res.Quantity.Div(amount.Quantity.Abs())
+	}
+	return res, nil
+}
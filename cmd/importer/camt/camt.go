@@ -0,0 +1,383 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package camt
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"golang.org/x/text/encoding/htmlindex"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the cobra command.
+func CreateCmd() *cobra.Command {
+
+	var r runner
+
+	cmd := &cobra.Command{
+		Use:   "camt",
+		Short: "Import ISO 20022 camt.053 / camt.054 XML account statements",
+		Long: `Import account statements (camt.053) and debit / credit notifications (camt.054) in the
+ISO 20022 XML format, which is offered by most Swiss and European banks. Only booked entries are
+imported. Batched entries are split into their individual transactions if the bank provides the
+transaction amounts and they add up to the amount of the entry. The closing balance of a statement is imported as a balance assertion.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		Run: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+type runner struct {
+	accountFlag flags.AccountFlag
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.accountFlag, "account", "a", "account name")
+	cmd.MarkFlagRequired("account")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) {
+	if err := r.runE(cmd, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func (r *runner) runE(cmd *cobra.Command, args []string) error {
+	var (
+		reader *bufio.Reader
+		reg    = registry.New()
+		err    error
+	)
+	if reader, err = flags.OpenFile(args[0]); err != nil {
+		return err
+	}
+	p := Parser{
+		registry: reg,
+		builder:  journal.New(),
+	}
+	if p.account, err = r.accountFlag.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if err = p.parse(reader); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return importer.Print(cmd, out, p.builder.Build())
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+// document is the subset of a camt.053 or camt.054 document which is
+// needed for the import. Element names are matched regardless of the
+// namespace, so that all versions of the schema are supported.
+type document struct {
+	Statements    []statement `xml:"BkToCstmrStmt>Stmt"`
+	Notifications []statement `xml:"BkToCstmrDbtCdtNtfctn>Ntfctn"`
+}
+
+type statement struct {
+	Balances []balance `xml:"Bal"`
+	Entries  []entry   `xml:"Ntry"`
+}
+
+type balance struct {
+	Type      string `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    amount `xml:"Amt"`
+	CdtDbtInd string `xml:"CdtDbtInd"`
+	Date      date   `xml:"Dt"`
+}
+
+type entry struct {
+	Amount         amount     `xml:"Amt"`
+	CdtDbtInd      string     `xml:"CdtDbtInd"`
+	Status         status     `xml:"Sts"`
+	BookingDate    date       `xml:"BookgDt"`
+	AdditionalInfo string     `xml:"AddtlNtryInf"`
+	Details        []txDetail `xml:"NtryDtls>TxDtls"`
+}
+
+type txDetail struct {
+	Amount         *amount  `xml:"Amt"`
+	TxAmount       *amount  `xml:"AmtDtls>TxAmt>Amt"`
+	CdtDbtInd      string   `xml:"CdtDbtInd"`
+	Debtor         party    `xml:"RltdPties>Dbtr"`
+	Creditor       party    `xml:"RltdPties>Cdtr"`
+	Unstructured   []string `xml:"RmtInf>Ustrd"`
+	Reference      []string `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	AdditionalInfo string   `xml:"AddtlTxInf"`
+}
+
+// party holds the name of a party. Versions 8 and later of the schema nest
+// the name in a Pty element.
+type party struct {
+	Name    string `xml:"Nm"`
+	PtyName string `xml:"Pty>Nm"`
+}
+
+func (p party) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.PtyName
+}
+
+type amount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// status is a plain string in older versions of the schema and a code
+// element in newer ones.
+type status struct {
+	Value string `xml:",chardata"`
+	Code  string `xml:"Cd"`
+}
+
+func (s status) booked() bool {
+	return strings.TrimSpace(s.Value) == "BOOK" || s.Code == "BOOK"
+}
+
+type date struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+func (d date) parse() (time.Time, error) {
+	s := d.Date
+	if s == "" && len(d.DateTime) >= 10 {
+		s = d.DateTime[:10]
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// Parser is a parser for camt.053 and camt.054 documents.
+type Parser struct {
+	registry *model.Registry
+	account  *model.Account
+	builder  *journal.Builder
+}
+
+func (p *Parser) parse(r io.Reader) error {
+	var doc document
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(label)
+		if err != nil {
+			return nil, err
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+	if len(doc.Statements) == 0 && len(doc.Notifications) == 0 {
+		return fmt.Errorf("no camt.053 statements or camt.054 notifications found")
+	}
+	for _, stmt := range append(doc.Statements, doc.Notifications...) {
+		for _, e := range stmt.Entries {
+			if err := p.parseEntry(e); err != nil {
+				return err
+			}
+		}
+		for _, b := range stmt.Balances {
+			if err := p.parseBalance(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Parser) parseEntry(e entry) error {
+	if !e.Status.booked() {
+		return nil
+	}
+	d, err := e.BookingDate.parse()
+	if err != nil {
+		return fmt.Errorf("invalid booking date: %w", err)
+	}
+	if !p.isBatch(e) {
+		var txd txDetail
+		if len(e.Details) == 1 {
+			txd = e.Details[0]
+		}
+		return p.addTransaction(d, e.Amount, e.CdtDbtInd, description(e, txd))
+	}
+	for _, txd := range e.Details {
+		amt, ind := *txd.amount(), txd.CdtDbtInd
+		if ind == "" {
+			ind = e.CdtDbtInd
+		}
+		if err := p.addTransaction(d, amt, ind, description(e, txd)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBatch returns true if the entry must be split into its transactions.
+// This is only possible if the bank reports amounts for all of them, and
+// if they add up to the amount of the entry. Otherwise, e.g. if the entry
+// includes fees, the entry is booked as a whole.
+func (p *Parser) isBatch(e entry) bool {
+	if len(e.Details) < 2 {
+		return false
+	}
+	commodity, total, err := p.parseAmount(e.Amount, e.CdtDbtInd)
+	if err != nil {
+		return false
+	}
+	for _, txd := range e.Details {
+		amt := txd.amount()
+		if amt == nil {
+			return false
+		}
+		ind := txd.CdtDbtInd
+		if ind == "" {
+			ind = e.CdtDbtInd
+		}
+		c, quantity, err := p.parseAmount(*amt, ind)
+		if err != nil || c != commodity {
+			return false
+		}
+		total = total.Sub(quantity)
+	}
+	return total.IsZero()
+}
+
+func (txd txDetail) amount() *amount {
+	if txd.Amount != nil {
+		return txd.Amount
+	}
+	return txd.TxAmount
+}
+
+// description uses the counterparty name and the remittance information of
+// the transaction, and falls back to the additional entry information.
+func description(e entry, txd txDetail) string {
+	var ss []string
+	ind, counterparty := txd.CdtDbtInd, txd.Creditor
+	if ind == "" {
+		ind = e.CdtDbtInd
+	}
+	if ind == "CRDT" {
+		counterparty = txd.Debtor
+	}
+	ss = append(ss, counterparty.name())
+	ss = append(ss, txd.Unstructured...)
+	ss = append(ss, txd.Reference...)
+	res := join(ss)
+	if res == "" {
+		res = join([]string{txd.AdditionalInfo})
+	}
+	if res == "" {
+		res = join([]string{e.AdditionalInfo})
+	}
+	return res
+}
+
+func join(ss []string) string {
+	var res []string
+	for _, s := range ss {
+		if s = strings.Join(strings.Fields(s), " "); s != "" {
+			res = append(res, s)
+		}
+	}
+	return strings.Join(res, " ")
+}
+
+func (p *Parser) addTransaction(d time.Time, amt amount, ind string, desc string) error {
+	commodity, quantity, err := p.parseAmount(amt, ind)
+	if err != nil {
+		return err
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        d,
+		Description: desc,
+		Postings: posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: commodity,
+			Quantity:  quantity,
+		}.Build(),
+	}.Build())
+	return nil
+}
+
+func (p *Parser) parseBalance(b balance) error {
+	if b.Type != "CLBD" {
+		return nil
+	}
+	d, err := b.Date.parse()
+	if err != nil {
+		return fmt.Errorf("invalid balance date: %w", err)
+	}
+	commodity, quantity, err := p.parseAmount(b.Amount, b.CdtDbtInd)
+	if err != nil {
+		return err
+	}
+	p.builder.Add(&model.Assertion{
+		Date: d,
+		Balances: []model.Balance{
+			{
+				Account:   p.account,
+				Commodity: commodity,
+				Quantity:  quantity,
+			},
+		},
+	})
+	return nil
+}
+
+func (p *Parser) parseAmount(amt amount, ind string) (*model.Commodity, decimal.Decimal, error) {
+	commodity, err := p.registry.Commodities().Get(amt.Currency)
+	if err != nil {
+		return nil, decimal.Zero, fmt.Errorf("invalid currency %q: %w", amt.Currency, err)
+	}
+	quantity, err := decimal.NewFromString(strings.TrimSpace(amt.Value))
+	if err != nil {
+		return nil, decimal.Zero, fmt.Errorf("invalid amount %q: %w", amt.Value, err)
+	}
+	switch ind {
+	case "CRDT":
+	case "DBIT":
+		quantity = quantity.Neg()
+	default:
+		return nil, decimal.Zero, fmt.Errorf("invalid credit / debit indicator %q", ind)
+	}
+	return commodity, quantity, nil
+}
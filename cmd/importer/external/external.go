@@ -0,0 +1,193 @@
+package external
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+// Prefix is the prefix of the names of external importer executables.
+const Prefix = "knut-import-"
+
+// Discover returns the external importers on PATH, by name. If several
+// executables have the same name, the first one on PATH is used.
+func Discover() map[string]string {
+	res := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := importerName(e.Name())
+			if !ok || e.IsDir() {
+				continue
+			}
+			if _, ok := res[name]; ok {
+				continue
+			}
+			p := filepath.Join(dir, e.Name())
+			if !isExecutable(p) {
+				continue
+			}
+			res[name] = p
+		}
+	}
+	return res
+}
+
+func importerName(file string) (string, bool) {
+	if runtime.GOOS == "windows" {
+		file = strings.TrimSuffix(strings.ToLower(file), ".exe")
+	}
+	name, ok := strings.CutPrefix(file, Prefix)
+	return name, ok && name != ""
+}
+
+func isExecutable(p string) bool {
+	info, err := os.Stat(p)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode()&0111 != 0
+}
+
+// CreateCmds creates commands for the external importers on PATH, except
+// for the given names of built-in importers.
+func CreateCmds(builtin map[string]bool) []*cobra.Command {
+	importers := Discover()
+	names := make([]string, 0, len(importers))
+	for name := range importers {
+		if !builtin[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var res []*cobra.Command
+	for _, name := range names {
+		res = append(res, CreateCmd(name, importers[name]))
+	}
+	return res
+}
+
+// CreateCmd creates the command for an external importer.
+func CreateCmd(name, executable string) *cobra.Command {
+	r := runner{executable: executable}
+	return &cobra.Command{
+		Use:   name,
+		Short: fmt.Sprintf("Import using the external importer %s", filepath.Base(executable)),
+		Long: fmt.Sprintf(`Import using the external importer %s.
+
+All arguments are passed to the importer, except for the flags of knut import
+(e.g. --journal or --output). Arguments after -- are always passed to the importer.
+The importer must print a JSON document to stdout, in the following format:
+
+{
+  "version": 1,
+  "transactions": [{
+    "date": "2023-05-01",
+    "description": "Salary",
+    "postings": [{"credit": "Income:Salary", "debit": "Assets:Bank", "amount": "5000", "commodity": "CHF"}]
+  }],
+  "assertions": [{"date": "2023-05-31", "account": "Assets:Bank", "amount": "5000", "commodity": "CHF"}],
+  "prices": [{"date": "2023-05-31", "commodity": "USD", "price": "0.9", "target": "CHF"}]
+}
+
+Omitted accounts of postings are replaced by Expenses:TBD.`, executable),
+		DisableFlagParsing: true,
+		Run:                r.run,
+	}
+}
+
+type runner struct {
+	executable string
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) {
+	if err := r.runE(cmd, args); err != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		os.Exit(1)
+	}
+}
+
+func (r *runner) runE(cmd *cobra.Command, args []string) error {
+	args, err := parseImportFlags(cmd, args)
+	if err != nil {
+		return err
+	}
+	var stdout bytes.Buffer
+	c := exec.CommandContext(cmd.Context(), r.executable, args...)
+	c.Stdin = cmd.InOrStdin()
+	c.Stdout = &stdout
+	c.Stderr = cmd.ErrOrStderr()
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(r.executable), err)
+	}
+	j, err := Decode(registry.New(), &stdout)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(r.executable), err)
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return importer.Print(cmd, out, j)
+}
+
+// parseImportFlags sets the inherited flags of the import command and
+// returns the remaining arguments, which are passed to the importer.
+func parseImportFlags(cmd *cobra.Command, args []string) ([]string, error) {
+	flags := cmd.InheritedFlags()
+	var res []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(res, args[i+1:]...), nil
+		}
+		var (
+			f            *pflag.Flag
+			value        string
+			hasValue     bool
+			name, prefix string
+		)
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue = strings.Cut(arg[2:], "=")
+			f, prefix = flags.Lookup(name), "--"
+		case strings.HasPrefix(arg, "-") && len(arg) >= 2:
+			name, value = arg[1:2], arg[2:]
+			hasValue = value != ""
+			if name != "" {
+				f, prefix = flags.ShorthandLookup(name), "-"
+			}
+		}
+		if f == nil {
+			res = append(res, arg)
+			continue
+		}
+		if !hasValue {
+			if f.NoOptDefVal != "" {
+				value = f.NoOptDefVal
+			} else if i+1 < len(args) && args[i+1] != "--" {
+				i++
+				value = args[i]
+			} else {
+				return nil, fmt.Errorf("flag needs an argument: %s%s", prefix, name)
+			}
+		}
+		if err := flags.Set(f.Name, value); err != nil {
+			return nil, fmt.Errorf("invalid argument %q for %s%s: %w", value, prefix, name, err)
+		}
+	}
+	return res, nil
+}
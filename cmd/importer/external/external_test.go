@@ -0,0 +1,143 @@
+package external
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sebdah/goldie/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/cmdtest"
+	"github.com/sboehler/knut/cmd/importer"
+)
+
+func TestParseImportFlags(t *testing.T) {
+	tests := []struct {
+		desc       string
+		args, want []string
+		output     string
+		append     bool
+	}{
+		{
+			desc: "no flags",
+			args: []string{"--account", "Assets:Bank", "file.csv"},
+			want: []string{"--account", "Assets:Bank", "file.csv"},
+		},
+		{
+			desc:   "import flags",
+			args:   []string{"-o", "out.knut", "--append", "file.csv"},
+			want:   []string{"file.csv"},
+			output: "out.knut",
+			append: true,
+		},
+		{
+			desc:   "values",
+			args:   []string{"--output=out.knut", "-x", "file.csv"},
+			want:   []string{"-x", "file.csv"},
+			output: "out.knut",
+		},
+		{
+			desc:   "shorthand with value",
+			args:   []string{"-oout.knut"},
+			output: "out.knut",
+		},
+		{
+			desc:   "terminator",
+			args:   []string{"-o", "out.knut", "--", "--output", "file.csv"},
+			want:   []string{"--output", "file.csv"},
+			output: "out.knut",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cmd := createTestCmd("/bin/false")
+
+			got, err := parseImportFlags(cmd, test.args)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Fatalf("unexpected diff (-want, +got):\n%s", diff)
+			}
+			if output, _ := cmd.InheritedFlags().GetString("output"); output != test.output {
+				t.Errorf("output = %q, want %q", output, test.output)
+			}
+			if appendTo, _ := cmd.InheritedFlags().GetBool("append"); appendTo != test.append {
+				t.Errorf("append = %t, want %t", appendTo, test.append)
+			}
+		})
+	}
+}
+
+func TestParseImportFlagsMissingValue(t *testing.T) {
+	for _, args := range [][]string{{"-o"}, {"--output", "--", "file.csv"}} {
+		cmd := createTestCmd("/bin/false")
+
+		if _, err := parseImportFlags(cmd, args); err == nil {
+			t.Errorf("parseImportFlags(%v) returned no error", args)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeScript(t, dir1, Prefix+"bank", "")
+	writeScript(t, dir2, Prefix+"bank", "")
+	writeScript(t, dir2, Prefix+"broker", "")
+	if err := os.WriteFile(filepath.Join(dir2, Prefix+"data"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir1+string(filepath.ListSeparator)+dir2)
+
+	got := Discover()
+
+	want := map[string]string{
+		"bank":   filepath.Join(dir1, Prefix+"bank"),
+		"broker": filepath.Join(dir2, Prefix+"broker"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	example, err := filepath.Abs("testdata/example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	exe := writeScript(t, dir, Prefix+"example", `[ "$*" = "--account Assets:Bank file.csv" ] || exit 1
+cat "`+example+`"`)
+	cmd := createTestCmd(exe)
+
+	got := cmdtest.Run(t, cmd.Root(), "example", "--account", "Assets:Bank", "--similarity", "0.5", "file.csv")
+
+	goldie.New(t).Assert(t, "example", got)
+}
+
+func createTestCmd(exe string) *cobra.Command {
+	parent := &cobra.Command{Use: "import"}
+	importer.SetupFlags(parent)
+	cmd := CreateCmd("example", exe)
+	parent.AddCommand(cmd)
+	return cmd
+}
+
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
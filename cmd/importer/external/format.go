@@ -0,0 +1,205 @@
+package external
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// Version is the version of the interchange format.
+const Version = 1
+
+// Document is the output of an external importer.
+type Document struct {
+	// Version is the version of the format. If omitted, the current
+	// version is assumed.
+	Version      int           `json:"version,omitempty"`
+	Transactions []Transaction `json:"transactions,omitempty"`
+	Assertions   []Assertion   `json:"assertions,omitempty"`
+	Prices       []Price       `json:"prices,omitempty"`
+}
+
+// Transaction is a transaction with one or more bookings.
+type Transaction struct {
+	Date        string    `json:"date"`
+	Description string    `json:"description"`
+	Postings    []Posting `json:"postings"`
+}
+
+// Posting books an amount from the credit account to the debit account.
+// An omitted account is replaced by the TBD account.
+type Posting struct {
+	Credit    string          `json:"credit,omitempty"`
+	Debit     string          `json:"debit,omitempty"`
+	Amount    decimal.Decimal `json:"amount"`
+	Commodity string          `json:"commodity"`
+}
+
+// Assertion asserts the balance of an account at the end of a day.
+type Assertion struct {
+	Date      string          `json:"date"`
+	Account   string          `json:"account"`
+	Amount    decimal.Decimal `json:"amount"`
+	Commodity string          `json:"commodity"`
+}
+
+// Price is the price of a commodity in terms of the target commodity.
+type Price struct {
+	Date      string          `json:"date"`
+	Commodity string          `json:"commodity"`
+	Price     decimal.Decimal `json:"price"`
+	Target    string          `json:"target"`
+}
+
+// Decode reads a document and validates it with the registry.
+func Decode(reg *model.Registry, r io.Reader) (*journal.Journal, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var doc Document
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	if doc.Version != 0 && doc.Version != Version {
+		return nil, fmt.Errorf("unsupported version %d, want %d", doc.Version, Version)
+	}
+	j := journal.New()
+	for i, t := range doc.Transactions {
+		trx, err := t.build(reg)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i+1, err)
+		}
+		j.Add(trx)
+	}
+	for i, a := range doc.Assertions {
+		assertion, err := a.build(reg)
+		if err != nil {
+			return nil, fmt.Errorf("assertion %d: %w", i+1, err)
+		}
+		j.Add(assertion)
+	}
+	for i, p := range doc.Prices {
+		price, err := p.build(reg)
+		if err != nil {
+			return nil, fmt.Errorf("price %d: %w", i+1, err)
+		}
+		j.Add(price)
+	}
+	return j.Build(), nil
+}
+
+func (t Transaction) build(reg *model.Registry) (*model.Transaction, error) {
+	date, err := parseDate(t.Date)
+	if err != nil {
+		return nil, err
+	}
+	if len(t.Postings) == 0 {
+		return nil, fmt.Errorf("no postings")
+	}
+	var builders posting.Builders
+	for i, p := range t.Postings {
+		b, err := p.builder(reg)
+		if err != nil {
+			return nil, fmt.Errorf("posting %d: %w", i+1, err)
+		}
+		builders = append(builders, b)
+	}
+	return transaction.Builder{
+		Date:        date,
+		Description: t.Description,
+		Postings:    builders.Build(),
+	}.Build(), nil
+}
+
+func (p Posting) builder(reg *model.Registry) (posting.Builder, error) {
+	credit, err := account(reg, p.Credit)
+	if err != nil {
+		return posting.Builder{}, err
+	}
+	debit, err := account(reg, p.Debit)
+	if err != nil {
+		return posting.Builder{}, err
+	}
+	if credit == debit {
+		return posting.Builder{}, fmt.Errorf("credit and debit account are both %s", credit.Name())
+	}
+	commodity, err := reg.Commodities().Get(p.Commodity)
+	if err != nil {
+		return posting.Builder{}, err
+	}
+	return posting.Builder{
+		Credit:    credit,
+		Debit:     debit,
+		Commodity: commodity,
+		Quantity:  p.Amount,
+	}, nil
+}
+
+func (a Assertion) build(reg *model.Registry) (*model.Assertion, error) {
+	date, err := parseDate(a.Date)
+	if err != nil {
+		return nil, err
+	}
+	account, err := reg.Accounts().Get(a.Account)
+	if err != nil {
+		return nil, err
+	}
+	commodity, err := reg.Commodities().Get(a.Commodity)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Assertion{
+		Date: date,
+		Balances: []model.Balance{{
+			Account:   account,
+			Commodity: commodity,
+			Quantity:  a.Amount,
+		}},
+	}, nil
+}
+
+func (p Price) build(reg *model.Registry) (*model.Price, error) {
+	date, err := parseDate(p.Date)
+	if err != nil {
+		return nil, err
+	}
+	commodity, err := reg.Commodities().Get(p.Commodity)
+	if err != nil {
+		return nil, err
+	}
+	target, err := reg.Commodities().Get(p.Target)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Price.IsPositive() {
+		return nil, fmt.Errorf("invalid price %s", p.Price)
+	}
+	return &model.Price{
+		Date:      date,
+		Commodity: commodity,
+		Price:     p.Price,
+		Target:    target,
+	}, nil
+}
+
+func account(reg *model.Registry, name string) (*model.Account, error) {
+	if name == "" {
+		return reg.Accounts().TBDAccount(), nil
+	}
+	return reg.Accounts().Get(name)
+}
+
+func parseDate(s string) (time.Time, error) {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return d, fmt.Errorf("invalid date %q, want YYYY-MM-DD", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,83 @@
+package external
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sebdah/goldie/v2"
+
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+func TestDecode(t *testing.T) {
+	f, err := os.Open("testdata/example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	j, err := Decode(registry.New(), f)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := journal.Print(&got, j); err != nil {
+		t.Fatal(err)
+	}
+	goldie.New(t).Assert(t, "example", got.Bytes())
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		desc, input, want string
+	}{
+		{
+			desc:  "unknown field",
+			input: `{"transactions": [], "foo": 1}`,
+			want:  `invalid document: json: unknown field "foo"`,
+		},
+		{
+			desc:  "unsupported version",
+			input: `{"version": 2}`,
+			want:  "unsupported version 2, want 1",
+		},
+		{
+			desc:  "invalid date",
+			input: `{"transactions": [{"date": "01.05.2023", "postings": [{"credit": "Assets:Bank", "amount": "1", "commodity": "CHF"}]}]}`,
+			want:  `transaction 1: invalid date "01.05.2023", want YYYY-MM-DD`,
+		},
+		{
+			desc:  "no postings",
+			input: `{"transactions": [{"date": "2023-05-01"}]}`,
+			want:  "transaction 1: no postings",
+		},
+		{
+			desc:  "invalid account",
+			input: `{"transactions": [{"date": "2023-05-01", "postings": [{"credit": "Bank", "amount": "1", "commodity": "CHF"}]}]}`,
+			want:  "transaction 1: posting 1: ",
+		},
+		{
+			desc:  "same accounts",
+			input: `{"transactions": [{"date": "2023-05-01", "postings": [{"credit": "Assets:Bank", "debit": "Assets:Bank", "amount": "1", "commodity": "CHF"}]}]}`,
+			want:  "transaction 1: posting 1: credit and debit account are both Assets:Bank",
+		},
+		{
+			desc:  "invalid price",
+			input: `{"prices": [{"date": "2023-05-01", "commodity": "USD", "price": "0", "target": "CHF"}]}`,
+			want:  "price 1: invalid price 0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			_, err := Decode(registry.New(), strings.NewReader(test.input))
+
+			if err == nil || !strings.HasPrefix(err.Error(), test.want) {
+				t.Fatalf("Decode() returned error %v, want %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generic
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config describes the layout of a CSV account statement.
+type Config struct {
+	// Delimiter is the field delimiter, defaults to ",".
+	Delimiter string `yaml:"delimiter"`
+	// Encoding is the character encoding, for example windows-1252 or
+	// iso-8859-1. Defaults to utf-8. A byte order mark is always skipped.
+	Encoding string `yaml:"encoding"`
+	// Skip is the number of rows to skip before the header row.
+	Skip int `yaml:"skip"`
+	// Header indicates whether the file has a header row. Columns can
+	// then be referred to by name.
+	Header bool `yaml:"header"`
+
+	// DateFormat is the layout of dates, in the format of the time
+	// package. Defaults to "2006-01-02".
+	DateFormat string `yaml:"date_format"`
+	// DecimalSeparator defaults to ".". ThousandsSeparators are removed
+	// from amounts.
+	DecimalSeparator    string `yaml:"decimal_separator"`
+	ThousandsSeparators string `yaml:"thousands_separators"`
+
+	Columns Columns `yaml:"columns"`
+
+	// Currency is the currency of all amounts, unless there is a
+	// currency column.
+	Currency string `yaml:"currency"`
+}
+
+// Columns maps fields to columns. Either Amount or Debit and Credit must
+// be given. Amounts in the Debit column are booked as outflows, regardless
+// of their sign.
+type Columns struct {
+	Date        Column   `yaml:"date"`
+	Description []Column `yaml:"description"`
+	Amount      Column   `yaml:"amount"`
+	Debit       Column   `yaml:"debit"`
+	Credit      Column   `yaml:"credit"`
+	Currency    Column   `yaml:"currency"`
+	Balance     Column   `yaml:"balance"`
+}
+
+// Column is a column, given either by its zero-based index or by its
+// name in the header row.
+type Column struct {
+	Index int
+	Name  string
+	set   bool
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *Column) UnmarshalYAML(unmarshal func(any) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	if i, err := strconv.Atoi(s); err == nil {
+		*c = Column{Index: i, set: true}
+	} else {
+		*c = Column{Name: s, set: true}
+	}
+	return nil
+}
+
+// IsSet returns whether the column is configured.
+func (c Column) IsSet() bool {
+	return c.set
+}
+
+// ReadConfig reads a configuration from a YAML file.
+func ReadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.SetStrict(true)
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (cfg *Config) validate() error {
+	if cfg.Delimiter == "" {
+		cfg.Delimiter = ","
+	}
+	if len([]rune(cfg.Delimiter)) != 1 {
+		return fmt.Errorf("invalid delimiter %q", cfg.Delimiter)
+	}
+	if cfg.DateFormat == "" {
+		cfg.DateFormat = "2006-01-02"
+	}
+	if cfg.DecimalSeparator == "" {
+		cfg.DecimalSeparator = "."
+	}
+	if !cfg.Columns.Date.IsSet() {
+		return fmt.Errorf("missing date column")
+	}
+	if cfg.Columns.Amount.IsSet() == (cfg.Columns.Debit.IsSet() || cfg.Columns.Credit.IsSet()) {
+		return fmt.Errorf("either an amount column or debit and credit columns are required")
+	}
+	if cfg.Columns.Debit.IsSet() != cfg.Columns.Credit.IsSet() {
+		return fmt.Errorf("debit and credit columns must be given together")
+	}
+	if cfg.Currency == "" && !cfg.Columns.Currency.IsSet() {
+		return fmt.Errorf("either a currency or a currency column is required")
+	}
+	for _, c := range cfg.columns() {
+		if c.Name != "" && !cfg.Header {
+			return fmt.Errorf("column %q requires a header row", c.Name)
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) columns() []Column {
+	cs := []Column{
+		cfg.Columns.Date,
+		cfg.Columns.Amount,
+		cfg.Columns.Debit,
+		cfg.Columns.Credit,
+		cfg.Columns.Currency,
+		cfg.Columns.Balance,
+	}
+	return append(cs, cfg.Columns.Description...)
+}
@@ -0,0 +1,325 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generic
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dimchansky/utfbom"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"golang.org/x/text/encoding/htmlindex"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the cobra command.
+func CreateCmd() *cobra.Command {
+
+	var r runner
+
+	cmd := &cobra.Command{
+		Use:   "csv",
+		Short: "Import CSV account statements using a configuration file",
+		Long: `Import CSV account statements of any bank. The layout of the file is described in a YAML
+configuration file:
+
+  delimiter: ";"                # field delimiter, default ","
+  encoding: windows-1252        # character encoding, default utf-8
+  skip: 3                       # rows to skip before the header
+  header: true                  # whether there is a header row
+  date_format: "02.01.2006"     # Go time layout, default 2006-01-02
+  decimal_separator: ","        # default "."
+  thousands_separators: "'"     # characters to remove from amounts
+  currency: CHF                 # currency, unless there is a currency column
+  columns:                      # columns by zero-based index or by header name
+    date: Date
+    description: [Text, 5]
+    amount: Amount              # or debit and credit columns
+    currency: Currency
+    balance: Balance            # emits a balance assertion per day`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		Run: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+type runner struct {
+	accountFlag flags.AccountFlag
+	config      string
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.accountFlag, "account", "a", "account name")
+	cmd.Flags().StringVarP(&r.config, "config", "c", "", "configuration file")
+	cmd.MarkFlagRequired("account")
+	cmd.MarkFlagRequired("config")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) {
+	if err := r.runE(cmd, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func (r *runner) runE(cmd *cobra.Command, args []string) error {
+	var (
+		reader *bufio.Reader
+		reg    = registry.New()
+		err    error
+	)
+	cfg, err := ReadConfig(r.config)
+	if err != nil {
+		return err
+	}
+	if reader, err = flags.OpenFile(args[0]); err != nil {
+		return err
+	}
+	decoded, err := decode(cfg.Encoding, utfbom.SkipOnly(reader))
+	if err != nil {
+		return err
+	}
+	p := Parser{
+		config:   cfg,
+		registry: reg,
+		reader:   csv.NewReader(decoded),
+		builder:  journal.New(),
+	}
+	if p.account, err = r.accountFlag.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if err = p.parse(); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return importer.Print(cmd, out, p.builder.Build())
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+func decode(encoding string, r io.Reader) (io.Reader, error) {
+	if encoding == "" {
+		return r, nil
+	}
+	enc, err := htmlindex.Get(encoding)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q: %w", encoding, err)
+	}
+	return enc.NewDecoder().Reader(r), nil
+}
+
+// Parser is a parser for account statements
+type Parser struct {
+	config   *Config
+	registry *model.Registry
+	reader   *csv.Reader
+	account  *model.Account
+	builder  *journal.Builder
+
+	currency *model.Commodity
+	header   map[string]int
+	balances *importer.Balances
+}
+
+func (p *Parser) parse() error {
+	p.reader.LazyQuotes = true
+	p.reader.TrimLeadingSpace = true
+	p.reader.Comma = []rune(p.config.Delimiter)[0]
+	p.reader.FieldsPerRecord = -1
+	p.balances = importer.NewBalances(p.account)
+
+	if p.config.Currency != "" {
+		c, err := p.registry.Commodities().Get(p.config.Currency)
+		if err != nil {
+			return err
+		}
+		p.currency = c
+	}
+	for i := 0; i < p.config.Skip; i++ {
+		if _, err := p.reader.Read(); err != nil {
+			return fmt.Errorf("error skipping row %d: %w", i+1, err)
+		}
+	}
+	if p.config.Header {
+		if err := p.readHeader(); err != nil {
+			return err
+		}
+	}
+	for {
+		rec, err := p.reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := p.readBookingLine(rec); err != nil {
+			return err
+		}
+	}
+	for _, a := range p.balances.Assertions() {
+		p.builder.Add(a)
+	}
+	return nil
+}
+
+func (p *Parser) readHeader() error {
+	rec, err := p.reader.Read()
+	if err != nil {
+		return fmt.Errorf("error reading header: %w", err)
+	}
+	p.header = make(map[string]int)
+	for i, name := range rec {
+		p.header[strings.TrimSpace(name)] = i
+	}
+	for _, c := range p.config.columns() {
+		if _, ok := p.header[c.Name]; c.Name != "" && !ok {
+			return fmt.Errorf("column %q not found in header %v", c.Name, rec)
+		}
+	}
+	return nil
+}
+
+func (p *Parser) field(rec []string, c Column) (string, error) {
+	i := c.Index
+	if c.Name != "" {
+		i = p.header[c.Name]
+	}
+	if i < 0 || i >= len(rec) {
+		return "", fmt.Errorf("column %d not found in row %v", i, rec)
+	}
+	return strings.TrimSpace(rec[i]), nil
+}
+
+func (p *Parser) readBookingLine(rec []string) error {
+	if len(rec) == 1 && strings.TrimSpace(rec[0]) == "" {
+		return nil
+	}
+	s, err := p.field(rec, p.config.Columns.Date)
+	if err != nil {
+		return err
+	}
+	date, err := time.Parse(p.config.DateFormat, s)
+	if err != nil {
+		return fmt.Errorf("invalid date in row %v: %w", rec, err)
+	}
+	var desc []string
+	for _, c := range p.config.Columns.Description {
+		s, err := p.field(rec, c)
+		if err != nil {
+			return err
+		}
+		if s != "" {
+			desc = append(desc, s)
+		}
+	}
+	quantity, err := p.parseQuantity(rec)
+	if err != nil {
+		return fmt.Errorf("invalid amount in row %v: %w", rec, err)
+	}
+	commodity := p.currency
+	if p.config.Columns.Currency.IsSet() {
+		s, err := p.field(rec, p.config.Columns.Currency)
+		if err != nil {
+			return err
+		}
+		if commodity, err = p.registry.Commodities().Get(s); err != nil {
+			return fmt.Errorf("invalid currency in row %v: %w", rec, err)
+		}
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: strings.Join(desc, " "),
+		Postings: posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: commodity,
+			Quantity:  quantity,
+		}.Build(),
+	}.Build())
+	return p.recordBalance(rec, date, commodity, quantity)
+}
+
+func (p *Parser) parseQuantity(rec []string) (decimal.Decimal, error) {
+	cols := p.config.Columns
+	if cols.Amount.IsSet() {
+		s, err := p.field(rec, cols.Amount)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		return p.parseDecimal(s)
+	}
+	debit, err := p.field(rec, cols.Debit)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	credit, err := p.field(rec, cols.Credit)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	switch {
+	case len(credit) > 0 && len(debit) == 0:
+		q, err := p.parseDecimal(credit)
+		return q.Abs(), err
+	case len(credit) == 0 && len(debit) > 0:
+		q, err := p.parseDecimal(debit)
+		return q.Abs().Neg(), err
+	default:
+		return decimal.Zero, fmt.Errorf("invalid debit and credit fields %q %q", debit, credit)
+	}
+}
+
+func (p *Parser) parseDecimal(s string) (decimal.Decimal, error) {
+	for _, r := range p.config.ThousandsSeparators {
+		s = strings.ReplaceAll(s, string(r), "")
+	}
+	s = strings.ReplaceAll(s, p.config.DecimalSeparator, ".")
+	return decimal.NewFromString(strings.ReplaceAll(s, " ", ""))
+}
+
+func (p *Parser) recordBalance(rec []string, date time.Time, commodity *model.Commodity, quantity decimal.Decimal) error {
+	if !p.config.Columns.Balance.IsSet() {
+		return nil
+	}
+	s, err := p.field(rec, p.config.Columns.Balance)
+	if err != nil || s == "" {
+		return err
+	}
+	bal, err := p.parseDecimal(s)
+	if err != nil {
+		return fmt.Errorf("invalid balance in row %v: %w", rec, err)
+	}
+	p.balances.Add(date, commodity, quantity, bal)
+	return nil
+}
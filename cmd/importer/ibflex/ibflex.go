@@ -0,0 +1,683 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ibflex
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the cobra command.
+func CreateCmd() *cobra.Command {
+	var r runner
+	cmd := &cobra.Command{
+		Use:   "us.interactivebrokers.flex",
+		Short: "Import Interactive Brokers Flex Query XML reports",
+		Long: `In the account manager web UI, go to "Performance & Reports" > "Flex Queries" and create an
+Activity Flex Query with the sections Account Information, Trades (including closed lots), Cash
+Transactions, Corporate Actions, Open Positions and Cash Report. Select XML as the format.
+
+Stock splits are imported as split directives. Cost basis and realized P&L of closing trades
+are added to the transaction description.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	accountFlag, dividendFlag, taxFlag, feeFlag, interestFlag, tradingFlag flags.AccountFlag
+}
+
+func (r *runner) setupFlags(c *cobra.Command) {
+	c.Flags().VarP(&r.accountFlag, "account", "a", "account name")
+	c.Flags().VarP(&r.interestFlag, "interest", "i", "account name of the interest expense account")
+	c.Flags().VarP(&r.dividendFlag, "dividend", "d", "account name of the dividend account")
+	c.Flags().VarP(&r.taxFlag, "tax", "w", "account name of the withholding tax account")
+	c.Flags().VarP(&r.feeFlag, "fee", "f", "account name of the fee account")
+	c.Flags().VarP(&r.tradingFlag, "trading", "t", "account name of the trading gain / loss account")
+	c.MarkFlagRequired("account")
+	c.MarkFlagRequired("interest")
+	c.MarkFlagRequired("dividend")
+	c.MarkFlagRequired("trading")
+	c.MarkFlagRequired("tax")
+	c.MarkFlagRequired("fee")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) error {
+	var (
+		reg = registry.New()
+		err error
+	)
+	f, err := flags.OpenFile(args[0])
+	if err != nil {
+		return err
+	}
+	p := parser{
+		registry: reg,
+		builder:  journal.New(),
+	}
+	if p.account, err = r.accountFlag.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if p.interest, err = r.interestFlag.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if p.dividend, err = r.dividendFlag.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if p.tax, err = r.taxFlag.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if p.fee, err = r.feeFlag.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if p.trading, err = r.tradingFlag.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if err = p.parse(f); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return importer.Print(cmd, out, p.builder.Build())
+}
+
+type flexQueryResponse struct {
+	Statements []flexStatement `xml:"FlexStatements>FlexStatement"`
+}
+
+type flexStatement struct {
+	ToDate             string               `xml:"toDate,attr"`
+	AccountInformation accountInformation   `xml:"AccountInformation"`
+	Trades             []trade              `xml:"Trades>Trade"`
+	Lots               []trade              `xml:"Trades>Lot"`
+	CashTransactions   []cashTransaction    `xml:"CashTransactions>CashTransaction"`
+	CorporateActions   []corporateAction    `xml:"CorporateActions>CorporateAction"`
+	OpenPositions      []openPosition       `xml:"OpenPositions>OpenPosition"`
+	CashReport         []cashReportCurrency `xml:"CashReport>CashReportCurrency"`
+}
+
+type accountInformation struct {
+	Currency string `xml:"currency,attr"`
+}
+
+type trade struct {
+	TradeID            string `xml:"tradeID,attr"`
+	AssetCategory      string `xml:"assetCategory,attr"`
+	Currency           string `xml:"currency,attr"`
+	Symbol             string `xml:"symbol,attr"`
+	TradeDate          string `xml:"tradeDate,attr"`
+	DateTime           string `xml:"dateTime,attr"`
+	Quantity           string `xml:"quantity,attr"`
+	TradePrice         string `xml:"tradePrice,attr"`
+	Proceeds           string `xml:"proceeds,attr"`
+	Commission         string `xml:"ibCommission,attr"`
+	CommissionCurrency string `xml:"ibCommissionCurrency,attr"`
+	Cost               string `xml:"cost,attr"`
+	RealizedPnL        string `xml:"fifoPnlRealized,attr"`
+	OpenCloseIndicator string `xml:"openCloseIndicator,attr"`
+	OpenDateTime       string `xml:"openDateTime,attr"`
+	LevelOfDetail      string `xml:"levelOfDetail,attr"`
+}
+
+type cashTransaction struct {
+	TransactionID string `xml:"transactionID,attr"`
+	Type          string `xml:"type,attr"`
+	Currency      string `xml:"currency,attr"`
+	Symbol        string `xml:"symbol,attr"`
+	DateTime      string `xml:"dateTime,attr"`
+	SettleDate    string `xml:"settleDate,attr"`
+	Amount        string `xml:"amount,attr"`
+	Description   string `xml:"description,attr"`
+	LevelOfDetail string `xml:"levelOfDetail,attr"`
+}
+
+type corporateAction struct {
+	ActionID    string `xml:"actionID,attr"`
+	Type        string `xml:"type,attr"`
+	Currency    string `xml:"currency,attr"`
+	Symbol      string `xml:"symbol,attr"`
+	DateTime    string `xml:"dateTime,attr"`
+	ReportDate  string `xml:"reportDate,attr"`
+	Quantity    string `xml:"quantity,attr"`
+	Proceeds    string `xml:"proceeds,attr"`
+	Description string `xml:"description,attr"`
+}
+
+type openPosition struct {
+	Symbol        string `xml:"symbol,attr"`
+	Position      string `xml:"position,attr"`
+	ReportDate    string `xml:"reportDate,attr"`
+	LevelOfDetail string `xml:"levelOfDetail,attr"`
+}
+
+type cashReportCurrency struct {
+	Currency   string `xml:"currency,attr"`
+	EndingCash string `xml:"endingCash,attr"`
+	ToDate     string `xml:"toDate,attr"`
+}
+
+type parser struct {
+	registry     *model.Registry
+	builder      *journal.Builder
+	baseCurrency *model.Commodity
+
+	account, dividend, tax, fee, interest, trading *model.Account
+
+	// ids holds the IDs of the imported records, as overlapping
+	// statements can contain the same records.
+	ids set.Set[string]
+}
+
+func (p *parser) parse(r io.Reader) error {
+	var resp flexQueryResponse
+	if err := xml.NewDecoder(r).Decode(&resp); err != nil {
+		return err
+	}
+	if len(resp.Statements) == 0 {
+		return fmt.Errorf("no flex statements found")
+	}
+	p.ids = set.New[string]()
+	for _, stmt := range resp.Statements {
+		if err := p.parseStatement(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseStatement(stmt flexStatement) error {
+	var err error
+	if p.baseCurrency, err = p.registry.Commodities().Get(stmt.AccountInformation.Currency); err != nil {
+		return fmt.Errorf("invalid base currency: %w", err)
+	}
+	lots := make(map[string][]trade)
+	for _, l := range stmt.Lots {
+		lots[l.TradeID] = append(lots[l.TradeID], l)
+	}
+	// older flex queries report closed lots as trades
+	for _, t := range stmt.Trades {
+		if t.LevelOfDetail == "CLOSED_LOT" {
+			lots[t.TradeID] = append(lots[t.TradeID], t)
+		}
+	}
+	for _, t := range stmt.Trades {
+		if t.LevelOfDetail != "" && t.LevelOfDetail != "EXECUTION" {
+			continue
+		}
+		if p.isDuplicate("trade", t.TradeID) {
+			continue
+		}
+		if t.AssetCategory == "CASH" {
+			err = p.parseForex(t)
+		} else {
+			err = p.parseTrade(t, lots[t.TradeID])
+		}
+		if err != nil {
+			return fmt.Errorf("trade %s: %w", t.TradeID, err)
+		}
+	}
+	for _, ct := range stmt.CashTransactions {
+		if ct.LevelOfDetail != "" && ct.LevelOfDetail != "DETAIL" {
+			continue
+		}
+		if p.isDuplicate("cash", ct.TransactionID) {
+			continue
+		}
+		if err := p.parseCashTransaction(ct); err != nil {
+			return fmt.Errorf("cash transaction %s: %w", ct.TransactionID, err)
+		}
+	}
+	for _, ca := range stmt.CorporateActions {
+		if p.isDuplicate("action", ca.ActionID) {
+			continue
+		}
+		if err := p.parseCorporateAction(ca); err != nil {
+			return fmt.Errorf("corporate action %s: %w", ca.ActionID, err)
+		}
+	}
+	date, err := parseDate(stmt.ToDate)
+	if err != nil {
+		return err
+	}
+	if err := p.createAssertions(date, stmt.OpenPositions); err != nil {
+		return err
+	}
+	return p.createCurrencyAssertions(date, stmt.CashReport)
+}
+
+func (p *parser) isDuplicate(kind, id string) bool {
+	if id == "" {
+		return false
+	}
+	key := kind + ":" + id
+	if p.ids.Has(key) {
+		return true
+	}
+	p.ids.Add(key)
+	return false
+}
+
+func (p *parser) parseTrade(t trade, lots []trade) error {
+	var (
+		currency, stock                 *model.Commodity
+		date                            time.Time
+		qty, price, proceeds, fee, cost decimal.Decimal
+		desc                            string
+		err                             error
+	)
+	if currency, err = p.registry.Commodities().Get(t.Currency); err != nil {
+		return err
+	}
+	if stock, err = p.commodity(t.Symbol); err != nil {
+		return err
+	}
+	if date, err = parseDate(tradeDate(t)); err != nil {
+		return err
+	}
+	if qty, err = parseDecimal(t.Quantity); err != nil {
+		return err
+	}
+	if price, err = parseDecimal(t.TradePrice); err != nil {
+		return err
+	}
+	if proceeds, err = parseDecimal(t.Proceeds); err != nil {
+		return err
+	}
+	if fee, err = parseOptionalDecimal(t.Commission); err != nil {
+		return err
+	}
+	feeCurrency, err := p.commissionCurrency(t, currency)
+	if err != nil {
+		return err
+	}
+	if qty.IsPositive() {
+		desc = fmt.Sprintf("Buy %s %s @ %s %s", qty, stock.Name(), price, currency.Name())
+	} else {
+		desc = fmt.Sprintf("Sell %s %s @ %s %s", qty, stock.Name(), price, currency.Name())
+	}
+	if strings.Contains(t.OpenCloseIndicator, "C") {
+		if cost, err = parseOptionalDecimal(t.Cost); err != nil {
+			return err
+		}
+		desc = fmt.Sprintf("%s (cost basis %s %s, realized P&L %s %s%s)", desc, cost.Abs(), currency.Name(), t.RealizedPnL, currency.Name(), describeLots(lots))
+	}
+	postings := posting.Builders{
+		{
+			Credit:    p.trading,
+			Debit:     p.account,
+			Commodity: stock,
+			Quantity:  qty,
+		},
+		{
+			Credit:    p.trading,
+			Debit:     p.account,
+			Commodity: currency,
+			Quantity:  proceeds,
+		},
+	}
+	if !fee.IsZero() {
+		postings = append(postings, posting.Builder{
+			Credit:    p.fee,
+			Debit:     p.account,
+			Commodity: feeCurrency,
+			Quantity:  fee,
+		})
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: desc,
+		Postings:    postings.Build(),
+		Targets:     []*model.Commodity{stock, currency},
+	}.Build())
+	return nil
+}
+
+// describeLots describes the lots closed by a trade.
+func describeLots(lots []trade) string {
+	var ss []string
+	for _, l := range lots {
+		d, err := parseDate(l.OpenDateTime)
+		if err != nil {
+			continue
+		}
+		ss = append(ss, fmt.Sprintf("%s opened %s", strings.TrimPrefix(l.Quantity, "-"), d.Format("2006-01-02")))
+	}
+	if len(ss) == 0 {
+		return ""
+	}
+	return ", lots: " + strings.Join(ss, ", ")
+}
+
+func (p *parser) parseForex(t trade) error {
+	var (
+		currency, stock           *model.Commodity
+		date                      time.Time
+		desc                      string
+		qty, price, proceeds, fee decimal.Decimal
+		err                       error
+	)
+	if currency, err = p.registry.Commodities().Get(t.Currency); err != nil {
+		return err
+	}
+	if stock, err = p.registry.Commodities().Get(strings.SplitN(t.Symbol, ".", 2)[0]); err != nil {
+		return err
+	}
+	if date, err = parseDate(tradeDate(t)); err != nil {
+		return err
+	}
+	if qty, err = parseDecimal(t.Quantity); err != nil {
+		return err
+	}
+	if price, err = parseDecimal(t.TradePrice); err != nil {
+		return err
+	}
+	if proceeds, err = parseDecimal(t.Proceeds); err != nil {
+		return err
+	}
+	if fee, err = parseOptionalDecimal(t.Commission); err != nil {
+		return err
+	}
+	feeCurrency, err := p.commissionCurrency(t, p.baseCurrency)
+	if err != nil {
+		return err
+	}
+	if qty.IsPositive() {
+		desc = fmt.Sprintf("Buy %s %s @ %s %s", qty, stock.Name(), price, currency.Name())
+	} else {
+		desc = fmt.Sprintf("Sell %s %s @ %s %s", qty, stock.Name(), price, currency.Name())
+	}
+	postings := posting.Builders{
+		{
+			Credit:    p.trading,
+			Debit:     p.account,
+			Commodity: stock,
+			Quantity:  qty,
+		},
+		{
+			Credit:    p.trading,
+			Debit:     p.account,
+			Commodity: currency,
+			Quantity:  proceeds,
+		},
+	}
+	if !fee.IsZero() {
+		postings = append(postings, posting.Builder{
+			Credit:    p.fee,
+			Debit:     p.account,
+			Commodity: feeCurrency,
+			Quantity:  fee,
+		})
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: desc,
+		Postings:    postings.Build(),
+		Targets:     []*model.Commodity{stock, currency},
+	}.Build())
+	return nil
+}
+
+func (p *parser) commissionCurrency(t trade, def *model.Commodity) (*model.Commodity, error) {
+	if t.CommissionCurrency == "" {
+		return def, nil
+	}
+	return p.registry.Commodities().Get(t.CommissionCurrency)
+}
+
+func (p *parser) parseCashTransaction(ct cashTransaction) error {
+	var (
+		currency *model.Commodity
+		date     time.Time
+		quantity decimal.Decimal
+		desc     = ct.Description
+		account  *model.Account
+		targets  []*model.Commodity
+		err      error
+	)
+	if currency, err = p.registry.Commodities().Get(ct.Currency); err != nil {
+		return err
+	}
+	d := ct.SettleDate
+	if ct.DateTime != "" {
+		d = ct.DateTime
+	}
+	if date, err = parseDate(d); err != nil {
+		return err
+	}
+	if quantity, err = parseDecimal(ct.Amount); err != nil {
+		return err
+	}
+	switch ct.Type {
+	case "Dividends", "Payment In Lieu Of Dividends":
+		account = p.dividend
+	case "Withholding Tax":
+		account = p.tax
+	case "Broker Interest Paid", "Broker Interest Received", "Bond Interest Paid", "Bond Interest Received":
+		account, targets = p.interest, []*model.Commodity{currency}
+	case "Other Fees", "Commission Adjustments":
+		account = p.fee
+	case "Deposits/Withdrawals", "Deposits & Withdrawals":
+		account = p.registry.Accounts().TBDAccount()
+		if quantity.IsPositive() {
+			desc = fmt.Sprintf("Deposit %s %s", quantity, currency.Name())
+		} else {
+			desc = fmt.Sprintf("Withdraw %s %s", quantity, currency.Name())
+		}
+	default:
+		account = p.registry.Accounts().TBDAccount()
+	}
+	if ct.Symbol != "" && targets == nil {
+		security, err := p.commodity(ct.Symbol)
+		if err != nil {
+			return err
+		}
+		targets = []*model.Commodity{security}
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: desc,
+		Postings: posting.Builder{
+			Credit:    account,
+			Debit:     p.account,
+			Commodity: currency,
+			Quantity:  quantity,
+		}.Build(),
+		Targets: targets,
+	}.Build())
+	return nil
+}
+
+var splitRegex = regexp.MustCompile(`SPLIT (\d+(?:\.\d+)?) FOR (\d+(?:\.\d+)?)`)
+
+// parseCorporateAction creates split directives for forward splits. Other
+// corporate actions are booked against the TBD account.
+func (p *parser) parseCorporateAction(ca corporateAction) error {
+	var (
+		security *model.Commodity
+		date     time.Time
+		quantity decimal.Decimal
+		err      error
+	)
+	if security, err = p.commodity(ca.Symbol); err != nil {
+		return err
+	}
+	d := ca.ReportDate
+	if ca.DateTime != "" {
+		d = ca.DateTime
+	}
+	if date, err = parseDate(d); err != nil {
+		return err
+	}
+	if m := splitRegex.FindStringSubmatch(ca.Description); ca.Type == "FS" && m != nil {
+		num, _ := decimal.NewFromString(m[1])
+		den, _ := decimal.NewFromString(m[2])
+		p.builder.Add(&model.Split{
+			Date:        date,
+			Commodity:   security,
+			Numerator:   num,
+			Denominator: den,
+		})
+		return nil
+	}
+	if quantity, err = parseDecimal(ca.Quantity); err != nil {
+		return err
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: ca.Description,
+		Postings: posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: security,
+			Quantity:  quantity,
+		}.Build(),
+		Targets: []*model.Commodity{security},
+	}.Build())
+	return nil
+}
+
+func (p *parser) createAssertions(date time.Time, positions []openPosition) error {
+	for _, pos := range positions {
+		if pos.LevelOfDetail != "" && pos.LevelOfDetail != "SUMMARY" {
+			continue
+		}
+		var (
+			symbol   *model.Commodity
+			quantity decimal.Decimal
+			err      error
+		)
+		if symbol, err = p.commodity(pos.Symbol); err != nil {
+			return err
+		}
+		if quantity, err = parseDecimal(pos.Position); err != nil {
+			return err
+		}
+		p.builder.Add(&model.Assertion{
+			Date: date,
+			Balances: []model.Balance{
+				{
+					Account:   p.account,
+					Commodity: symbol,
+					Quantity:  quantity,
+				},
+			},
+		})
+	}
+	return nil
+}
+
+func (p *parser) createCurrencyAssertions(date time.Time, cash []cashReportCurrency) error {
+	for _, c := range cash {
+		if c.Currency == "BASE_SUMMARY" {
+			continue
+		}
+		var (
+			symbol *model.Commodity
+			amount decimal.Decimal
+			err    error
+		)
+		if symbol, err = p.registry.Commodities().Get(c.Currency); err != nil {
+			return err
+		}
+		if amount, err = parseRoundedDecimal(c.EndingCash); err != nil {
+			return err
+		}
+		p.builder.Add(&model.Assertion{
+			Date: date,
+			Balances: []model.Balance{
+				{
+					Account:   p.account,
+					Commodity: symbol,
+					Quantity:  amount,
+				},
+			},
+		})
+	}
+	return nil
+}
+
+// commodity maps a symbol to a commodity. Characters which are not valid in
+// commodity names, such as the space in "BRK B", are removed.
+func (p *parser) commodity(symbol string) (*model.Commodity, error) {
+	return p.registry.Commodities().Get(strings.Map(func(r rune) rune {
+		if r == ' ' || r == '.' {
+			return -1
+		}
+		return r
+	}, symbol))
+}
+
+func tradeDate(t trade) string {
+	if t.TradeDate != "" {
+		return t.TradeDate
+	}
+	return t.DateTime
+}
+
+func parseRoundedDecimal(s string) (decimal.Decimal, error) {
+	amount, err := parseDecimal(s)
+	if err != nil {
+		return amount, err
+	}
+	return amount.Round(2), nil
+}
+
+func parseDecimal(s string) (decimal.Decimal, error) {
+	return decimal.NewFromString(strings.ReplaceAll(s, ",", ""))
+}
+
+func parseOptionalDecimal(s string) (decimal.Decimal, error) {
+	if s == "" {
+		return decimal.Zero, nil
+	}
+	return parseDecimal(s)
+}
+
+// parseDate parses dates in the formats offered by flex queries
+// (yyyyMMdd or yyyy-MM-dd), ignoring an optional time part.
+func parseDate(s string) (time.Time, error) {
+	if len(s) >= 10 && s[4] == '-' {
+		return time.Parse("2006-01-02", s[:10])
+	}
+	if len(s) >= 8 {
+		return time.Parse("20060102", s[:8])
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
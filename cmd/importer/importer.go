@@ -1,6 +1,16 @@
 package importer
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/dedup"
+	"github.com/sboehler/knut/lib/journal/rules"
+	"github.com/sboehler/knut/lib/model/registry"
+)
 
 var importers []func() *cobra.Command
 
@@ -12,3 +22,139 @@ func RegisterImporter(f func() *cobra.Command) {
 func GetImporters() []func() *cobra.Command {
 	return importers
 }
+
+// SetupFlags sets up the flags shared by all importers.
+func SetupFlags(c *cobra.Command) {
+	c.PersistentFlags().String("rules", "", "assign accounts to imported transactions using the rules in the given YAML file")
+	c.PersistentFlags().String("journal", "", "skip transactions which already exist in the given journal")
+	c.PersistentFlags().Int("tolerance", 3, "maximum number of days between the dates of duplicate transactions")
+	c.PersistentFlags().Float64("similarity", 0, "minimum similarity (0-1) of the descriptions of duplicate transactions (0: ignore descriptions)")
+	c.PersistentFlags().StringP("output", "o", "", "write to the given file instead of stdout")
+	c.PersistentFlags().Bool("append", false, "append to the output file instead of overwriting it")
+	c.PersistentFlags().String("routing", "", "write to files by date, using the routing config in the given YAML file")
+	c.PersistentFlags().String("assert", AssertDaily, "balance assertions to import: none, last (the last balance of every account) or daily")
+}
+
+// Print prints the imported journal, or writes it to files if --output or
+// --routing is given. If a rules file is given with the
+// --rules flag, the rules are applied to the imported transactions. If a
+// journal is given with the --journal flag, transactions which already
+// exist in that journal are skipped and reported on stderr. Balance
+// assertions are selected with the --assert flag.
+func Print(cmd *cobra.Command, w io.Writer, j *journal.Journal) error {
+	if err := categorize(cmd, j); err != nil {
+		return err
+	}
+	if err := deduplicate(cmd, j); err != nil {
+		return err
+	}
+	missing, err := assert(cmd, j)
+	if err != nil {
+		return err
+	}
+	return output(cmd, w, j, missing)
+}
+
+// output writes the journal to the file given by --output or to the files
+// given by the routing config, or to w otherwise. If balance assertions are
+// missing, a comment is printed to w, or a note to stderr if files are
+// written.
+func output(cmd *cobra.Command, w io.Writer, j *journal.Journal, missing bool) error {
+	file, _ := cmd.Flags().GetString("output")
+	appendTo, _ := cmd.Flags().GetBool("append")
+	routing, _ := cmd.Flags().GetString("routing")
+	switch {
+	case file != "" && routing != "":
+		return fmt.Errorf("--output and --routing are mutually exclusive")
+	case appendTo && file == "":
+		return fmt.Errorf("--append requires --output")
+	case missing && (file != "" || routing != ""):
+		fmt.Fprintln(cmd.ErrOrStderr(), NoBalances)
+	case missing:
+		fmt.Fprintf(w, "%s\n\n", NoBalances)
+	}
+	switch {
+	case file != "":
+		return WriteFile(file, j, appendTo)
+	case routing != "":
+		r, err := ReadRouting(routing)
+		if err != nil {
+			return err
+		}
+		files, err := r.Write(j)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			fmt.Fprintf(cmd.ErrOrStderr(), "wrote %s\n", f)
+		}
+		return nil
+	}
+	return journal.Print(w, j)
+}
+
+// assert filters the balance assertions according to --assert. It returns
+// whether assertions are missing although some were requested.
+func assert(cmd *cobra.Command, j *journal.Journal) (bool, error) {
+	mode, err := cmd.Flags().GetString("assert")
+	if err != nil {
+		// the flag is not defined when an importer is run on its own
+		mode = AssertDaily
+	}
+	found, err := FilterAssertions(j, mode)
+	if err != nil {
+		return false, err
+	}
+	return mode != AssertNone && !found, nil
+}
+
+func categorize(cmd *cobra.Command, j *journal.Journal) error {
+	path, err := cmd.Flags().GetString("rules")
+	if err != nil || path == "" {
+		return nil
+	}
+	rs, err := rules.Read(path)
+	if err != nil {
+		return err
+	}
+	reg := registry.New()
+	for _, d := range j.Days {
+		for _, t := range d.Transactions {
+			if err := rs.Apply(reg, t); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func deduplicate(cmd *cobra.Command, j *journal.Journal) error {
+	path, err := cmd.Flags().GetString("journal")
+	if err != nil || path == "" {
+		// the flag is not defined when an importer is run on its own
+		return nil
+	}
+	tolerance, err := cmd.Flags().GetInt("tolerance")
+	if err != nil {
+		return err
+	}
+	similarity, err := cmd.Flags().GetFloat64("similarity")
+	if err != nil {
+		return err
+	}
+	existing, err := journal.FromPath(cmd.Context(), registry.New(), path)
+	if err != nil {
+		return err
+	}
+	m := dedup.NewMatcher(existing.Build(), tolerance, similarity)
+	duplicates := m.Filter(j)
+	for _, d := range duplicates {
+		fmt.Fprintf(cmd.ErrOrStderr(), "skipped %s %q: matches %s %q\n",
+			d.Imported.Date.Format("2006-01-02"), d.Imported.Description,
+			d.Existing.Date.Format("2006-01-02"), d.Existing.Description)
+	}
+	if len(duplicates) > 0 {
+		fmt.Fprintf(cmd.ErrOrStderr(), "skipped %d duplicate transactions\n", len(duplicates))
+	}
+	return nil
+}
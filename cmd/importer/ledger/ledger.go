@@ -0,0 +1,440 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ledger
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/cmd/importer/plaintext"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+// CreateCmd creates the cobra command.
+func CreateCmd() *cobra.Command {
+
+	var r runner
+
+	cmd := &cobra.Command{
+		Use:   "ledger",
+		Short: "Import a ledger or hledger journal",
+		Long: `Import a ledger or hledger journal, including the files it includes. Transactions,
+balance assertions and assignments on postings, and price (P) directives are converted.
+Transactions with more than two postings are converted to bookings between two accounts by pairing
+the postings of every commodity. Amounts which cannot be paired, e.g. the two sides of a currency
+exchange or of a purchase at cost, are booked against the balancing account. Codes, notes and tags
+are kept as comments.
+
+Account names are converted to valid knut account names (e.g. "expenses:dining out" becomes
+Expenses:DiningOut), currency symbols such as $ or € are converted to ISO codes, and accounts are
+opened at their first use. Virtual postings, automated and periodic transactions are skipped and
+reported on stderr.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		Run: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	balancing flags.AccountFlag
+}
+
+func (r *runner) setupFlags(c *cobra.Command) {
+	c.Flags().VarP(&r.balancing, "balancing", "b", "account for amounts which cannot be paired (default Equity:Conversions)")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) {
+	if err := r.runE(cmd, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func (r *runner) runE(cmd *cobra.Command, args []string) error {
+	reg := registry.New()
+	balancing, err := r.balancing.ValueWithDefault(reg.Accounts(), reg.Accounts().MustGet("Equity:Conversions"))
+	if err != nil {
+		return err
+	}
+	p := parser{
+		skipped: make(map[string]int),
+		aliases: make(map[string]string),
+		year:    time.Now().Year(),
+	}
+	if err := p.parseFile(args[0]); err != nil {
+		return err
+	}
+	p.reportSkipped(cmd)
+	c := plaintext.Converter{Registry: reg, Balancing: balancing}
+	j, err := c.Convert(p.entries)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return importer.Print(cmd, out, j)
+}
+
+type parser struct {
+	entries []plaintext.Entry
+	skipped map[string]int
+	aliases map[string]string
+	year    int
+
+	// the current transaction and posting, to which indented lines belong
+	trx     *plaintext.Transaction
+	account string
+	// block is the comment or test block which is skipped until its end
+	block string
+}
+
+func (p *parser) reportSkipped(cmd *cobra.Command) {
+	var ds []string
+	for d := range p.skipped {
+		ds = append(ds, d)
+	}
+	sort.Strings(ds)
+	for _, d := range ds {
+		fmt.Fprintf(cmd.ErrOrStderr(), "skipped %d %s\n", p.skipped[d], d)
+	}
+}
+
+func (p *parser) parseFile(path string) error {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(string(text), "\n") {
+		pos := fmt.Sprintf("%s:%d", path, i+1)
+		if err := p.parseLine(path, pos, strings.TrimRight(line, "\r")); err != nil {
+			return fmt.Errorf("%s: %w", pos, err)
+		}
+	}
+	p.end()
+	return nil
+}
+
+// end ends the current transaction.
+func (p *parser) end() {
+	p.trx, p.account = nil, ""
+}
+
+func (p *parser) parseLine(path, pos, line string) error {
+	if p.block == "comment" || p.block == "test" {
+		if strings.HasPrefix(line, "end "+p.block) {
+			p.block = ""
+		}
+		return nil
+	}
+	if strings.TrimSpace(line) == "" {
+		p.end()
+		return nil
+	}
+	if line[0] == ' ' || line[0] == '\t' {
+		if p.trx == nil {
+			// lines of a skipped directive
+			return nil
+		}
+		return p.parseIndented(strings.TrimSpace(line))
+	}
+	p.end()
+	p.block = ""
+	if line[0] >= '0' && line[0] <= '9' {
+		return p.parseTransaction(pos, line)
+	}
+	directive, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	switch directive {
+	case "include", "!include":
+		inc := rest
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		return p.parseFile(inc)
+	case "P":
+		return p.parsePrice(pos, rest)
+	case "apply":
+		if y, ok := strings.CutPrefix(rest, "year "); ok {
+			return p.setYear(y)
+		}
+	case "year", "Y":
+		return p.setYear(rest)
+	case "alias":
+		from, to, ok := strings.Cut(rest, "=")
+		if !ok {
+			return fmt.Errorf("invalid alias %q", rest)
+		}
+		p.aliases[strings.TrimSpace(from)] = strings.TrimSpace(to)
+	case "comment", "test":
+		p.block = directive
+	case "=":
+		p.skipped["automated transactions"]++
+	case "~":
+		p.skipped["periodic transactions"]++
+	}
+	// comments, account, commodity and other declarations
+	return nil
+}
+
+var headerRegex = regexp.MustCompile(`^(\S+?)(?:=(\S+))?(?:\s+([*!]))?(?:\s+\(([^)]*)\))?(?:\s+(.*))?$`)
+
+func (p *parser) parseTransaction(pos, line string) error {
+	line, note := splitComment(line)
+	m := headerRegex.FindStringSubmatch(line)
+	if m == nil {
+		return fmt.Errorf("invalid transaction: %s", line)
+	}
+	date, err := p.parseDate(m[1])
+	if err != nil {
+		return err
+	}
+	var comments []string
+	if m[3] == "!" {
+		comments = append(comments, "flag: !")
+	}
+	if m[4] != "" {
+		comments = append(comments, fmt.Sprintf("code: %s", m[4]))
+	}
+	if m[2] != "" {
+		comments = append(comments, fmt.Sprintf("date2: %s", m[2]))
+	}
+	if note != "" {
+		comments = append(comments, note)
+	}
+	p.trx = &plaintext.Transaction{
+		Pos:         pos,
+		Date:        date,
+		Description: strings.TrimSpace(m[5]),
+		Comments:    comments,
+	}
+	p.entries = append(p.entries, p.trx)
+	return nil
+}
+
+func (p *parser) setYear(s string) error {
+	y, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("invalid year %q", s)
+	}
+	p.year = y
+	return nil
+}
+
+func (p *parser) parseDate(s string) (time.Time, error) {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == '/' || r == '-' || r == '.' })
+	if len(parts) == 2 {
+		parts = append([]string{strconv.Itoa(p.year)}, parts...)
+	}
+	d, err := time.Parse("2006-1-2", strings.Join(parts, "-"))
+	if err != nil {
+		return d, fmt.Errorf("invalid date %q", s)
+	}
+	return d, nil
+}
+
+func (p *parser) parseIndented(line string) error {
+	if strings.HasPrefix(line, ";") {
+		c := strings.TrimSpace(line[1:])
+		if p.account != "" {
+			c = fmt.Sprintf("%s (%s)", c, p.account)
+		}
+		p.trx.Comments = append(p.trx.Comments, c)
+		return nil
+	}
+	return p.parsePosting(line)
+}
+
+func (p *parser) parsePosting(line string) error {
+	line, note := splitComment(line)
+	if len(line) > 1 && (line[0] == '*' || line[0] == '!') {
+		line = strings.TrimSpace(line[1:])
+	}
+	account, rest := cutAccount(line)
+	if strings.HasPrefix(account, "(") || strings.HasPrefix(account, "[") {
+		p.skipped["virtual postings"]++
+		return nil
+	}
+	if a, ok := p.aliases[account]; ok {
+		account = a
+	}
+	pst := plaintext.Posting{Account: account}
+	rest, assertion, _ := strings.Cut(rest, "=")
+	if assertion = strings.TrimLeft(assertion, "=*"); strings.TrimSpace(assertion) != "" {
+		a, err := parseAmount(assertion)
+		if err != nil {
+			return err
+		}
+		pst.Assertion = a
+	}
+	rest, price, total := cutPrice(rest)
+	rest, cost, totalCost := cutAnnotations(rest)
+	var err error
+	if pst.Amount, err = parseAmount(rest); err != nil {
+		return err
+	}
+	if pst.Cost, err = perUnit(cost, totalCost, pst.Amount); err != nil {
+		return err
+	}
+	if pst.Price, err = perUnit(price, total, pst.Amount); err != nil {
+		return err
+	}
+	p.trx.Postings = append(p.trx.Postings, pst)
+	p.account = account
+	if note != "" {
+		p.trx.Comments = append(p.trx.Comments, fmt.Sprintf("%s (%s)", note, account))
+	}
+	return nil
+}
+
+// cutAccount cuts the account, which ends with two spaces or a tab.
+func cutAccount(line string) (string, string) {
+	i := strings.Index(line, "  ")
+	if j := strings.Index(line, "\t"); j >= 0 && (i < 0 || j < i) {
+		i = j
+	}
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], strings.TrimSpace(line[i:])
+}
+
+func (p *parser) parsePrice(pos, s string) error {
+	fields := strings.Fields(s)
+	if len(fields) < 3 {
+		return fmt.Errorf("invalid price directive: %s", s)
+	}
+	date, err := p.parseDate(fields[0])
+	if err != nil {
+		return err
+	}
+	fields = fields[1:]
+	if strings.Contains(fields[0], ":") {
+		// time of day
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return fmt.Errorf("invalid price directive: %s", s)
+	}
+	amount, err := parseAmount(strings.Join(fields[1:], " "))
+	if err != nil {
+		return err
+	}
+	p.entries = append(p.entries, &plaintext.Price{
+		Pos:       pos,
+		Date:      date,
+		Commodity: fields[0],
+		Price:     *amount,
+	})
+	return nil
+}
+
+// splitComment splits a line into its content and a trailing comment.
+func splitComment(line string) (string, string) {
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ';' && !quoted:
+			return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		}
+	}
+	return strings.TrimSpace(line), ""
+}
+
+// cutPrice cuts a price annotation, @ per unit or @@ in total.
+func cutPrice(s string) (string, string, bool) {
+	if before, after, ok := strings.Cut(s, "@@"); ok {
+		return strings.TrimSpace(before), strings.TrimSpace(after), true
+	}
+	if before, after, ok := strings.Cut(s, "@"); ok {
+		return strings.TrimSpace(before), strings.TrimSpace(after), false
+	}
+	return strings.TrimSpace(s), "", false
+}
+
+var annotationRegex = regexp.MustCompile(`\{\{[^}]*\}\}|\{[^}]*\}|\[[^\]]*\]|\([^)]*\)`)
+
+// cutAnnotations removes the lot annotations of an amount and returns the
+// cost, {} per unit or {{}} in total, if any.
+func cutAnnotations(s string) (string, string, bool) {
+	var (
+		cost  string
+		total bool
+	)
+	for _, a := range annotationRegex.FindAllString(s, -1) {
+		if strings.HasPrefix(a, "{") {
+			total = strings.HasPrefix(a, "{{")
+			cost = strings.Trim(a, "{}=")
+		}
+	}
+	return strings.TrimSpace(annotationRegex.ReplaceAllString(s, "")), cost, total
+}
+
+var amountRegex = regexp.MustCompile(`^(-?)\s*(?:("[^"]*"|[^\d\s.,"-]+)\s*)?(-?[\d,]*\.?\d+)\s*("[^"]*"|[^\d\s.,"-]\S*)?$`)
+
+func parseAmount(s string) (*plaintext.Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	m := amountRegex.FindStringSubmatch(s)
+	if m == nil || m[2] != "" && m[4] != "" {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	q, err := decimal.NewFromString(strings.ReplaceAll(m[3], ",", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	if m[1] == "-" {
+		q = q.Neg()
+	}
+	return &plaintext.Amount{Quantity: q, Commodity: m[2] + m[4]}, nil
+}
+
+// perUnit parses a cost or price, which is converted to the price per unit
+// if it is given in total.
+func perUnit(s string, total bool, amount *plaintext.Amount) (*plaintext.Amount, error) {
+	if s == "" || amount == nil {
+		return nil, nil
+	}
+	res, err := parseAmount(s)
+	if err != nil || res == nil {
+		return nil, err
+	}
+	if total && !amount.Quantity.IsZero() {
+		res.Quantity = res.Quantity.Div(amount.Quantity.Abs())
+	}
+	return res, nil
+}
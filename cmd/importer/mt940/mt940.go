@@ -0,0 +1,353 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mt940
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the cobra command.
+func CreateCmd() *cobra.Command {
+
+	var r runner
+
+	cmd := &cobra.Command{
+		Use:   "mt940",
+		Short: "Import SWIFT MT940 account statements",
+		Long: `Import SWIFT MT940 account statements. Descriptions are taken from the :86: field, using the
+structured subfields (counterparty name and remittance information) where available. Opening and
+closing balances are imported as balance assertions at their respective dates.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		Run: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+type runner struct {
+	accountFlag flags.AccountFlag
+}
+
+func (r *runner) setupFlags(cmd *cobra.Command) {
+	cmd.Flags().VarP(&r.accountFlag, "account", "a", "account name")
+	cmd.MarkFlagRequired("account")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) {
+	if err := r.runE(cmd, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func (r *runner) runE(cmd *cobra.Command, args []string) error {
+	var (
+		reader *bufio.Reader
+		reg    = registry.New()
+		err    error
+	)
+	if reader, err = flags.OpenFile(args[0]); err != nil {
+		return err
+	}
+	p := Parser{
+		registry: reg,
+		builder:  journal.New(),
+	}
+	if p.account, err = r.accountFlag.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if err = p.parse(reader); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return importer.Print(cmd, out, p.builder.Build())
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+// Parser is a parser for MT940 account statements.
+type Parser struct {
+	registry *model.Registry
+	account  *model.Account
+	builder  *journal.Builder
+
+	currency *model.Commodity
+	booking  *booking
+	balances map[amounts.Key]decimal.Decimal
+}
+
+type booking struct {
+	date     time.Time
+	quantity decimal.Decimal
+	info     string
+}
+
+type field struct {
+	tag, value string
+}
+
+var tagRegex = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+
+func (p *Parser) parse(r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if !utf8.Valid(b) {
+		// MT940 files are usually encoded in Latin-1 or one of its variants
+		if b, err = charmap.Windows1252.NewDecoder().Bytes(b); err != nil {
+			return err
+		}
+	}
+	p.balances = make(map[amounts.Key]decimal.Decimal)
+	for _, f := range fields(string(b)) {
+		if err := p.parseField(f); err != nil {
+			return fmt.Errorf(":%s:%s: %w", f.tag, f.value, err)
+		}
+	}
+	p.flush()
+	for k, bal := range p.balances {
+		p.builder.Add(&model.Assertion{
+			Date: k.Date,
+			Balances: []model.Balance{
+				{
+					Account:   p.account,
+					Commodity: k.Commodity,
+					Quantity:  bal,
+				},
+			},
+		})
+	}
+	return nil
+}
+
+// fields splits the file into tagged fields. Fields can span multiple
+// lines, and SWIFT block headers and trailers are ignored.
+func fields(s string) []field {
+	var res []field
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := tagRegex.FindStringSubmatch(line); m != nil {
+			res = append(res, field{tag: m[1], value: line[len(m[0]):]})
+			continue
+		}
+		if line == "" || line == "-" || line == "-}" || strings.HasPrefix(line, "{") {
+			continue
+		}
+		if len(res) > 0 {
+			res[len(res)-1].value += "\n" + line
+		}
+	}
+	return res
+}
+
+func (p *Parser) parseField(f field) error {
+	switch f.tag {
+	case "60F", "60M", "62F", "62M":
+		p.flush()
+		return p.parseBalance(f.value)
+	case "61":
+		p.flush()
+		return p.parseStatementLine(f.value)
+	case "86":
+		if p.booking != nil {
+			p.booking.info = parseInformation(f.value)
+		}
+	}
+	return nil
+}
+
+// flush adds the current booking to the journal.
+func (p *Parser) flush() {
+	if p.booking == nil {
+		return
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        p.booking.date,
+		Description: p.booking.info,
+		Postings: posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: p.currency,
+			Quantity:  p.booking.quantity,
+		}.Build(),
+	}.Build())
+	p.booking = nil
+}
+
+var balanceRegex = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)`)
+
+func (p *Parser) parseBalance(s string) error {
+	m := balanceRegex.FindStringSubmatch(s)
+	if m == nil {
+		return fmt.Errorf("invalid balance")
+	}
+	date, err := time.Parse("060102", m[2])
+	if err != nil {
+		return err
+	}
+	if p.currency, err = p.registry.Commodities().Get(m[3]); err != nil {
+		return err
+	}
+	quantity, err := parseAmount(m[1], m[4])
+	if err != nil {
+		return err
+	}
+	p.balances[amounts.DateCommodityKey(date, p.currency)] = quantity
+	return nil
+}
+
+var statementLineRegex = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)[A-Z]?(\d+,\d*)`)
+
+func (p *Parser) parseStatementLine(s string) error {
+	if p.currency == nil {
+		return fmt.Errorf("statement line before opening balance")
+	}
+	m := statementLineRegex.FindStringSubmatch(s)
+	if m == nil {
+		return fmt.Errorf("invalid statement line")
+	}
+	valueDate, err := time.Parse("060102", m[1])
+	if err != nil {
+		return err
+	}
+	date := valueDate
+	if m[2] != "" {
+		if date, err = entryDate(valueDate, m[2]); err != nil {
+			return err
+		}
+	}
+	quantity, err := parseAmount(m[3], m[4])
+	if err != nil {
+		return err
+	}
+	p.booking = &booking{date: date, quantity: quantity}
+	return nil
+}
+
+// entryDate computes the booking date, which has no year, relative to the
+// value date. Both can lie in different years around new year.
+func entryDate(valueDate time.Time, mmdd string) (time.Time, error) {
+	d, err := time.Parse("0102", mmdd)
+	if err != nil {
+		return time.Time{}, err
+	}
+	year := valueDate.Year()
+	switch {
+	case valueDate.Month() == time.January && d.Month() == time.December:
+		year--
+	case valueDate.Month() == time.December && d.Month() == time.January:
+		year++
+	}
+	return time.Date(year, d.Month(), d.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+func parseAmount(mark, amount string) (decimal.Decimal, error) {
+	q, err := decimal.NewFromString(strings.Replace(amount, ",", ".", 1))
+	if err != nil {
+		return decimal.Zero, err
+	}
+	// reversals of credits (RC) are debits, and vice versa
+	if mark == "D" || mark == "RC" {
+		q = q.Neg()
+	}
+	return q, nil
+}
+
+var structuredRegex = regexp.MustCompile(`^\d{3}[?@/>]`)
+
+// parseInformation extracts a description from the :86: field. Structured
+// fields, as used by German and Swiss banks, start with a three-digit
+// transaction code followed by subfields such as ?20 (remittance
+// information) or ?32 (counterparty name).
+func parseInformation(s string) string {
+	if !structuredRegex.MatchString(s) {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	s = strings.ReplaceAll(s, "\n", "")
+	subfields := make(map[int]string)
+	for _, sf := range strings.Split(s[4:], s[3:4]) {
+		var code int
+		if len(sf) < 2 {
+			continue
+		}
+		if _, err := fmt.Sscanf(sf[:2], "%02d", &code); err != nil {
+			continue
+		}
+		subfields[code] = sf[2:]
+	}
+	var name, remittance []string
+	for code := 32; code <= 33; code++ {
+		name = append(name, subfields[code])
+	}
+	for _, codes := range [][2]int{{20, 29}, {60, 63}} {
+		for code := codes[0]; code <= codes[1]; code++ {
+			remittance = append(remittance, subfields[code])
+		}
+	}
+	desc := []string{strings.Join(name, ""), sepaRemittance(strings.Join(remittance, ""))}
+	if desc[0] == "" && desc[1] == "" {
+		// fall back to the posting text
+		desc = append(desc, subfields[0])
+	}
+	return strings.Join(strings.Fields(strings.Join(desc, " ")), " ")
+}
+
+var sepaRegex = regexp.MustCompile(`(EREF|KREF|MREF|CRED|DEBT|COAM|OAMT|SVWZ|ABWA|ABWE|IBAN|BIC)\+`)
+
+// sepaRemittance extracts the remittance information (SVWZ+) from SEPA
+// remittance fields. Other texts are returned unchanged.
+func sepaRemittance(s string) string {
+	locs := sepaRegex.FindAllStringSubmatchIndex(s, -1)
+	for i, loc := range locs {
+		if s[loc[2]:loc[3]] != "SVWZ" {
+			continue
+		}
+		end := len(s)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		return s[loc[1]:end]
+	}
+	if len(locs) > 0 {
+		return s[:locs[0][0]]
+	}
+	return s
+}
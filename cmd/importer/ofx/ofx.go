@@ -0,0 +1,508 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ofx
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the cobra command.
+func CreateCmd() *cobra.Command {
+
+	var r runner
+
+	cmd := &cobra.Command{
+		Use:   "ofx",
+		Short: "Import OFX / QFX bank, credit card and investment statements",
+		Long: `Import OFX statements (SGML as in OFX 1.x or XML as in OFX 2.x), as exported by many banks
+and brokers. Bank and credit card transactions are booked against Expenses:TBD. For investment
+statements, securities are mapped to commodities by their ticker symbol from the security list,
+or by their unique ID (CUSIP / ISIN) if there is no usable ticker. Ledger balances and positions
+are imported as balance assertions.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		Run: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	accountFlag, dividendFlag, taxFlag, feeFlag, interestFlag, tradingFlag flags.AccountFlag
+}
+
+func (r *runner) setupFlags(c *cobra.Command) {
+	c.Flags().VarP(&r.accountFlag, "account", "a", "account name")
+	c.Flags().VarP(&r.interestFlag, "interest", "i", "account name of the interest account (default Expenses:TBD)")
+	c.Flags().VarP(&r.dividendFlag, "dividend", "d", "account name of the dividend account (default Expenses:TBD)")
+	c.Flags().VarP(&r.taxFlag, "tax", "w", "account name of the withholding tax account (default Expenses:TBD)")
+	c.Flags().VarP(&r.feeFlag, "fee", "f", "account name of the fee account (default Expenses:TBD)")
+	c.Flags().VarP(&r.tradingFlag, "trading", "t", "account name of the trading gain / loss account (default Expenses:TBD)")
+	c.MarkFlagRequired("account")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) {
+	if err := r.runE(cmd, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func (r *runner) runE(cmd *cobra.Command, args []string) error {
+	var (
+		reg = registry.New()
+		tbd = reg.Accounts().TBDAccount()
+		err error
+	)
+	f, err := flags.OpenFile(args[0])
+	if err != nil {
+		return err
+	}
+	doc, err := parse(f)
+	if err != nil {
+		return err
+	}
+	p := parser{
+		registry: reg,
+		builder:  journal.New(),
+	}
+	if p.account, err = r.accountFlag.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if p.interest, err = r.interestFlag.ValueWithDefault(reg.Accounts(), tbd); err != nil {
+		return err
+	}
+	if p.dividend, err = r.dividendFlag.ValueWithDefault(reg.Accounts(), tbd); err != nil {
+		return err
+	}
+	if p.tax, err = r.taxFlag.ValueWithDefault(reg.Accounts(), tbd); err != nil {
+		return err
+	}
+	if p.fee, err = r.feeFlag.ValueWithDefault(reg.Accounts(), tbd); err != nil {
+		return err
+	}
+	if p.trading, err = r.tradingFlag.ValueWithDefault(reg.Accounts(), tbd); err != nil {
+		return err
+	}
+	if err = p.parse(doc); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return importer.Print(cmd, out, p.builder.Build())
+}
+
+type parser struct {
+	registry *model.Registry
+	builder  *journal.Builder
+	account  *model.Account
+
+	dividend, tax, fee, interest, trading *model.Account
+
+	securities map[string]*model.Commodity
+	fitids     set.Set[string]
+}
+
+func (p *parser) parse(doc *element) error {
+	p.fitids = set.New[string]()
+	if err := p.parseSecurities(doc); err != nil {
+		return err
+	}
+	for _, name := range []string{"STMTRS", "CCSTMTRS"} {
+		for _, stmt := range doc.findAll(name) {
+			if err := p.parseStatement(stmt); err != nil {
+				return err
+			}
+		}
+	}
+	for _, stmt := range doc.findAll("INVSTMTRS") {
+		if err := p.parseInvestmentStatement(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSecurities maps the securities in the security list to commodities.
+func (p *parser) parseSecurities(doc *element) error {
+	p.securities = make(map[string]*model.Commodity)
+	for _, info := range doc.findAll("SECINFO") {
+		id := info.text("SECID", "UNIQUEID")
+		c, err := p.registry.Commodities().Get(info.text("TICKER"))
+		if err != nil {
+			if c, err = p.registry.Commodities().Get(id); err != nil {
+				return fmt.Errorf("no valid commodity name for security %q", info.text("SECNAME"))
+			}
+		}
+		p.securities[id] = c
+	}
+	return nil
+}
+
+func (p *parser) security(e *element) (*model.Commodity, error) {
+	id := e.text("SECID", "UNIQUEID")
+	if c, ok := p.securities[id]; ok {
+		return c, nil
+	}
+	c, err := p.registry.Commodities().Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("unknown security %q: %w", id, err)
+	}
+	return c, nil
+}
+
+// currency returns the currency of the aggregate, which defaults to the
+// currency of the statement.
+func (p *parser) currency(e *element, def *model.Commodity) (*model.Commodity, error) {
+	if sym := e.text("CURRENCY", "CURSYM"); sym != "" {
+		return p.registry.Commodities().Get(sym)
+	}
+	return def, nil
+}
+
+func (p *parser) parseStatement(stmt *element) error {
+	cur, err := p.registry.Commodities().Get(stmt.text("CURDEF"))
+	if err != nil {
+		return fmt.Errorf("invalid statement currency: %w", err)
+	}
+	for _, trx := range stmt.child("BANKTRANLIST").findAll("STMTTRN") {
+		if err := p.parseTransaction(trx, cur); err != nil {
+			return err
+		}
+	}
+	if bal := stmt.child("LEDGERBAL"); bal != nil {
+		date, err := parseDate(bal.text("DTASOF"))
+		if err != nil {
+			return err
+		}
+		amount, err := parseDecimal(bal.text("BALAMT"))
+		if err != nil {
+			return err
+		}
+		p.builder.Add(&model.Assertion{
+			Date: date,
+			Balances: []model.Balance{
+				{
+					Account:   p.account,
+					Commodity: cur,
+					Quantity:  amount,
+				},
+			},
+		})
+	}
+	return nil
+}
+
+// isDuplicate returns true if the transaction with the given ID has been
+// seen before. Some banks repeat transactions in overlapping statements.
+func (p *parser) isDuplicate(fitid string) bool {
+	if fitid == "" {
+		return false
+	}
+	if p.fitids.Has(fitid) {
+		return true
+	}
+	p.fitids.Add(fitid)
+	return false
+}
+
+func (p *parser) parseTransaction(trx *element, cur *model.Commodity) error {
+	if p.isDuplicate(trx.text("FITID")) {
+		return nil
+	}
+	date, err := parseDate(trx.text("DTPOSTED"))
+	if err != nil {
+		return err
+	}
+	amount, err := parseDecimal(trx.text("TRNAMT"))
+	if err != nil {
+		return err
+	}
+	if cur, err = p.currency(trx, cur); err != nil {
+		return err
+	}
+	name := trx.text("NAME")
+	if name == "" {
+		name = trx.text("PAYEE", "NAME")
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: join(name, trx.text("MEMO")),
+		Postings: posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: cur,
+			Quantity:  amount,
+		}.Build(),
+	}.Build())
+	return nil
+}
+
+func (p *parser) parseInvestmentStatement(stmt *element) error {
+	cur, err := p.registry.Commodities().Get(stmt.text("CURDEF"))
+	if err != nil {
+		return fmt.Errorf("invalid statement currency: %w", err)
+	}
+	if list := stmt.child("INVTRANLIST"); list != nil {
+		for _, trx := range list.children {
+			if err := p.parseInvestmentTransaction(trx, cur); err != nil {
+				return fmt.Errorf("%s: %w", trx.name, err)
+			}
+		}
+	}
+	return p.parsePositions(stmt)
+}
+
+func (p *parser) parseInvestmentTransaction(trx *element, cur *model.Commodity) error {
+	switch {
+	case trx.name == "DTSTART" || trx.name == "DTEND":
+		return nil
+	case trx.name == "INVBANKTRAN":
+		return p.parseTransaction(trx.child("STMTTRN"), cur)
+	case strings.HasPrefix(trx.name, "BUY"):
+		return p.parseTrade(trx.child("INVBUY"), cur, "Buy")
+	case strings.HasPrefix(trx.name, "SELL"):
+		return p.parseTrade(trx.child("INVSELL"), cur, "Sell")
+	case trx.name == "INCOME":
+		return p.parseIncome(trx, cur)
+	case trx.name == "REINVEST":
+		if err := p.parseIncome(trx, cur); err != nil {
+			return err
+		}
+		return p.parseTrade(trx, cur, "Reinvest")
+	default:
+		return fmt.Errorf("unsupported investment transaction")
+	}
+}
+
+func (p *parser) parseTrade(trx *element, cur *model.Commodity, kind string) error {
+	if trx == nil {
+		return fmt.Errorf("missing trade details")
+	}
+	if p.isDuplicate(trx.text("INVTRAN", "FITID") + kind) {
+		return nil
+	}
+	date, err := parseDate(trx.text("INVTRAN", "DTTRADE"))
+	if err != nil {
+		return err
+	}
+	security, err := p.security(trx)
+	if err != nil {
+		return err
+	}
+	if cur, err = p.currency(trx, cur); err != nil {
+		return err
+	}
+	var units, price, commission, fees, taxes, total decimal.Decimal
+	for _, f := range []struct {
+		field string
+		value *decimal.Decimal
+	}{
+		{"UNITS", &units},
+		{"UNITPRICE", &price},
+		{"COMMISSION", &commission},
+		{"FEES", &fees},
+		{"TAXES", &taxes},
+		{"TOTAL", &total},
+	} {
+		if *f.value, err = parseOptionalDecimal(trx.text(f.field)); err != nil {
+			return fmt.Errorf("invalid %s: %w", f.field, err)
+		}
+	}
+	costs := commission.Add(fees)
+	// TOTAL is net of commissions, fees and taxes. The gross amount is
+	// booked against the trading account.
+	gross := total.Add(costs).Add(taxes)
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: join(fmt.Sprintf("%s %s %s @ %s %s", kind, units.Abs(), security.Name(), price, cur.Name()), trx.text("INVTRAN", "MEMO")),
+		Postings: postings(
+			posting.Builder{
+				Credit:    p.trading,
+				Debit:     p.account,
+				Commodity: security,
+				Quantity:  units,
+			},
+			posting.Builder{
+				Credit:    p.trading,
+				Debit:     p.account,
+				Commodity: cur,
+				Quantity:  gross,
+			},
+			posting.Builder{
+				Credit:    p.fee,
+				Debit:     p.account,
+				Commodity: cur,
+				Quantity:  costs.Neg(),
+			},
+			posting.Builder{
+				Credit:    p.tax,
+				Debit:     p.account,
+				Commodity: cur,
+				Quantity:  taxes.Neg(),
+			},
+		),
+		Targets: []*model.Commodity{security, cur},
+	}.Build())
+	return nil
+}
+
+func (p *parser) parseIncome(trx *element, cur *model.Commodity) error {
+	if p.isDuplicate(trx.text("INVTRAN", "FITID")) {
+		return nil
+	}
+	date, err := parseDate(trx.text("INVTRAN", "DTTRADE"))
+	if err != nil {
+		return err
+	}
+	security, err := p.security(trx)
+	if err != nil {
+		return err
+	}
+	if cur, err = p.currency(trx, cur); err != nil {
+		return err
+	}
+	total, err := parseDecimal(trx.text("TOTAL"))
+	if err != nil {
+		return err
+	}
+	var (
+		desc    string
+		account = p.dividend
+	)
+	switch trx.text("INCOMETYPE") {
+	case "DIV":
+		desc = fmt.Sprintf("Dividend %s", security.Name())
+	case "INTEREST":
+		desc, account = fmt.Sprintf("Interest %s", security.Name()), p.interest
+	case "CGLONG", "CGSHORT":
+		desc = fmt.Sprintf("Capital gain distribution %s", security.Name())
+	default:
+		desc = fmt.Sprintf("Income %s", security.Name())
+	}
+	// For reinvestments, TOTAL is the (negative) amount reinvested.
+	if trx.name == "REINVEST" {
+		total = total.Neg()
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: join(desc, trx.text("INVTRAN", "MEMO")),
+		Postings: posting.Builder{
+			Credit:    account,
+			Debit:     p.account,
+			Commodity: cur,
+			Quantity:  total,
+		}.Build(),
+		Targets: []*model.Commodity{security, cur},
+	}.Build())
+	return nil
+}
+
+// parsePositions creates assertions for the security positions held at the
+// date of the statement.
+func (p *parser) parsePositions(stmt *element) error {
+	list := stmt.child("INVPOSLIST")
+	if list == nil {
+		return nil
+	}
+	date, err := parseDate(stmt.text("DTASOF"))
+	if err != nil {
+		return err
+	}
+	var balances []model.Balance
+	for _, pos := range list.findAll("INVPOS") {
+		security, err := p.security(pos)
+		if err != nil {
+			return err
+		}
+		units, err := parseDecimal(pos.text("UNITS"))
+		if err != nil {
+			return err
+		}
+		balances = append(balances, model.Balance{
+			Account:   p.account,
+			Commodity: security,
+			Quantity:  units,
+		})
+	}
+	if len(balances) > 0 {
+		p.builder.Add(&model.Assertion{
+			Date:     date,
+			Balances: balances,
+		})
+	}
+	return nil
+}
+
+func postings(bs ...posting.Builder) []*model.Posting {
+	var res posting.Builders
+	for _, b := range bs {
+		if !b.Quantity.IsZero() {
+			res = append(res, b)
+		}
+	}
+	return res.Build()
+}
+
+func join(ss ...string) string {
+	var res []string
+	for _, s := range ss {
+		if s = strings.TrimSpace(s); s != "" {
+			res = append(res, s)
+		}
+	}
+	return strings.Join(res, " ")
+}
+
+// parseDate parses the date part of an OFX datetime, such as
+// 20230105120000.000[-5:EST].
+func parseDate(s string) (time.Time, error) {
+	if len(s) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return time.Parse("20060102", s[:8])
+}
+
+func parseDecimal(s string) (decimal.Decimal, error) {
+	return decimal.NewFromString(strings.ReplaceAll(strings.TrimSpace(s), ",", "."))
+}
+
+func parseOptionalDecimal(s string) (decimal.Decimal, error) {
+	if s == "" {
+		return decimal.Zero, nil
+	}
+	return parseDecimal(s)
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ofx
+
+import (
+	"testing"
+
+	"github.com/sebdah/goldie/v2"
+
+	"github.com/sboehler/knut/cmd/cmdtest"
+)
+
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "example1",
+			args: []string{"--account", "Assets:Bank", "testdata/example1.input"},
+		},
+		{
+			name: "example2",
+			args: []string{"--account", "Liabilities:CreditCard", "testdata/example2.input"},
+		},
+		{
+			name: "example3",
+			args: []string{
+				"--account", "Assets:Broker",
+				"--dividend", "Income:Dividends",
+				"--fee", "Expenses:Fees",
+				"--trading", "Income:Trading",
+				"testdata/example3.input",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			got := cmdtest.Run(t, CreateCmd(), test.args...)
+
+			goldie.New(t).Assert(t, test.name, got)
+		})
+	}
+}
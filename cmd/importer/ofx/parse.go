@@ -0,0 +1,150 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ofx
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// element is a node of an OFX document. Aggregates have children, leaf
+// elements have a value.
+type element struct {
+	name     string
+	value    string
+	children []*element
+}
+
+// child returns the first descendant along the given path, or nil.
+func (e *element) child(path ...string) *element {
+	for _, name := range path {
+		if e == nil {
+			return nil
+		}
+		var next *element
+		for _, c := range e.children {
+			if c.name == name {
+				next = c
+				break
+			}
+		}
+		e = next
+	}
+	return e
+}
+
+// text returns the value of the descendant along the given path.
+func (e *element) text(path ...string) string {
+	if c := e.child(path...); c != nil {
+		return c.value
+	}
+	return ""
+}
+
+// findAll returns all descendants with the given name, in document order.
+func (e *element) findAll(name string) []*element {
+	var res []*element
+	for _, c := range e.children {
+		if c.name == name {
+			res = append(res, c)
+		} else {
+			res = append(res, c.findAll(name)...)
+		}
+	}
+	return res
+}
+
+// parse parses an OFX document. It supports both the SGML format of OFX
+// 1.x, where closing tags of leaf elements are omitted, and the XML format
+// of OFX 2.x.
+func parse(r io.Reader) (*element, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	start := bytes.Index(b, []byte("<OFX>"))
+	if start < 0 {
+		return nil, fmt.Errorf("no <OFX> element found")
+	}
+	header, body := string(b[:start]), b[start:]
+	if body, err = decode(header, body); err != nil {
+		return nil, err
+	}
+	var (
+		root  = new(element)
+		stack = []*element{root}
+		s     = string(body)
+	)
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			break
+		}
+		if text := strings.TrimSpace(s[:i]); text != "" && len(stack) > 1 {
+			top := stack[len(stack)-1]
+			top.value = html.UnescapeString(text)
+			// a leaf element: its closing tag is optional, so close it here
+			stack = stack[:len(stack)-1]
+		}
+		j := strings.IndexByte(s[i:], '>')
+		if j < 0 {
+			return nil, fmt.Errorf("unterminated tag: %q", s[i:])
+		}
+		tag := strings.TrimSpace(s[i+1 : i+j])
+		s = s[i+j+1:]
+		switch {
+		case tag == "" || tag[0] == '?' || tag[0] == '!':
+			continue
+		case tag[0] == '/':
+			name := tag[1:]
+			for k := len(stack) - 1; k > 0; k-- {
+				if stack[k].name == name {
+					stack = stack[:k]
+					break
+				}
+			}
+		default:
+			e := &element{name: tag}
+			top := stack[len(stack)-1]
+			top.children = append(top.children, e)
+			stack = append(stack, e)
+		}
+	}
+	ofx := root.child("OFX")
+	if ofx == nil {
+		return nil, fmt.Errorf("no <OFX> element found")
+	}
+	return ofx, nil
+}
+
+// decode converts the body to UTF-8, based on the header of an OFX 1.x
+// file. OFX 2.x files are always UTF-8.
+func decode(header string, body []byte) ([]byte, error) {
+	if strings.Contains(header, "<?xml") || strings.Contains(header, "ENCODING:UTF-8") {
+		return body, nil
+	}
+	switch {
+	case strings.Contains(header, "CHARSET:1252"):
+		return charmap.Windows1252.NewDecoder().Bytes(body)
+	case strings.Contains(header, "CHARSET:ISO-8859-1"), strings.Contains(header, "CHARSET:8859-1"):
+		return charmap.ISO8859_1.NewDecoder().Bytes(body)
+	}
+	return body, nil
+}
@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/natefinch/atomic"
+	"gopkg.in/yaml.v2"
+
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sboehler/knut/lib/syntax/directives"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+// Routing sends imported directives to files by date.
+type Routing struct {
+	// Journal is the main journal, which includes the files. A relative
+	// path is relative to the directory of the routing config.
+	Journal string `yaml:"journal"`
+	// Path is the path of the file for a date, relative to the directory of
+	// the main journal. The placeholders {year}, {month} and {day} are
+	// replaced by the date, e.g. "{year}/{year}-{month}.knut".
+	Path string `yaml:"path"`
+}
+
+var placeholders = regexp.MustCompile(`\{[^}]*\}`)
+
+// file returns the path of the file for the given date.
+func (r *Routing) file(t time.Time) string {
+	return path.Clean(strings.NewReplacer(
+		"{year}", t.Format("2006"),
+		"{month}", t.Format("01"),
+		"{day}", t.Format("02"),
+	).Replace(r.Path))
+}
+
+// ReadRouting reads a routing config from a YAML file.
+func ReadRouting(p string) (*Routing, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.SetStrict(true)
+	var r Routing
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", p, err)
+	}
+	if r.Journal == "" || r.Path == "" {
+		return nil, fmt.Errorf("error reading %s: journal and path are required", p)
+	}
+	for _, ph := range placeholders.FindAllString(r.Path, -1) {
+		if ph != "{year}" && ph != "{month}" && ph != "{day}" {
+			return nil, fmt.Errorf("error reading %s: invalid placeholder %s in path, expected {year}, {month} or {day}", p, ph)
+		}
+	}
+	if !filepath.IsAbs(r.Journal) {
+		r.Journal = filepath.Join(filepath.Dir(p), r.Journal)
+	}
+	return &r, nil
+}
+
+// Write appends the days of the journal to the files given by the routing
+// and includes new files in the main journal. It returns the written
+// files.
+func (r *Routing) Write(j *journal.Journal) ([]string, error) {
+	var (
+		paths []string
+		days  = make(map[string][]*journal.Day)
+	)
+	for _, d := range j.Days {
+		if isEmpty(d) {
+			continue
+		}
+		p := r.file(d.Date)
+		if _, ok := days[p]; !ok {
+			paths = append(paths, p)
+		}
+		days[p] = append(days[p], d)
+	}
+	sort.Strings(paths)
+	var res []string
+	for _, p := range paths {
+		file := filepath.Join(filepath.Dir(r.Journal), filepath.FromSlash(p))
+		if err := WriteFile(file, &journal.Journal{Days: days[p]}, true); err != nil {
+			return nil, err
+		}
+		res = append(res, file)
+	}
+	if err := r.include(paths); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func isEmpty(d *journal.Day) bool {
+	return len(d.Prices) == 0 && len(d.Splits) == 0 && len(d.Renames) == 0 &&
+		len(d.Assertions) == 0 && len(d.Openings) == 0 && len(d.Transactions) == 0 &&
+		len(d.Closings) == 0
+}
+
+// include adds include directives for the given paths to the main journal,
+// unless they are already included.
+func (r *Routing) include(paths []string) error {
+	text, err := os.ReadFile(r.Journal)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	f, err := parse(r.Journal, string(text))
+	if err != nil {
+		return err
+	}
+	included := make(map[string]bool)
+	for _, d := range f.Directives {
+		if inc, ok := d.Directive.(directives.Include); ok {
+			included[path.Clean(inc.IncludePath.Content.Extract())] = true
+		}
+	}
+	var b strings.Builder
+	for _, p := range paths {
+		if !included[p] {
+			fmt.Fprintf(&b, "include \"%s\"\n", p)
+		}
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	return write(r.Journal, join(text, b.String()))
+}
+
+// WriteFile writes the journal to the given file. If appendTo is true, the
+// journal is appended to the existing content of the file. The result is
+// formatted and written atomically.
+func WriteFile(file string, j *journal.Journal, appendTo bool) error {
+	var buf bytes.Buffer
+	if err := journal.Print(&buf, j); err != nil {
+		return err
+	}
+	var text []byte
+	if appendTo {
+		var err error
+		if text, err = os.ReadFile(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return write(file, join(text, buf.String()))
+}
+
+// join joins existing text and new directives, separated by an empty line.
+func join(text []byte, s string) string {
+	t := strings.TrimRight(string(text), "\n")
+	if t == "" {
+		return s
+	}
+	return t + "\n\n" + s
+}
+
+func write(file, text string) error {
+	f, err := parse(file, text)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := syntax.FormatFile(&buf, f); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return atomic.WriteFile(file, &buf)
+}
+
+func parse(file, text string) (syntax.File, error) {
+	p := parser.New(text, file)
+	if err := p.Advance(); err != nil {
+		return syntax.File{}, err
+	}
+	return p.ParseFile()
+}
@@ -0,0 +1,104 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+func TestRoutingWrite(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		reg  = registry.New()
+		bank = reg.Accounts().MustGet("Assets:Bank")
+		tbd  = reg.Accounts().TBDAccount()
+		chf  = reg.Commodities().MustGet("CHF")
+	)
+	build := func(days ...int) *journal.Journal {
+		b := journal.New()
+		for _, d := range days {
+			b.Add(transaction.Builder{
+				Date:        date.Date(2023, 1, d),
+				Description: "foo",
+				Postings: posting.Builder{
+					Credit:    bank,
+					Debit:     tbd,
+					Commodity: chf,
+					Quantity:  decimal.NewFromInt(int64(d)),
+				}.Build(),
+			}.Build())
+		}
+		return b.Build()
+	}
+	writeTestFile(t, filepath.Join(dir, "main.knut"), "// main\ninclude \"bank1/2023/2023-01-31.knut\"\n")
+	writeTestFile(t, filepath.Join(dir, "bank1", "2023", "2023-01-31.knut"), "// existing\n")
+	writeTestFile(t, filepath.Join(dir, "routing.yaml"), "journal: main.knut\npath: bank1/{year}/{year}-{month}-{day}.knut\n")
+	r, err := ReadRouting(filepath.Join(dir, "routing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Write(build(31)); err != nil {
+		t.Fatalf("Write() returned unexpected error: %v", err)
+	}
+	got, err := r.Write(build(1, 31, 1))
+	if err != nil {
+		t.Fatalf("Write() returned unexpected error: %v", err)
+	}
+
+	want := []string{
+		filepath.Join(dir, "bank1", "2023", "2023-01-01.knut"),
+		filepath.Join(dir, "bank1", "2023", "2023-01-31.knut"),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Write() returned unexpected diff (-want/+got):\n%s\n", diff)
+	}
+	wantFiles := map[string]string{
+		"main.knut": "// main\ninclude \"bank1/2023/2023-01-31.knut\"\n\ninclude \"bank1/2023/2023-01-01.knut\"\n",
+		"bank1/2023/2023-01-01.knut": "2023-01-01 \"foo\"\nAssets:Bank  Expenses:TBD          1 CHF\n\n" +
+			"2023-01-01 \"foo\"\nAssets:Bank  Expenses:TBD          1 CHF\n\n",
+		"bank1/2023/2023-01-31.knut": "// existing\n\n" +
+			"2023-01-31 \"foo\"\nAssets:Bank  Expenses:TBD         31 CHF\n\n" +
+			"2023-01-31 \"foo\"\nAssets:Bank  Expenses:TBD         31 CHF\n\n",
+	}
+	for name, want := range wantFiles {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, string(got)); diff != "" {
+			t.Fatalf("%s has unexpected diff (-want/+got):\n%s\n", name, diff)
+		}
+	}
+}
+
+func TestReadRoutingInvalidPlaceholder(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "routing.yaml"), "journal: main.knut\npath: \"{year}/{week}.knut\"\n")
+
+	_, err := ReadRouting(filepath.Join(dir, "routing.yaml"))
+
+	if err == nil || !strings.Contains(err.Error(), "{week}") {
+		t.Fatalf("ReadRouting() returned error %v, want an error about {week}", err)
+	}
+}
+
+func writeTestFile(t *testing.T, file, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
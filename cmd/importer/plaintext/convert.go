@@ -0,0 +1,501 @@
+package plaintext
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// Converter converts entries to a knut journal. Transactions with more than
+// two postings are converted to bookings between two accounts by pairing
+// the postings of every commodity. Amounts which cannot be paired, e.g.
+// the two sides of a currency exchange, are booked against the balancing
+// account. Accounts which are used without being opened are opened at their
+// first use. Notes are kept as comments of the open directive of their
+// account.
+type Converter struct {
+	Registry  *model.Registry
+	Balancing *model.Account
+
+	builder  *journal.Builder
+	balances amounts.Amounts
+	opened   map[*model.Account]bool
+	notes    map[*model.Account][]string
+	pads     map[*model.Account]*pad
+	pending  []assertion
+	day      time.Time
+}
+
+type pad struct {
+	*Pad
+	source *model.Account
+	done   map[*model.Commodity]bool
+}
+
+// assertion is a balance assertion after a posting, which is converted to
+// an assertion at the end of the day.
+type assertion struct {
+	account   *model.Account
+	commodity *model.Commodity
+	quantity  decimal.Decimal
+	balance   decimal.Decimal
+}
+
+// Convert converts the given entries.
+func (c *Converter) Convert(es []Entry) (*journal.Journal, error) {
+	c.builder = journal.New()
+	c.balances = make(amounts.Amounts)
+	c.opened = make(map[*model.Account]bool)
+	c.notes = make(map[*model.Account][]string)
+	c.pads = make(map[*model.Account]*pad)
+	c.pending = nil
+
+	es = append([]Entry(nil), es...)
+	sort.SliceStable(es, func(i, j int) bool {
+		di, dj := date(es[i]), date(es[j])
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		return rank(es[i]) < rank(es[j])
+	})
+	for _, e := range es {
+		switch t := e.(type) {
+		case *Open:
+			a, err := c.account(t.Account)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", t.Pos, err)
+			}
+			c.opened[a] = true
+		case *Note:
+			a, err := c.account(t.Account)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", t.Pos, err)
+			}
+			c.notes[a] = append(c.notes[a], t.Comments...)
+			c.notes[a] = append(c.notes[a], fmt.Sprintf("%s note: %s", t.Date.Format("2006-01-02"), t.Text))
+		}
+	}
+	for i, e := range es {
+		if i == 0 || !date(e).Equal(c.day) {
+			if err := c.flush(); err != nil {
+				return nil, err
+			}
+			c.day = date(e)
+		}
+		if err := c.convert(e); err != nil {
+			return nil, fmt.Errorf("%s: %w", pos(e), err)
+		}
+	}
+	if err := c.flush(); err != nil {
+		return nil, err
+	}
+	return c.builder.Build(), nil
+}
+
+func date(e Entry) time.Time {
+	switch t := e.(type) {
+	case *Transaction:
+		return t.Date
+	case *Open:
+		return t.Date
+	case *Close:
+		return t.Date
+	case *Balance:
+		return t.Date
+	case *Price:
+		return t.Date
+	case *Pad:
+		return t.Date
+	case *Note:
+		return t.Date
+	}
+	return time.Time{}
+}
+
+func pos(e Entry) string {
+	switch t := e.(type) {
+	case *Transaction:
+		return t.Pos
+	case *Open:
+		return t.Pos
+	case *Close:
+		return t.Pos
+	case *Balance:
+		return t.Pos
+	case *Price:
+		return t.Pos
+	case *Pad:
+		return t.Pos
+	case *Note:
+		return t.Pos
+	}
+	return ""
+}
+
+// rank orders the entries of a day. Balances hold at the beginning of the
+// day.
+func rank(e Entry) int {
+	switch e.(type) {
+	case *Balance:
+		return 0
+	case *Open:
+		return 1
+	case *Pad:
+		return 2
+	case *Transaction:
+		return 3
+	case *Price:
+		return 4
+	}
+	return 5
+}
+
+func (c *Converter) convert(e Entry) error {
+	switch t := e.(type) {
+	case *Transaction:
+		return c.convertTransaction(t)
+	case *Open:
+		a, err := c.account(t.Account)
+		if err != nil {
+			return err
+		}
+		comments := append(append([]string(nil), t.Comments...), c.notes[a]...)
+		return c.builder.Add(&model.Open{Date: t.Date, Account: a, Comments: comments})
+	case *Close:
+		a, err := c.account(t.Account)
+		if err != nil {
+			return err
+		}
+		return c.builder.Add(&model.Close{Date: t.Date, Account: a, Comments: t.Comments})
+	case *Price:
+		return c.convertPrice(t)
+	case *Pad:
+		a, err := c.account(t.Account)
+		if err != nil {
+			return err
+		}
+		source, err := c.account(t.Source)
+		if err != nil {
+			return err
+		}
+		c.pads[a] = &pad{Pad: t, source: source, done: make(map[*model.Commodity]bool)}
+		return nil
+	case *Balance:
+		return c.convertBalance(t)
+	case *Note:
+		// the note is kept with the open directive
+		a, err := c.account(t.Account)
+		if err != nil {
+			return err
+		}
+		return c.use(a, t.Date)
+	}
+	return fmt.Errorf("unknown entry %T", e)
+}
+
+func (c *Converter) convertPrice(p *Price) error {
+	com, err := c.commodity(p.Commodity)
+	if err != nil {
+		return err
+	}
+	target, err := c.commodity(p.Price.Commodity)
+	if err != nil {
+		return err
+	}
+	return c.builder.Add(&model.Price{
+		Date:      p.Date,
+		Commodity: com,
+		Price:     p.Price.Quantity,
+		Target:    target,
+		Comments:  p.Comments,
+	})
+}
+
+func (c *Converter) convertBalance(b *Balance) error {
+	a, err := c.account(b.Account)
+	if err != nil {
+		return err
+	}
+	com, err := c.commodity(b.Amount.Commodity)
+	if err != nil {
+		return err
+	}
+	k := amounts.AccountCommodityKey(a, com)
+	if p, ok := c.pads[a]; ok && !p.done[com] {
+		p.done[com] = true
+		if diff := b.Amount.Quantity.Sub(c.balances[k]); !diff.IsZero() {
+			if err := c.use(p.source, p.Date); err != nil {
+				return err
+			}
+			if err := c.use(a, p.Date); err != nil {
+				return err
+			}
+			c.balances.Add(k, diff)
+			c.balances.Add(amounts.AccountCommodityKey(p.source, com), diff.Neg())
+			err := c.builder.Add(transaction.Builder{
+				Date:        p.Date,
+				Description: fmt.Sprintf("Padding %s", a.Name()),
+				Postings: posting.Builder{
+					Credit:    p.source,
+					Debit:     a,
+					Commodity: com,
+					Quantity:  diff,
+				}.Build(),
+				Comments: p.Comments,
+			}.Build())
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// knut checks balances at the end of the day
+	d := b.Date.AddDate(0, 0, -1)
+	if err := c.use(a, d); err != nil {
+		return err
+	}
+	return c.builder.Add(&model.Assertion{
+		Date: d,
+		Balances: []model.Balance{{
+			Account:   a,
+			Commodity: com,
+			Quantity:  b.Amount.Quantity,
+		}},
+		Comments: b.Comments,
+	})
+}
+
+// flush adds the assertions after postings of the current day, adjusted by
+// the postings after them.
+func (c *Converter) flush() error {
+	for _, a := range c.pending {
+		bal := c.balances[amounts.AccountCommodityKey(a.account, a.commodity)]
+		err := c.builder.Add(&model.Assertion{
+			Date: c.day,
+			Balances: []model.Balance{{
+				Account:   a.account,
+				Commodity: a.commodity,
+				Quantity:  a.quantity.Add(bal).Sub(a.balance),
+			}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	c.pending = nil
+	return nil
+}
+
+// use opens the account at the given date, unless it is opened explicitly.
+func (c *Converter) use(a *model.Account, d time.Time) error {
+	if c.opened[a] {
+		return nil
+	}
+	c.opened[a] = true
+	return c.builder.Add(&model.Open{Date: d, Account: a, Comments: c.notes[a]})
+}
+
+type leg struct {
+	account   *model.Account
+	commodity *model.Commodity
+	quantity  decimal.Decimal
+}
+
+func (c *Converter) convertTransaction(t *Transaction) error {
+	var (
+		legs        []leg
+		elided      *model.Account
+		weights     = make(map[*model.Commodity]decimal.Decimal)
+		commodities []*model.Commodity
+	)
+	addWeight := func(com *model.Commodity, q decimal.Decimal) {
+		if _, ok := weights[com]; !ok {
+			commodities = append(commodities, com)
+		}
+		weights[com] = weights[com].Add(q)
+	}
+	add := func(a *model.Account, com *model.Commodity, q decimal.Decimal) {
+		legs = append(legs, leg{a, com, q})
+		c.balances.Add(amounts.AccountCommodityKey(a, com), q)
+	}
+	for i, p := range t.Postings {
+		a, err := c.account(p.Account)
+		if err != nil {
+			return fmt.Errorf("posting %d: %w", i+1, err)
+		}
+		amount := p.Amount
+		if amount == nil && p.Assertion != nil {
+			// the amount is given by the balance after the posting
+			com, err := c.commodity(p.Assertion.Commodity)
+			if err != nil {
+				return fmt.Errorf("posting %d: %w", i+1, err)
+			}
+			bal := c.balances[amounts.AccountCommodityKey(a, com)]
+			amount = &Amount{Quantity: p.Assertion.Quantity.Sub(bal), Commodity: p.Assertion.Commodity}
+		}
+		if amount == nil {
+			if elided != nil {
+				return fmt.Errorf("posting %d: more than one posting without amount", i+1)
+			}
+			elided = a
+			continue
+		}
+		com, err := c.commodity(amount.Commodity)
+		if err != nil {
+			return fmt.Errorf("posting %d: %w", i+1, err)
+		}
+		add(a, com, amount.Quantity)
+		if err := c.weigh(p, amount, addWeight); err != nil {
+			return fmt.Errorf("posting %d: %w", i+1, err)
+		}
+		if p.Assertion != nil {
+			if err := c.assert(a, p.Assertion); err != nil {
+				return fmt.Errorf("posting %d: %w", i+1, err)
+			}
+		}
+	}
+	if elided != nil {
+		for _, com := range commodities {
+			if w := weights[com]; !w.IsZero() {
+				add(elided, com, w.Neg())
+			}
+		}
+	}
+	bookings := pair(legs, c.Balancing)
+	if len(bookings) == 0 {
+		return nil
+	}
+	for _, b := range bookings {
+		if err := c.use(b.Credit, t.Date); err != nil {
+			return err
+		}
+		if err := c.use(b.Debit, t.Date); err != nil {
+			return err
+		}
+	}
+	return c.builder.Add(transaction.Builder{
+		Date:        t.Date,
+		Description: strings.ReplaceAll(t.Description, `"`, "'"),
+		Postings:    bookings.Build(),
+		Comments:    t.Comments,
+	}.Build())
+}
+
+// weigh adds the weight of a posting, i.e. the amount which must be
+// balanced by the other postings of the transaction.
+func (c *Converter) weigh(p Posting, amount *Amount, add func(*model.Commodity, decimal.Decimal)) error {
+	unit := p.Cost
+	if unit == nil {
+		unit = p.Price
+	}
+	if unit == nil {
+		com, err := c.commodity(amount.Commodity)
+		if err != nil {
+			return err
+		}
+		add(com, amount.Quantity)
+		return nil
+	}
+	com, err := c.commodity(unit.Commodity)
+	if err != nil {
+		return err
+	}
+	add(com, amount.Quantity.Mul(unit.Quantity))
+	return nil
+}
+
+func (c *Converter) assert(a *model.Account, amount *Amount) error {
+	com, err := c.commodity(amount.Commodity)
+	if err != nil {
+		return err
+	}
+	if err := c.use(a, c.day); err != nil {
+		return err
+	}
+	c.pending = append(c.pending, assertion{
+		account:   a,
+		commodity: com,
+		quantity:  amount.Quantity,
+		balance:   c.balances[amounts.AccountCommodityKey(a, com)],
+	})
+	return nil
+}
+
+// pair converts legs to bookings. Legs of the same commodity are paired,
+// opposite legs of equal amounts first. The remaining amounts are booked
+// against the balancing account.
+func pair(legs []leg, balancing *model.Account) posting.Builders {
+	var (
+		res         posting.Builders
+		commodities []*model.Commodity
+		byCommodity = make(map[*model.Commodity][]leg)
+	)
+	for _, l := range legs {
+		if l.quantity.IsZero() {
+			continue
+		}
+		if _, ok := byCommodity[l.commodity]; !ok {
+			commodities = append(commodities, l.commodity)
+		}
+		byCommodity[l.commodity] = append(byCommodity[l.commodity], l)
+	}
+	book := func(credit, debit *model.Account, com *model.Commodity, q decimal.Decimal) {
+		if credit != debit {
+			res = append(res, posting.Builder{Credit: credit, Debit: debit, Commodity: com, Quantity: q})
+		}
+	}
+	for _, com := range commodities {
+		var debits, credits []leg
+		for _, l := range byCommodity[com] {
+			if l.quantity.IsPositive() {
+				debits = append(debits, l)
+			} else {
+				credits = append(credits, leg{l.account, l.commodity, l.quantity.Neg()})
+			}
+		}
+		for i := range debits {
+			for j := range credits {
+				if !credits[j].quantity.IsZero() && credits[j].quantity.Equal(debits[i].quantity) {
+					book(credits[j].account, debits[i].account, com, debits[i].quantity)
+					debits[i].quantity, credits[j].quantity = decimal.Zero, decimal.Zero
+					break
+				}
+			}
+		}
+		i, j := 0, 0
+		for {
+			for i < len(debits) && debits[i].quantity.IsZero() {
+				i++
+			}
+			for j < len(credits) && credits[j].quantity.IsZero() {
+				j++
+			}
+			if i == len(debits) || j == len(credits) {
+				break
+			}
+			q := decimal.Min(debits[i].quantity, credits[j].quantity)
+			book(credits[j].account, debits[i].account, com, q)
+			debits[i].quantity = debits[i].quantity.Sub(q)
+			credits[j].quantity = credits[j].quantity.Sub(q)
+		}
+		for ; i < len(debits); i++ {
+			if !debits[i].quantity.IsZero() {
+				book(balancing, debits[i].account, com, debits[i].quantity)
+			}
+		}
+		for ; j < len(credits); j++ {
+			if !credits[j].quantity.IsZero() {
+				book(credits[j].account, balancing, com, credits[j].quantity)
+			}
+		}
+	}
+	return res
+}
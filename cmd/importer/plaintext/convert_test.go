@@ -0,0 +1,193 @@
+package plaintext
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+func TestPair(t *testing.T) {
+	tests := []struct {
+		desc string
+		legs []string
+		want []string
+	}{
+		{
+			desc: "two legs",
+			legs: []string{"Assets:A 10 USD", "Assets:B -10 USD"},
+			want: []string{"Assets:B Assets:A 10 USD"},
+		},
+		{
+			desc: "multiple legs",
+			legs: []string{"Assets:A 10 USD", "Assets:B 5 USD", "Assets:C -15 USD"},
+			want: []string{"Assets:C Assets:A 10 USD", "Assets:C Assets:B 5 USD"},
+		},
+		{
+			desc: "equal amounts first",
+			legs: []string{"Assets:A 5 USD", "Assets:B 10 USD", "Assets:C -10 USD", "Assets:D -5 USD"},
+			want: []string{"Assets:D Assets:A 5 USD", "Assets:C Assets:B 10 USD"},
+		},
+		{
+			desc: "partial pairing",
+			legs: []string{"Assets:A 10 USD", "Assets:B -4 USD", "Assets:C -4 USD"},
+			want: []string{"Assets:B Assets:A 4 USD", "Assets:C Assets:A 4 USD", "Equity:Conversions Assets:A 2 USD"},
+		},
+		{
+			desc: "unbalanced commodities",
+			legs: []string{"Assets:A 10 USD", "Assets:B -9 EUR"},
+			want: []string{"Equity:Conversions Assets:A 10 USD", "Assets:B Equity:Conversions 9 EUR"},
+		},
+		{
+			desc: "zero and same account",
+			legs: []string{"Assets:A 0 USD", "Assets:B 10 USD", "Assets:B -10 USD"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			reg := registry.New()
+			var legs []leg
+			for _, s := range test.legs {
+				fields := strings.Fields(s)
+				legs = append(legs, leg{
+					account:   reg.Accounts().MustGet(fields[0]),
+					commodity: reg.Commodities().MustGet(fields[2]),
+					quantity:  decimal.RequireFromString(fields[1]),
+				})
+			}
+
+			bookings := pair(legs, reg.Accounts().MustGet("Equity:Conversions"))
+
+			var got []string
+			for _, b := range bookings {
+				got = append(got, fmt.Sprintf("%s %s %s %s", b.Credit.Name(), b.Debit.Name(), b.Quantity, b.Commodity.Name()))
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Fatalf("pair() returned unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	var (
+		jan = func(d int) time.Time { return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC) }
+		usd = func(q string) *Amount { return &Amount{Quantity: decimal.RequireFromString(q), Commodity: "USD"} }
+	)
+	tests := []struct {
+		desc    string
+		entries []Entry
+		want    []string
+	}{
+		{
+			desc: "pad and balance",
+			entries: []Entry{
+				&Open{Date: jan(1), Account: "Assets:Checking"},
+				&Pad{Date: jan(1), Account: "Assets:Checking", Source: "Equity:Opening"},
+				&Transaction{Date: jan(2), Description: "Salary", Postings: []Posting{
+					{Account: "Assets:Checking", Amount: usd("10")},
+					{Account: "Income:Salary", Amount: usd("-10")},
+				}},
+				&Balance{Date: jan(5), Account: "Assets:Checking", Amount: *usd("100")},
+			},
+			want: []string{
+				"2020-01-01 open Assets:Checking",
+				"2020-01-01 open Equity:Opening",
+				"",
+				`2020-01-01 "Padding Assets:Checking"`,
+				"Equity:Opening  Assets:Checking         90 USD",
+				"",
+				"2020-01-02 open Income:Salary",
+				"",
+				`2020-01-02 "Salary"`,
+				"Income:Salary   Assets:Checking         10 USD",
+				"",
+				"2020-01-04 balance Assets:Checking 100 USD",
+				"",
+				"",
+			},
+		},
+		{
+			desc: "balance without difference",
+			entries: []Entry{
+				&Pad{Date: jan(1), Account: "Assets:Checking", Source: "Equity:Opening"},
+				&Balance{Date: jan(2), Account: "Assets:Checking", Amount: *usd("0")},
+			},
+			want: []string{
+				"2020-01-01 open Assets:Checking",
+				"",
+				"2020-01-01 balance Assets:Checking 0 USD",
+				"",
+				"",
+			},
+		},
+		{
+			desc: "elided amount and posting assertions",
+			entries: []Entry{
+				&Transaction{Date: jan(1), Description: "Deposit", Postings: []Posting{
+					{Account: "Assets:Checking", Amount: usd("50"), Assertion: usd("50")},
+					{Account: "Equity:Opening"},
+				}},
+				&Transaction{Date: jan(1), Description: "Groceries", Postings: []Posting{
+					{Account: "Assets:Checking", Assertion: usd("30")},
+					{Account: "Expenses:Groceries"},
+				}},
+			},
+			want: []string{
+				"2020-01-01 open Assets:Checking",
+				"2020-01-01 open Equity:Opening",
+				"2020-01-01 open Expenses:Groceries",
+				"",
+				`2020-01-01 "Deposit"`,
+				"Equity:Opening     Assets:Checking            50 USD",
+				"",
+				`2020-01-01 "Groceries"`,
+				"Assets:Checking    Expenses:Groceries         20 USD",
+				"",
+				"2020-01-01 balance Assets:Checking 30 USD",
+				"2020-01-01 balance Assets:Checking 30 USD",
+				"",
+				"",
+			},
+		},
+		{
+			desc: "note",
+			entries: []Entry{
+				&Open{Date: jan(1), Account: "Assets:Checking", Comments: []string{"bank: ACME"}},
+				&Note{Date: jan(3), Account: "Assets:Checking", Text: "Called the bank"},
+			},
+			want: []string{
+				"# bank: ACME",
+				"# 2020-01-03 note: Called the bank",
+				"2020-01-01 open Assets:Checking",
+				"",
+				"",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			reg := registry.New()
+			c := Converter{Registry: reg, Balancing: reg.Accounts().MustGet("Equity:Conversions")}
+
+			j, err := c.Convert(test.entries)
+
+			if err != nil {
+				t.Fatalf("Convert() returned unexpected error: %v", err)
+			}
+			var got strings.Builder
+			if err := journal.Print(&got, j); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(strings.Join(test.want, "\n"), got.String()); diff != "" {
+				t.Fatalf("Convert() returned unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Package plaintext converts the entries of the journals of other plain
+// text accounting tools, such as beancount or ledger, to knut directives.
+package plaintext
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Entry is one of *Transaction, *Open, *Close, *Balance, *Price, *Pad or
+// *Note.
+type Entry any
+
+// Amount is a quantity of a commodity, as written in the source journal.
+type Amount struct {
+	Quantity  decimal.Decimal
+	Commodity string
+}
+
+// Transaction is a transaction with any number of postings.
+type Transaction struct {
+	Pos         string
+	Date        time.Time
+	Description string
+	Postings    []Posting
+	Comments    []string
+}
+
+// Posting is a posting of a transaction.
+type Posting struct {
+	Account string
+
+	// Amount is nil if the amount is elided.
+	Amount *Amount
+
+	// Cost is the cost per unit, and Price the price per unit, if any.
+	// They are only used to determine the amount of an elided posting.
+	Cost, Price *Amount
+
+	// Assertion is the balance of the account after the posting, if any.
+	// If Amount is nil, the amount is the difference to the balance
+	// before the posting.
+	Assertion *Amount
+}
+
+// Open opens an account.
+type Open struct {
+	Pos      string
+	Date     time.Time
+	Account  string
+	Comments []string
+}
+
+// Close closes an account.
+type Close struct {
+	Pos      string
+	Date     time.Time
+	Account  string
+	Comments []string
+}
+
+// Balance asserts the balance of an account at the beginning of a day.
+type Balance struct {
+	Pos      string
+	Date     time.Time
+	Account  string
+	Amount   Amount
+	Comments []string
+}
+
+// Price is the price of a commodity.
+type Price struct {
+	Pos       string
+	Date      time.Time
+	Commodity string
+	Price     Amount
+	Comments  []string
+}
+
+// Pad inserts a transaction from Source to Account, such that the next
+// balance assertion of Account holds.
+type Pad struct {
+	Pos             string
+	Date            time.Time
+	Account, Source string
+	Comments        []string
+}
+
+// Note is a note about an account.
+type Note struct {
+	Pos      string
+	Date     time.Time
+	Account  string
+	Text     string
+	Comments []string
+}
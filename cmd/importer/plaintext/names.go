@@ -0,0 +1,98 @@
+package plaintext
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sboehler/knut/lib/model"
+)
+
+// accountTypes maps lower case root accounts to knut's account types.
+var accountTypes = map[string]string{
+	"assets":      "Assets",
+	"asset":       "Assets",
+	"liabilities": "Liabilities",
+	"liability":   "Liabilities",
+	"equity":      "Equity",
+	"income":      "Income",
+	"revenue":     "Income",
+	"revenues":    "Income",
+	"expenses":    "Expenses",
+	"expense":     "Expenses",
+}
+
+// symbols maps currency symbols to commodities.
+var symbols = map[string]string{
+	"$": "USD",
+	"€": "EUR",
+	"£": "GBP",
+	"¥": "JPY",
+	"₹": "INR",
+	"₽": "RUB",
+	"₩": "KRW",
+	"₿": "BTC",
+}
+
+// AccountName converts an account name to a valid knut account name. The
+// root account is mapped to an account type, and the characters of every
+// segment which are not letters or digits are removed. The words of a
+// segment are capitalized, e.g. "expenses:dining out" becomes
+// "Expenses:DiningOut".
+func AccountName(name string) (string, error) {
+	segments := strings.Split(name, ":")
+	t, ok := accountTypes[strings.ToLower(strings.TrimSpace(segments[0]))]
+	if !ok {
+		return "", fmt.Errorf("account %s: unknown account type %q", name, segments[0])
+	}
+	res := []string{t}
+	for _, s := range segments[1:] {
+		var b strings.Builder
+		for _, w := range strings.FieldsFunc(s, isSeparator) {
+			r, n := utf8.DecodeRuneInString(w)
+			b.WriteRune(unicode.ToUpper(r))
+			b.WriteString(w[n:])
+		}
+		if b.Len() == 0 {
+			return "", fmt.Errorf("account %s: invalid segment %q", name, s)
+		}
+		res = append(res, b.String())
+	}
+	return strings.Join(res, ":"), nil
+}
+
+// CommodityName converts a commodity to a valid knut commodity name.
+// Currency symbols are mapped to their ISO codes, and other characters
+// which are not letters or digits are removed.
+func CommodityName(name string) (string, error) {
+	name = strings.Trim(strings.TrimSpace(name), `"`)
+	if s, ok := symbols[name]; ok {
+		return s, nil
+	}
+	res := strings.Join(strings.FieldsFunc(name, isSeparator), "")
+	if res == "" {
+		return "", fmt.Errorf("invalid commodity %q", name)
+	}
+	return res, nil
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func (c *Converter) account(name string) (*model.Account, error) {
+	n, err := AccountName(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.Registry.Accounts().Get(n)
+}
+
+func (c *Converter) commodity(name string) (*model.Commodity, error) {
+	n, err := CommodityName(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.Registry.Commodities().Get(n)
+}
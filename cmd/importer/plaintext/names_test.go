@@ -0,0 +1,65 @@
+package plaintext
+
+import "testing"
+
+func TestAccountName(t *testing.T) {
+	tests := []struct {
+		name, want string
+		wantErr    bool
+	}{
+		{name: "Assets:Checking", want: "Assets:Checking"},
+		{name: "expenses:dining out", want: "Expenses:DiningOut"},
+		{name: "Revenue:Salary-2020", want: "Income:Salary2020"},
+		{name: "Liabilities:Credit_Card:Visa", want: "Liabilities:CreditCard:Visa"},
+		{name: "Foo:Bar", wantErr: true},
+		{name: "Assets:--", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := AccountName(test.name)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("AccountName(%q) = %q, want error", test.name, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AccountName(%q) returned unexpected error: %v", test.name, err)
+			}
+			if got != test.want {
+				t.Fatalf("AccountName(%q) = %q, want %q", test.name, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCommodityName(t *testing.T) {
+	tests := []struct {
+		name, want string
+		wantErr    bool
+	}{
+		{name: "USD", want: "USD"},
+		{name: "$", want: "USD"},
+		{name: "€", want: "EUR"},
+		{name: `"VANGUARD 500"`, want: "VANGUARD500"},
+		{name: "BRK.B", want: "BRKB"},
+		{name: "--", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := CommodityName(test.name)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("CommodityName(%q) = %q, want error", test.name, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CommodityName(%q) returned unexpected error: %v", test.name, err)
+			}
+			if got != test.want {
+				t.Fatalf("CommodityName(%q) = %q, want %q", test.name, got, test.want)
+			}
+		})
+	}
+}
@@ -91,7 +91,7 @@ func (r *runner) runE(cmd *cobra.Command, args []string) error {
 	}
 	out := bufio.NewWriter(cmd.OutOrStdout())
 	defer out.Flush()
-	return journal.Print(out, p.builder.Build())
+	return importer.Print(cmd, out, p.builder.Build())
 }
 
 func init() {
@@ -106,6 +106,7 @@ type Parser struct {
 	builder  *journal.Builder
 
 	currency *model.Commodity
+	balances *importer.Balances
 }
 
 func (p *Parser) parse() error {
@@ -113,6 +114,7 @@ func (p *Parser) parse() error {
 	p.reader.TrimLeadingSpace = true
 	p.reader.Comma = ';'
 	p.reader.FieldsPerRecord = -1
+	p.balances = importer.NewBalances(p.account)
 
 	kv, err := p.readKeyValues()
 	if err != nil {
@@ -135,6 +137,9 @@ func (p *Parser) parse() error {
 			break
 		}
 	}
+	for _, a := range p.balances.Assertions() {
+		p.builder.Add(a)
+	}
 	for {
 		err := p.readDisclaimer()
 		if err == io.EOF {
@@ -205,6 +210,13 @@ func (p *Parser) readBookingLine() (bool, error) {
 			Quantity:  quantity,
 		}.Build(),
 	}.Build())
+	if len(rec) > int(bfSaldoInCHF) && rec[bfSaldoInCHF] != "" {
+		balance, err := decimal.NewFromString(strings.ReplaceAll(rec[bfSaldoInCHF], "'", ""))
+		if err != nil {
+			return false, fmt.Errorf("invalid balance %q: %w", rec[bfSaldoInCHF], err)
+		}
+		p.balances.Add(date, p.currency, quantity, balance)
+	}
 	return true, nil
 }
 
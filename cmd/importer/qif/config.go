@@ -0,0 +1,90 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qif
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/sboehler/knut/lib/model"
+)
+
+// Mapping maps QIF categories, accounts and securities to knut accounts and
+// commodities.
+type Mapping struct {
+	// DateFormat is the layout of dates, in the format of the time package.
+	// If empty, common US formats are tried.
+	DateFormat string `yaml:"date_format"`
+	// Categories maps categories (such as "Auto:Fuel") to accounts. Classes
+	// ("Auto:Fuel/Vacation") are ignored.
+	Categories map[string]string `yaml:"categories"`
+	// Accounts maps QIF account names, which are the targets of transfers
+	// ("[Savings]") and the names in !Account blocks, to accounts.
+	Accounts map[string]string `yaml:"accounts"`
+	// Securities maps security names to commodities. Securities which are
+	// not mapped use their symbol from the security list.
+	Securities map[string]string `yaml:"securities"`
+}
+
+// ReadMapping reads a mapping from a YAML file.
+func ReadMapping(path string) (*Mapping, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.SetStrict(true)
+	var m Mapping
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// account maps a category or a transfer to an account. Unknown categories
+// are mapped to the TBD account, so that they can be inferred later.
+func (m *Mapping) account(reg *model.Registry, category string) (*model.Account, error) {
+	var name string
+	if strings.HasPrefix(category, "[") {
+		target, _, _ := strings.Cut(category[1:], "]")
+		name = m.Accounts[target]
+	} else {
+		category, _, _ = strings.Cut(category, "/")
+		name = m.Categories[category]
+	}
+	if name == "" {
+		return reg.Accounts().TBDAccount(), nil
+	}
+	return reg.Accounts().Get(name)
+}
+
+// commodity maps a security to a commodity.
+func (m *Mapping) commodity(reg *model.Registry, security string, symbols map[string]string) (*model.Commodity, error) {
+	if name, ok := m.Securities[security]; ok {
+		return reg.Commodities().Get(name)
+	}
+	if name, ok := symbols[security]; ok {
+		return reg.Commodities().Get(name)
+	}
+	c, err := reg.Commodities().Get(security)
+	if err != nil {
+		return nil, fmt.Errorf("security %q is not mapped to a commodity", security)
+	}
+	return c, nil
+}
@@ -0,0 +1,586 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qif
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+
+	"github.com/sboehler/knut/cmd/flags"
+	"github.com/sboehler/knut/cmd/importer"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+// CreateCmd creates the cobra command.
+func CreateCmd() *cobra.Command {
+
+	var r runner
+
+	cmd := &cobra.Command{
+		Use:   "qif",
+		Short: "Import QIF files from Quicken, GnuCash, Moneydance and others",
+		Long: `Import transactions from QIF files. Bank, credit card and investment accounts are supported,
+including split transactions. Categories, transfer accounts and securities are mapped through an
+optional YAML mapping file:
+
+  date_format: "01/02/2006"     # Go time layout, default: common US formats
+  categories:
+    Groceries: Expenses:Groceries
+    Auto:Fuel: Expenses:Car:Fuel
+  accounts:                     # transfers ([Savings]) and !Account names
+    Savings: Assets:Savings
+  securities:                   # security names to commodities
+    Apple Inc: AAPL
+
+Unknown categories are booked against Expenses:TBD, so that they can be inferred with
+'knut infer' afterwards. Transfers between two mapped accounts contained in the same file
+appear once for each account and are imported only once.`,
+
+		Args: cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+
+		Run: r.run,
+	}
+	r.setupFlags(cmd)
+	return cmd
+}
+
+func init() {
+	importer.RegisterImporter(CreateCmd)
+}
+
+type runner struct {
+	mapping                                                       string
+	currency                                                      flags.CommodityFlag
+	accountFlag, dividendFlag, feeFlag, interestFlag, tradingFlag flags.AccountFlag
+}
+
+func (r *runner) setupFlags(c *cobra.Command) {
+	c.Flags().VarP(&r.accountFlag, "account", "a", "account name")
+	c.Flags().VarP(&r.currency, "currency", "c", "currency of the amounts")
+	c.Flags().StringVarP(&r.mapping, "mapping", "m", "", "YAML file mapping categories, accounts and securities")
+	c.Flags().VarP(&r.interestFlag, "interest", "i", "account name of the interest account (default Expenses:TBD)")
+	c.Flags().VarP(&r.dividendFlag, "dividend", "d", "account name of the dividend account (default Expenses:TBD)")
+	c.Flags().VarP(&r.feeFlag, "fee", "f", "account name of the fee account (default Expenses:TBD)")
+	c.Flags().VarP(&r.tradingFlag, "trading", "t", "account name of the trading gain / loss account (default Expenses:TBD)")
+	c.MarkFlagRequired("account")
+	c.MarkFlagRequired("currency")
+}
+
+func (r *runner) run(cmd *cobra.Command, args []string) {
+	if err := r.runE(cmd, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func (r *runner) runE(cmd *cobra.Command, args []string) error {
+	var (
+		reg = registry.New()
+		tbd = reg.Accounts().TBDAccount()
+		err error
+	)
+	p := parser{
+		registry:  reg,
+		builder:   journal.New(),
+		mapping:   new(Mapping),
+		symbols:   make(map[string]string),
+		transfers: make(map[transfer][]*model.Account),
+	}
+	if r.mapping != "" {
+		if p.mapping, err = ReadMapping(r.mapping); err != nil {
+			return err
+		}
+	}
+	if p.currency, err = r.currency.Value(reg); err != nil {
+		return err
+	}
+	if p.defaultAccount, err = r.accountFlag.Value(reg.Accounts()); err != nil {
+		return err
+	}
+	if p.interest, err = r.interestFlag.ValueWithDefault(reg.Accounts(), tbd); err != nil {
+		return err
+	}
+	if p.dividend, err = r.dividendFlag.ValueWithDefault(reg.Accounts(), tbd); err != nil {
+		return err
+	}
+	if p.fee, err = r.feeFlag.ValueWithDefault(reg.Accounts(), tbd); err != nil {
+		return err
+	}
+	if p.trading, err = r.tradingFlag.ValueWithDefault(reg.Accounts(), tbd); err != nil {
+		return err
+	}
+	f, err := flags.OpenFile(args[0])
+	if err != nil {
+		return err
+	}
+	if err = p.parse(f); err != nil {
+		return err
+	}
+	out := bufio.NewWriter(cmd.OutOrStdout())
+	defer out.Flush()
+	return importer.Print(cmd, out, p.builder.Build())
+}
+
+type parser struct {
+	registry *model.Registry
+	builder  *journal.Builder
+	mapping  *Mapping
+	currency *model.Commodity
+
+	defaultAccount, account          *model.Account
+	dividend, fee, interest, trading *model.Account
+
+	// symbols maps security names to symbols from the security list.
+	symbols map[string]string
+
+	// transfers holds, for every transfer, the accounts from which a leg
+	// has been booked which has not been matched by its mirrored leg yet.
+	transfers map[transfer][]*model.Account
+}
+
+// transfer identifies a transfer independently of the account from which
+// it is recorded.
+type transfer struct {
+	date          time.Time
+	credit, debit *model.Account
+	quantity      string
+}
+
+// mirrored returns whether the transfer posting b, booked in the current
+// account against the given category, is the mirrored leg of a transfer
+// which has already been booked from the other account. Both legs describe
+// the same flow, so only the first one must be imported.
+func (p *parser) mirrored(date time.Time, category string, b posting.Builder) bool {
+	if !strings.HasPrefix(category, "[") || b.Credit == b.Debit || b.Credit == p.registry.Accounts().TBDAccount() {
+		return false
+	}
+	key := transfer{date: date, credit: b.Credit, debit: b.Debit, quantity: b.Quantity.String()}
+	if key.credit.Name() > key.debit.Name() {
+		key.credit, key.debit, key.quantity = b.Debit, b.Credit, b.Quantity.Neg().String()
+	}
+	legs := p.transfers[key]
+	for i, a := range legs {
+		if a != p.account {
+			p.transfers[key] = slices.Delete(legs, i, i+1)
+			return true
+		}
+	}
+	p.transfers[key] = append(legs, p.account)
+	return false
+}
+
+// record is a QIF record. Fields which can occur multiple times (splits)
+// are kept in order.
+type record struct {
+	fields []field
+}
+
+type field struct {
+	code  byte
+	value string
+}
+
+func (r record) get(code byte) string {
+	for _, f := range r.fields {
+		if f.code == code {
+			return f.value
+		}
+	}
+	return ""
+}
+
+type split struct {
+	category, memo, amount string
+}
+
+func (r record) splits() []split {
+	var res []split
+	for _, f := range r.fields {
+		switch f.code {
+		case 'S':
+			res = append(res, split{category: f.value})
+		case 'E':
+			if len(res) > 0 {
+				res[len(res)-1].memo = f.value
+			}
+		case '$':
+			if len(res) > 0 {
+				res[len(res)-1].amount = f.value
+			}
+		}
+	}
+	return res
+}
+
+func (p *parser) parse(r io.Reader) error {
+	var (
+		scanner = bufio.NewScanner(r)
+		section string
+		rec     record
+		line    int
+	)
+	p.account = p.defaultAccount
+	for scanner.Scan() {
+		line++
+		s := strings.TrimRight(scanner.Text(), "\r\t ")
+		if line == 1 {
+			s = strings.TrimPrefix(s, "\uFEFF")
+		}
+		switch {
+		case s == "":
+			continue
+		case strings.HasPrefix(s, "!"):
+			if strings.HasPrefix(s, "!Option") || strings.HasPrefix(s, "!Clear") {
+				continue
+			}
+			section = strings.ToLower(strings.TrimSpace(s))
+		case s == "^":
+			if err := p.parseRecord(section, rec); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			rec = record{}
+		default:
+			rec.fields = append(rec.fields, field{code: s[0], value: strings.TrimSpace(s[1:])})
+		}
+	}
+	return scanner.Err()
+}
+
+func (p *parser) parseRecord(section string, rec record) error {
+	switch section {
+	case "!account":
+		return p.parseAccount(rec)
+	case "!type:bank", "!type:ccard", "!type:cash", "!type:oth a", "!type:oth l":
+		return p.parseTransaction(rec)
+	case "!type:invst":
+		return p.parseInvestment(rec)
+	case "!type:security":
+		if name, symbol := rec.get('N'), rec.get('S'); name != "" && symbol != "" {
+			p.symbols[name] = symbol
+		}
+	}
+	// other sections, such as categories, classes and memorized
+	// transactions, are ignored.
+	return nil
+}
+
+// parseAccount switches the current account. Accounts which are not mapped
+// are imported into the account given on the command line.
+func (p *parser) parseAccount(rec record) error {
+	p.account = p.defaultAccount
+	if name, ok := p.mapping.Accounts[rec.get('N')]; ok {
+		a, err := p.registry.Accounts().Get(name)
+		if err != nil {
+			return err
+		}
+		p.account = a
+	}
+	return nil
+}
+
+func (p *parser) description(rec record) string {
+	var ss []string
+	for _, s := range []string{rec.get('P'), rec.get('M')} {
+		if s != "" {
+			ss = append(ss, s)
+		}
+	}
+	return strings.Join(ss, " ")
+}
+
+func (p *parser) parseTransaction(rec record) error {
+	date, err := p.parseDate(rec.get('D'))
+	if err != nil {
+		return err
+	}
+	total, err := p.amount(rec)
+	if err != nil {
+		return err
+	}
+	var (
+		postings  posting.Builders
+		remainder = total
+		splits    = rec.splits()
+	)
+	for _, s := range splits {
+		account, err := p.mapping.account(p.registry, s.category)
+		if err != nil {
+			return err
+		}
+		amount, err := parseDecimal(s.amount)
+		if err != nil {
+			return fmt.Errorf("invalid split amount: %w", err)
+		}
+		remainder = remainder.Sub(amount)
+		b := posting.Builder{
+			Credit:    account,
+			Debit:     p.account,
+			Commodity: p.currency,
+			Quantity:  amount,
+		}
+		if !p.mirrored(date, s.category, b) {
+			postings = append(postings, b)
+		}
+	}
+	if len(splits) == 0 {
+		category := rec.get('L')
+		account, err := p.mapping.account(p.registry, category)
+		if err != nil {
+			return err
+		}
+		b := posting.Builder{
+			Credit:    account,
+			Debit:     p.account,
+			Commodity: p.currency,
+			Quantity:  total,
+		}
+		if !p.mirrored(date, category, b) {
+			postings = append(postings, b)
+		}
+	} else if !remainder.IsZero() {
+		// splits which do not add up to the total
+		postings = append(postings, posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: p.currency,
+			Quantity:  remainder,
+		})
+	}
+	if len(postings) == 0 {
+		return nil
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: p.description(rec),
+		Postings:    postings.Build(),
+	}.Build())
+	return nil
+}
+
+func (p *parser) parseInvestment(rec record) error {
+	date, err := p.parseDate(rec.get('D'))
+	if err != nil {
+		return err
+	}
+	action := rec.get('N')
+	var (
+		security                    *model.Commodity
+		quantity, price, fee, total decimal.Decimal
+		transfer                    decimal.Decimal
+		postings                    posting.Builders
+		desc                        string
+	)
+	if name := rec.get('Y'); name != "" {
+		if security, err = p.mapping.commodity(p.registry, name, p.symbols); err != nil {
+			return err
+		}
+	}
+	for _, f := range []struct {
+		code  byte
+		value *decimal.Decimal
+	}{
+		{'Q', &quantity},
+		{'I', &price},
+		{'O', &fee},
+		{'$', &transfer},
+	} {
+		if *f.value, err = parseOptionalDecimal(rec.get(f.code)); err != nil {
+			return fmt.Errorf("invalid field %c: %w", f.code, err)
+		}
+	}
+	if total, err = p.amount(rec); err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(action, "X")
+	if security == nil && !cashActions[base] {
+		return fmt.Errorf("%s: missing security", action)
+	}
+	switch base {
+	case "Buy", "Sell":
+		sign := decimal.NewFromInt(1)
+		if base == "Sell" {
+			sign = sign.Neg()
+		}
+		// the total includes the commission for buys and is net of
+		// the commission for sells
+		gross := total.Sub(fee.Mul(sign)).Mul(sign)
+		desc = fmt.Sprintf("%s %s %s @ %s %s", base, quantity, security.Name(), price, p.currency.Name())
+		postings = append(postings,
+			posting.Builder{Credit: p.trading, Debit: p.account, Commodity: security, Quantity: quantity.Mul(sign)},
+			posting.Builder{Credit: p.trading, Debit: p.account, Commodity: p.currency, Quantity: gross.Neg()},
+		)
+		if !fee.IsZero() {
+			postings = append(postings, posting.Builder{Credit: p.fee, Debit: p.account, Commodity: p.currency, Quantity: fee.Neg()})
+		}
+	case "Div", "CGLong", "CGShort", "CGMid", "IntInc", "MiscInc":
+		account := p.dividend
+		if base == "IntInc" {
+			account = p.interest
+		}
+		desc = strings.TrimSpace(fmt.Sprintf("%s %s", incomeDescription[base], name(security)))
+		postings = append(postings, posting.Builder{Credit: account, Debit: p.account, Commodity: p.currency, Quantity: total})
+	case "ReinvDiv", "ReinvInt", "ReinvLg", "ReinvSh", "ReinvMd":
+		account := p.dividend
+		if base == "ReinvInt" {
+			account = p.interest
+		}
+		desc = fmt.Sprintf("Reinvest %s %s @ %s %s", quantity, security.Name(), price, p.currency.Name())
+		postings = append(postings,
+			posting.Builder{Credit: account, Debit: p.account, Commodity: p.currency, Quantity: total},
+			posting.Builder{Credit: p.trading, Debit: p.account, Commodity: security, Quantity: quantity},
+			posting.Builder{Credit: p.trading, Debit: p.account, Commodity: p.currency, Quantity: total.Neg()},
+		)
+	case "ShrsIn", "ShrsOut":
+		sign := decimal.NewFromInt(1)
+		if base == "ShrsOut" {
+			sign = sign.Neg()
+		}
+		desc = fmt.Sprintf("%s %s %s", base, quantity, security.Name())
+		postings = append(postings, posting.Builder{
+			Credit:    p.registry.Accounts().TBDAccount(),
+			Debit:     p.account,
+			Commodity: security,
+			Quantity:  quantity.Mul(sign),
+		})
+	case "XIn", "XOut", "MiscExp", "Cash":
+		if base == "XOut" || base == "MiscExp" {
+			total = total.Neg()
+		}
+		account, err := p.mapping.account(p.registry, rec.get('L'))
+		if err != nil {
+			return err
+		}
+		if desc = rec.get('P'); desc == "" {
+			desc = base
+		}
+		b := posting.Builder{Credit: account, Debit: p.account, Commodity: p.currency, Quantity: total}
+		if !p.mirrored(date, rec.get('L'), b) {
+			postings = append(postings, b)
+		}
+	default:
+		return fmt.Errorf("unsupported investment action %q", action)
+	}
+	// For actions ending in X, the cash is transferred from or to the
+	// account given in the L field.
+	if strings.HasSuffix(action, "X") && base != "XIn" && base != "XOut" && !transfer.IsZero() {
+		account, err := p.mapping.account(p.registry, rec.get('L'))
+		if err != nil {
+			return err
+		}
+		if base == "Buy" || base == "MiscExp" {
+			transfer = transfer.Abs()
+		} else {
+			transfer = transfer.Abs().Neg()
+		}
+		b := posting.Builder{Credit: account, Debit: p.account, Commodity: p.currency, Quantity: transfer}
+		if !p.mirrored(date, rec.get('L'), b) {
+			postings = append(postings, b)
+		}
+	}
+	if len(postings) == 0 {
+		return nil
+	}
+	var targets []*model.Commodity
+	if security != nil {
+		targets = []*model.Commodity{security, p.currency}
+	}
+	p.builder.Add(transaction.Builder{
+		Date:        date,
+		Description: strings.Join(strings.Fields(desc+" "+rec.get('M')), " "),
+		Postings:    postings.Build(),
+		Targets:     targets,
+	}.Build())
+	return nil
+}
+
+// cashActions are the actions which do not require a security.
+var cashActions = map[string]bool{
+	"IntInc":  true,
+	"MiscInc": true,
+	"MiscExp": true,
+	"XIn":     true,
+	"XOut":    true,
+	"Cash":    true,
+}
+
+var incomeDescription = map[string]string{
+	"Div":     "Dividend",
+	"CGLong":  "Capital gain distribution",
+	"CGShort": "Capital gain distribution",
+	"CGMid":   "Capital gain distribution",
+	"IntInc":  "Interest",
+	"MiscInc": "Income",
+}
+
+func name(c *model.Commodity) string {
+	if c == nil {
+		return ""
+	}
+	return c.Name()
+}
+
+// amount returns the amount of the record, which is given in the T field
+// or, by some applications, in the U field.
+func (p *parser) amount(rec record) (decimal.Decimal, error) {
+	s := rec.get('T')
+	if s == "" {
+		s = rec.get('U')
+	}
+	q, err := parseOptionalDecimal(s)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid amount: %w", err)
+	}
+	return q, nil
+}
+
+var dateFormats = []string{"1/2/2006", "1/2/06", "1/ 2/06", "2006-01-02", "2.1.2006"}
+
+// parseDate parses a date. Quicken uses an apostrophe to separate the year
+// for dates after 2000 (1/15'23).
+func (p *parser) parseDate(s string) (time.Time, error) {
+	s = strings.ReplaceAll(s, "'", "/")
+	if p.mapping.DateFormat != "" {
+		return time.Parse(p.mapping.DateFormat, s)
+	}
+	for _, layout := range dateFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func parseDecimal(s string) (decimal.Decimal, error) {
+	return decimal.NewFromString(strings.ReplaceAll(strings.TrimSpace(s), ",", ""))
+}
+
+func parseOptionalDecimal(s string) (decimal.Decimal, error) {
+	if s == "" {
+		return decimal.Zero, nil
+	}
+	return parseDecimal(s)
+}
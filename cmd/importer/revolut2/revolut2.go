@@ -91,7 +91,7 @@ func (r *runner) run(cmd *cobra.Command, args []string) error {
 	}
 	out := bufio.NewWriter(cmd.OutOrStdout())
 	defer out.Flush()
-	return journal.Print(out, builder.Build())
+	return importer.Print(cmd, out, builder.Build())
 }
 
 type parser struct {
@@ -87,7 +87,7 @@ func (r *runner) run(cmd *cobra.Command, args []string) error {
 	}
 	w := bufio.NewWriter(cmd.OutOrStdout())
 	defer w.Flush()
-	return journal.Print(w, p.builder.Build())
+	return importer.Print(cmd, w, p.builder.Build())
 }
 
 type parser struct {
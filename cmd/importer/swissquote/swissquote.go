@@ -110,13 +110,14 @@ func (r *runner) run(cmd *cobra.Command, args []string) error {
 	}
 	out := bufio.NewWriter(cmd.OutOrStdout())
 	defer out.Flush()
-	return journal.Print(out, p.builder.Build())
+	return importer.Print(cmd, out, p.builder.Build())
 }
 
 type parser struct {
 	registry *model.Registry
 	reader   *csv.Reader
 	builder  *journal.Builder
+	balances *importer.Balances
 	last     *record
 
 	account, dividend, tax, fee, interest, trading *model.Account
@@ -126,6 +127,7 @@ func (p *parser) parse() error {
 	p.reader.LazyQuotes = true
 	p.reader.Comma = ';'
 	p.reader.FieldsPerRecord = 13
+	p.balances = importer.NewBalances(p.account)
 	// skip header
 	if _, err := p.reader.Read(); err != nil {
 		return err
@@ -133,6 +135,9 @@ func (p *parser) parse() error {
 	for {
 		err := p.readLine()
 		if err == io.EOF {
+			for _, a := range p.balances.Assertions() {
+				p.builder.Add(a)
+			}
 			return nil
 		}
 		if err != nil {
@@ -150,6 +155,7 @@ func (p *parser) readLine() error {
 	if err != nil {
 		return err
 	}
+	p.balances.Add(r.date, r.currency, r.netQuantity, r.balance)
 	if ok, err := p.parseTrade(r); err != nil || ok {
 		return err
 	}
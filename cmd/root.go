@@ -36,6 +36,7 @@ func CreateCmd(version string) *cobra.Command {
 	c.AddCommand(commands.CreateImportCommand())
 	c.AddCommand(commands.CreateInferCmd())
 	c.AddCommand(commands.CreatePortfolioCommand())
+	c.AddCommand(commands.CreatePricesCommand())
 	c.AddCommand(commands.CreateFetchCommand())
 	c.AddCommand(commands.CreateRegisterCmd())
 	c.AddCommand(commands.CreateTranscodeCommand())
@@ -75,6 +75,37 @@ func (ch *Checker) balance(a *model.Assertion, bal *model.Balance) error {
 	return nil
 }
 
+func (ch *Checker) split(s *model.Split) error {
+	var found bool
+	for pos, qty := range ch.quantities {
+		if pos.Commodity != s.Commodity || qty.IsZero() {
+			continue
+		}
+		ch.quantities[pos] = s.Apply(qty)
+		found = true
+	}
+	if !found {
+		return Error{Directive: s, Msg: fmt.Sprintf("no account holds %s", s.Commodity.Name())}
+	}
+	return nil
+}
+
+func (ch *Checker) rename(r *model.Rename) error {
+	var found bool
+	for pos, qty := range ch.quantities {
+		if pos.Commodity != r.Commodity || qty.IsZero() {
+			continue
+		}
+		ch.quantities.Add(amounts.AccountCommodityKey(pos.Account, r.Target), qty)
+		delete(ch.quantities, pos)
+		found = true
+	}
+	if !found {
+		return Error{Directive: r, Msg: fmt.Sprintf("no account holds %s", r.Commodity.Name())}
+	}
+	return nil
+}
+
 func (ch *Checker) close(c *model.Close) error {
 	for pos, amount := range ch.quantities {
 		if pos.Account != c.Account {
@@ -123,6 +154,8 @@ func (ch *Checker) Check() *journal.Processor {
 	}
 
 	return &journal.Processor{
+		Split:   ch.split,
+		Rename:  ch.rename,
 		Open:    ch.open,
 		Posting: ch.posting,
 		Balance: ch.balance,
@@ -0,0 +1,87 @@
+package check
+
+import (
+	"time"
+
+	"github.com/sboehler/knut/lib/amounts"
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
+)
+
+// StalePrice is a commodity held at a reporting date whose most
+// recent price is too old.
+type StalePrice struct {
+	Date      time.Time
+	Commodity *model.Commodity
+
+	// LastPrice is the date of the most recent price, or the zero
+	// time if the commodity has never been priced.
+	LastPrice time.Time
+	Age       int
+}
+
+// PriceChecker finds commodities held in asset and liability accounts
+// whose price is older than MaxAge days at the given dates. The price of a
+// commodity is as old as the oldest price on its path to the valuation
+// commodity, e.g. a stock quoted daily in USD has a stale price if the USD
+// price is stale. It requires the prices to be computed by
+// journal.ComputePrices.
+type PriceChecker struct {
+	Valuation *model.Commodity
+	MaxAge    int
+	Dates     []time.Time
+
+	quantities amounts.Amounts
+	dates      set.Set[time.Time]
+	stale      []StalePrice
+}
+
+// StalePrices returns the stale prices, ordered by date and commodity.
+func (pc *PriceChecker) StalePrices() []StalePrice {
+	return pc.stale
+}
+
+func (pc *PriceChecker) posting(_ *model.Transaction, p *model.Posting) error {
+	if p.Account.IsAL() {
+		pc.quantities.Add(amounts.AccountCommodityKey(p.Account, p.Commodity), p.Quantity)
+	}
+	return nil
+}
+
+func (pc *PriceChecker) dayEnd(d *journal.Day) error {
+	if !pc.dates.Has(d.Date) {
+		return nil
+	}
+	held := set.New[*model.Commodity]()
+	for pos, qty := range pc.quantities {
+		if !qty.IsZero() && pos.Commodity != pc.Valuation {
+			held.Add(pos.Commodity)
+		}
+	}
+	for _, c := range held.Sorted(commodity.Compare) {
+		age, ok := d.PriceAge(c)
+		if ok && age <= pc.MaxAge {
+			continue
+		}
+		pc.stale = append(pc.stale, StalePrice{
+			Date:      d.Date,
+			Commodity: c,
+			LastPrice: d.PriceDates[c],
+			Age:       age,
+		})
+	}
+	return nil
+}
+
+// Check returns a processor which collects stale prices.
+func (pc *PriceChecker) Check() *journal.Processor {
+	pc.quantities = make(amounts.Amounts)
+	pc.dates = set.FromSlice(pc.Dates)
+	pc.stale = nil
+	return &journal.Processor{
+		Posting: pc.posting,
+		DayEnd:  pc.dayEnd,
+	}
+}
@@ -0,0 +1,54 @@
+package check
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/journaltest"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+func TestPriceChecker(t *testing.T) {
+	const text = `
+2020-01-01 open Equity:Equity
+2020-01-01 open Assets:Portfolio
+
+2020-01-01 price USD 0.9 CHF
+
+2023-05-01 "Buy"
+Equity:Equity Assets:Portfolio 10 AAPL
+
+2023-05-01 "Deposit"
+Equity:Equity Assets:Portfolio 5 GBP
+
+2023-05-01 price AAPL 150 USD
+2023-05-01 price EUR 1 CHF
+2023-06-01 price AAPL 160 USD
+`
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	j := journaltest.Build(t, reg, text)
+	dates := []time.Time{date.Date(2023, 5, 1), date.Date(2023, 6, 1)}
+	j.Days(dates)
+	pc := PriceChecker{Valuation: chf, MaxAge: 30, Dates: dates}
+
+	err := j.Build().Process(journal.ComputePrices(chf), pc.Check())
+
+	if err != nil {
+		t.Fatalf("Process() returned unexpected error: %v", err)
+	}
+	// AAPL is priced daily in USD, but the USD price is stale.
+	want := []StalePrice{
+		{Date: dates[0], Commodity: reg.Commodities().MustGet("AAPL"), LastPrice: date.Date(2020, 1, 1), Age: 1216},
+		{Date: dates[0], Commodity: reg.Commodities().MustGet("GBP")},
+		{Date: dates[1], Commodity: reg.Commodities().MustGet("AAPL"), LastPrice: date.Date(2020, 1, 1), Age: 1247},
+		{Date: dates[1], Commodity: reg.Commodities().MustGet("GBP")},
+	}
+	if diff := cmp.Diff(want, pc.StalePrices(), cmp.Comparer(func(a, b *model.Commodity) bool { return a == b })); diff != "" {
+		t.Errorf("StalePrices() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+}
@@ -0,0 +1,155 @@
+package dedup
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+)
+
+// Duplicate is an imported transaction which matches an existing one.
+type Duplicate struct {
+	Imported, Existing *model.Transaction
+}
+
+// Matcher finds imported transactions which already exist in a journal.
+// Accounts and commodities are compared by name, so the imported and the
+// existing journal can use different registries.
+type Matcher struct {
+	// Tolerance is the maximum number of days between the dates of
+	// matching transactions.
+	Tolerance int
+
+	// Similarity is the minimum similarity of the descriptions, between
+	// 0 and 1. Descriptions are ignored if it is 0.
+	Similarity float64
+
+	existing map[time.Time][]*model.Transaction
+	matched  set.Set[*model.Transaction]
+}
+
+// NewMatcher creates a matcher for the transactions of the given journal.
+func NewMatcher(j *journal.Journal, tolerance int, similarity float64) *Matcher {
+	m := &Matcher{
+		Tolerance:  tolerance,
+		Similarity: similarity,
+		existing:   make(map[time.Time][]*model.Transaction),
+		matched:    set.New[*model.Transaction](),
+	}
+	for _, d := range j.Days {
+		m.existing[d.Date] = append(m.existing[d.Date], d.Transactions...)
+	}
+	return m
+}
+
+// Match returns an existing transaction which matches t. Transactions on
+// closer dates are preferred. Every existing transaction matches at most
+// one imported transaction, so that repeated identical transactions are
+// not lost.
+func (m *Matcher) Match(t *model.Transaction) (*model.Transaction, bool) {
+	if len(keyPostings(t)) == 0 {
+		return nil, false
+	}
+	for offset := 0; offset <= m.Tolerance; offset++ {
+		for _, sign := range []int{1, -1} {
+			if offset == 0 && sign < 0 {
+				continue
+			}
+			for _, e := range m.existing[t.Date.AddDate(0, 0, sign*offset)] {
+				if m.matched.Has(e) || !m.matches(t, e) {
+					continue
+				}
+				m.matched.Add(e)
+				return e, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Filter removes the transactions from j which match an existing
+// transaction, and returns them.
+func (m *Matcher) Filter(j *journal.Journal) []Duplicate {
+	var res []Duplicate
+	for _, d := range j.Days {
+		var kept []*model.Transaction
+		for _, t := range d.Transactions {
+			if e, ok := m.Match(t); ok {
+				res = append(res, Duplicate{Imported: t, Existing: e})
+			} else {
+				kept = append(kept, t)
+			}
+		}
+		d.Transactions = kept
+	}
+	return res
+}
+
+// matches returns whether all asset and liability postings of the imported
+// transaction are contained in the existing one. The other side of the
+// postings is ignored, as it is usually not known at import time.
+func (m *Matcher) matches(t, e *model.Transaction) bool {
+	candidates := keyPostings(e)
+	for _, p := range keyPostings(t) {
+		found := false
+		for i, c := range candidates {
+			if c != nil &&
+				c.Account.Name() == p.Account.Name() &&
+				c.Commodity.Name() == p.Commodity.Name() &&
+				c.Quantity.Equal(p.Quantity) {
+				candidates[i] = nil
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return m.Similarity <= 0 || Similarity(t.Description, e.Description) >= m.Similarity
+}
+
+func keyPostings(t *model.Transaction) []*model.Posting {
+	var res []*model.Posting
+	for _, p := range t.Postings {
+		if p.Account.IsAL() {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// Similarity computes the similarity of two strings as the Sørensen-Dice
+// coefficient of their character bigrams, ignoring case and whitespace.
+func Similarity(s1, s2 string) float64 {
+	b1, b2 := bigrams(s1), bigrams(s2)
+	if len(b1) == 0 || len(b2) == 0 {
+		if strings.EqualFold(strings.TrimSpace(s1), strings.TrimSpace(s2)) {
+			return 1
+		}
+		return 0
+	}
+	var common int
+	for bg, n := range b1 {
+		common += min(n, b2[bg])
+	}
+	var total int
+	for _, n := range b1 {
+		total += n
+	}
+	for _, n := range b2 {
+		total += n
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+func bigrams(s string) map[string]int {
+	rs := []rune(strings.ToLower(strings.Join(strings.Fields(s), " ")))
+	res := make(map[string]int)
+	for i := 0; i+1 < len(rs); i++ {
+		res[string(rs[i:i+2])]++
+	}
+	return res
+}
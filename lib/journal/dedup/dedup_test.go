@@ -0,0 +1,151 @@
+package dedup
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+)
+
+func TestFilter(t *testing.T) {
+	var (
+		reg       = registry.New()
+		bank      = reg.Accounts().MustGet("Assets:Bank")
+		groceries = reg.Accounts().MustGet("Expenses:Groceries")
+		tbd       = reg.Accounts().TBDAccount()
+		chf       = reg.Commodities().MustGet("CHF")
+		usd       = reg.Commodities().MustGet("USD")
+	)
+	trx := func(day int, desc string, other *model.Account, com *model.Commodity, qty int64) *model.Transaction {
+		return transaction.Builder{
+			Date:        date.Date(2023, 1, day),
+			Description: desc,
+			Postings: posting.Builder{
+				Credit:    other,
+				Debit:     bank,
+				Commodity: com,
+				Quantity:  decimal.NewFromInt(qty),
+			}.Build(),
+		}.Build()
+	}
+	build := func(ts ...*model.Transaction) *journal.Journal {
+		b := journal.New()
+		for _, t := range ts {
+			b.Add(t)
+		}
+		return b.Build()
+	}
+	existing := build(
+		trx(5, "Migros Zurich", groceries, chf, -50),
+		trx(5, "Migros Zurich", groceries, chf, -50),
+		trx(10, "Coop", groceries, chf, -20),
+		trx(12, "Salary", groceries, chf, 1000),
+	)
+
+	tests := []struct {
+		desc       string
+		similarity float64
+		imported   []*model.Transaction
+		want       []string
+	}{
+		{
+			desc: "same date, account, amount and commodity",
+			imported: []*model.Transaction{
+				trx(10, "COOP-1234 ZURICH", tbd, chf, -20),
+			},
+			want: []string{"COOP-1234 ZURICH"},
+		},
+		{
+			desc: "within tolerance",
+			imported: []*model.Transaction{
+				trx(8, "early", tbd, chf, -20),
+				trx(14, "late", tbd, chf, 1000),
+			},
+			want: []string{"early", "late"},
+		},
+		{
+			desc: "outside tolerance",
+			imported: []*model.Transaction{
+				trx(6, "too early", tbd, chf, -20),
+			},
+		},
+		{
+			desc: "different amount or commodity",
+			imported: []*model.Transaction{
+				trx(10, "amount", tbd, chf, -21),
+				trx(10, "commodity", tbd, usd, -20),
+			},
+		},
+		{
+			desc: "every existing transaction matches once",
+			imported: []*model.Transaction{
+				trx(5, "first", tbd, chf, -50),
+				trx(5, "second", tbd, chf, -50),
+				trx(5, "third", tbd, chf, -50),
+			},
+			want: []string{"first", "second"},
+		},
+		{
+			desc:       "similar descriptions",
+			similarity: 0.5,
+			imported: []*model.Transaction{
+				trx(5, "MIGROS ZURICH", tbd, chf, -50),
+				trx(10, "Denner", tbd, chf, -20),
+			},
+			want: []string{"MIGROS ZURICH"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			m := NewMatcher(existing, 2, test.similarity)
+			j := build(test.imported...)
+
+			var got []string
+			for _, d := range m.Filter(j) {
+				got = append(got, d.Imported.Description)
+			}
+
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Fatalf("Filter() returned unexpected diff (-want/+got):\n%s\n", diff)
+			}
+			var remaining int
+			for _, d := range j.Days {
+				remaining += len(d.Transactions)
+			}
+			if remaining+len(got) != len(test.imported) {
+				t.Fatalf("Filter() kept %d of %d transactions, skipped %d", remaining, len(test.imported), len(got))
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		s1, s2 string
+		want   float64
+	}{
+		{"Migros", "MIGROS", 1},
+		{"abc", "xyz", 0},
+		{"night", "nacht", 0.25},
+		{"", "", 1},
+		{"a", "b", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.s1+"/"+test.s2, func(t *testing.T) {
+
+			got := Similarity(test.s1, test.s2)
+
+			if got != test.want {
+				t.Fatalf("Similarity(%q, %q) = %f, want %f", test.s1, test.s2, got, test.want)
+			}
+		})
+	}
+}
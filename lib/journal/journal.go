@@ -68,6 +68,14 @@ func (j *Builder) Add(d model.Directive) error {
 		}
 		d.Prices = append(d.Prices, t)
 
+	case *model.Split:
+		d := j.Day(t.Date)
+		d.Splits = append(d.Splits, t)
+
+	case *model.Rename:
+		d := j.Day(t.Date)
+		d.Renames = append(d.Renames, t)
+
 	case *model.Open:
 		d := j.Day(t.Date)
 		d.Openings = append(d.Openings, t)
@@ -159,16 +167,34 @@ func (j *Journal) Process(ps ...*Processor) error {
 type Day struct {
 	Date         time.Time
 	Prices       []*model.Price
+	Splits       []*model.Split
+	Renames      []*model.Rename
 	Assertions   []*model.Assertion
 	Openings     []*model.Open
 	Transactions []*model.Transaction
 	Closings     []*model.Close
 
 	Normalized price.NormalizedPrices
+	Quotes     price.Quotes
+
+	// PriceDates holds the date of the oldest price on the path used
+	// to price each commodity.
+	PriceDates map[*model.Commodity]time.Time
 
 	Performance *Performance
 }
 
+// PriceAge returns the age in days of the normalized price of the given
+// commodity, which is the age of the oldest price on its path to the
+// valuation commodity, and false if it has never been priced.
+func (d *Day) PriceAge(c *model.Commodity) (int, bool) {
+	t, ok := d.PriceDates[c]
+	if !ok {
+		return 0, false
+	}
+	return int(d.Date.Sub(t).Hours() / 24), true
+}
+
 // Less establishes an ordering on Day.
 func CompareDays(d *Day, d2 *Day) compare.Order {
 	return compare.Time(d.Date, d2.Date)
@@ -231,6 +257,21 @@ func Print(w io.Writer, j *Journal) error {
 				return err
 			}
 		}
+		for _, s := range day.Splits {
+			if _, err := p.PrintDirectiveLn(s); err != nil {
+				return err
+			}
+		}
+		for _, r := range day.Renames {
+			if _, err := p.PrintDirectiveLn(r); err != nil {
+				return err
+			}
+		}
+		if len(day.Splits) > 0 || len(day.Renames) > 0 {
+			if _, err := io.WriteString(p, "\n"); err != nil {
+				return err
+			}
+		}
 		for _, o := range day.Openings {
 			if _, err := p.PrintDirectiveLn(o); err != nil {
 				return err
@@ -273,6 +314,8 @@ func Print(w io.Writer, j *Journal) error {
 type Processor struct {
 	DayStart    func(*Day) error
 	Price       func(*model.Price) error
+	Split       func(*model.Split) error
+	Rename      func(*model.Rename) error
 	Open        func(*model.Open) error
 	Transaction func(*model.Transaction) error
 	Posting     func(*model.Transaction, *model.Posting) error
@@ -295,6 +338,20 @@ func (proc *Processor) Process(d *Day) error {
 			}
 		}
 	}
+	if proc.Split != nil {
+		for _, s := range d.Splits {
+			if err := proc.Split(s); err != nil {
+				return err
+			}
+		}
+	}
+	if proc.Rename != nil {
+		for _, r := range d.Renames {
+			if err := proc.Rename(r); err != nil {
+				return err
+			}
+		}
+	}
 	if proc.Open != nil {
 		for _, o := range d.Openings {
 			if err := proc.Open(o); err != nil {
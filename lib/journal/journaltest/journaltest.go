@@ -0,0 +1,51 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package journaltest provides helpers to test processors on journals.
+package journaltest
+
+import (
+	"testing"
+
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+// Build parses the given journal text and returns a builder with its
+// directives.
+func Build(t *testing.T, reg *model.Registry, text string) *journal.Builder {
+	t.Helper()
+	p := parser.New(text, "")
+	if err := p.Advance(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := p.ParseFile()
+	if err != nil {
+		t.Fatalf("p.ParseFile() returned unexpected error: %v", err)
+	}
+	j := journal.New()
+	for _, d := range f.Directives {
+		ds, err := model.ParseDirective(reg, d)
+		if err != nil {
+			t.Fatalf("model.ParseDirective() returned unexpected error: %v", err)
+		}
+		for _, d := range ds {
+			if err := j.Add(d); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return j
+}
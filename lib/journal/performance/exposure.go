@@ -0,0 +1,101 @@
+package performance
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"gopkg.in/yaml.v2"
+)
+
+type yamlExposureFile map[string]yamlExposure
+
+// yamlExposure is either a plain currency name, which exposes the
+// full value of the commodity to the currency, or a map from currency
+// names to fractions.
+type yamlExposure map[string]float64
+
+func (e *yamlExposure) UnmarshalYAML(unmarshal func(any) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		*e = yamlExposure{name: 1}
+		return nil
+	}
+	var m map[string]float64
+	if err := unmarshal(&m); err != nil {
+		return fmt.Errorf("expected <currency> or a map of <currency>: <weight>: %w", err)
+	}
+	*e = m
+	return nil
+}
+
+func LoadExposuresFromFile(reg *commodity.Registry, path string) (Exposures, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadExposures(reg, f)
+}
+
+func LoadExposures(reg *commodity.Registry, r io.Reader) (Exposures, error) {
+	dec := yaml.NewDecoder(r)
+	dec.SetStrict(true)
+	var t yamlExposureFile
+	if err := dec.Decode(&t); err != nil {
+		return nil, err
+	}
+	return exposuresFromYAML(reg, t)
+}
+
+// Exposure is the fraction of a commodity's value which is
+// exposed to a currency.
+type Exposure struct {
+	Currency *model.Commodity
+	Weight   float64
+}
+
+// Exposures maps commodities to their underlying currency exposures.
+type Exposures map[*model.Commodity][]Exposure
+
+func exposuresFromYAML(reg *commodity.Registry, yaml yamlExposureFile) (Exposures, error) {
+	exposures := make(Exposures)
+	for name, currencies := range yaml {
+		com, err := reg.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		var total float64
+		for currency, weight := range currencies {
+			cur, err := reg.Get(currency)
+			if err != nil {
+				return nil, err
+			}
+			if weight <= 0 || weight > 1 {
+				return nil, fmt.Errorf("commodity %s has invalid weight %v in currency %s", com.Name(), weight, cur.Name())
+			}
+			exposures[com] = append(exposures[com], Exposure{Currency: cur, Weight: weight})
+			total += weight
+		}
+		if math.Abs(total-1) > weightTolerance {
+			return nil, fmt.Errorf("currency exposures of commodity %s sum up to %v, expected 1", com.Name(), total)
+		}
+	}
+	return exposures, nil
+}
+
+// Locate returns the currency exposures of the given commodity. Commodities
+// which are not mapped are only exposed to themselves if they are tagged as
+// currencies. Locate returns false for other commodities.
+func (ex Exposures) Locate(c *model.Commodity) ([]Exposure, bool) {
+	if exposures, ok := ex[c]; ok {
+		return exposures, true
+	}
+	if c.IsCurrency {
+		return []Exposure{{Currency: c, Weight: 1}}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,51 @@
+package performance
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/model/commodity"
+)
+
+func TestLoadExposures(t *testing.T) {
+	reg := commodity.NewCommodities()
+	aapl := reg.MustGet("AAPL")
+	usd := reg.MustGet("USD")
+	chf := reg.MustGet("CHF")
+	input := "AAPL: USD\nVWRL:\n  USD: 0.6\n  EUR: 0.4\n"
+
+	got, err := LoadExposures(reg, strings.NewReader(input))
+
+	if err != nil {
+		t.Fatalf("LoadExposures() returned unexpected error: %v", err)
+	}
+	if e, ok := got.Locate(aapl); !ok || !cmp.Equal([]Exposure{{Currency: usd, Weight: 1}}, e, cmp.Comparer(samePointer)) {
+		t.Errorf("Locate(AAPL) = %v, %t, want exposure to USD", e, ok)
+	}
+	if e, ok := got.Locate(reg.MustGet("VWRL")); !ok || len(e) != 2 {
+		t.Errorf("Locate(VWRL) = %v, %t, want 2 exposures", e, ok)
+	}
+	if e, ok := got.Locate(reg.MustGet("MSFT")); ok {
+		t.Errorf("Locate(MSFT) = %v, %t, want no exposures", e, ok)
+	}
+	chf.IsCurrency = true
+	if e, ok := got.Locate(chf); !ok || !cmp.Equal([]Exposure{{Currency: chf, Weight: 1}}, e, cmp.Comparer(samePointer)) {
+		t.Errorf("Locate(CHF) = %v, %t, want exposure to CHF", e, ok)
+	}
+}
+
+func TestLoadExposuresIncomplete(t *testing.T) {
+	reg := commodity.NewCommodities()
+	input := "VWRL:\n  USD: 0.6\n  EUR: 0.3\n"
+
+	_, err := LoadExposures(reg, strings.NewReader(input))
+
+	if err == nil {
+		t.Fatalf("LoadExposures() returned no error, want an error")
+	}
+}
+
+func samePointer(c1, c2 *commodity.Commodity) bool {
+	return c1 == c2
+}
@@ -3,6 +3,7 @@ package performance
 import (
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"strings"
 
@@ -11,7 +12,34 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-type yamlUniverseFile map[string][]string
+type yamlUniverseFile map[string][]yamlAllocation
+
+// yamlAllocation is either a plain commodity name, which allocates the
+// full value of the commodity to the class, or a single-entry map from
+// commodity name to the fraction allocated to the class.
+type yamlAllocation struct {
+	Commodity string
+	Weight    float64
+}
+
+func (a *yamlAllocation) UnmarshalYAML(unmarshal func(any) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		a.Commodity, a.Weight = name, 1
+		return nil
+	}
+	var m map[string]float64
+	if err := unmarshal(&m); err != nil {
+		return fmt.Errorf("expected <commodity> or <commodity>: <weight>: %w", err)
+	}
+	if len(m) != 1 {
+		return fmt.Errorf("expected a single <commodity>: <weight> entry, got %v", m)
+	}
+	for name, weight := range m {
+		a.Commodity, a.Weight = name, weight
+	}
+	return nil
+}
 
 func LoadUniverseFromFile(reg *commodity.Registry, path string) (Universe, error) {
 	f, err := os.Open(path)
@@ -32,29 +60,54 @@ func LoadUniverse(reg *commodity.Registry, r io.Reader) (Universe, error) {
 	return fromYAML(reg, t)
 }
 
-type Universe map[*model.Commodity][]string
+// Allocation is the fraction of a commodity's value which is
+// attributed to a class.
+type Allocation struct {
+	Class  []string
+	Weight float64
+}
+
+type Universe map[*model.Commodity][]Allocation
+
+// weightTolerance is the tolerance when checking that the allocations
+// of a commodity sum up to 1.
+const weightTolerance = 1e-6
 
 func fromYAML(reg *commodity.Registry, yaml yamlUniverseFile) (Universe, error) {
 	universe := make(Universe)
-	for class, commodities := range yaml {
-		for _, name := range commodities {
-			com, err := reg.Get(name)
+	for class, allocations := range yaml {
+		for _, a := range allocations {
+			com, err := reg.Get(a.Commodity)
 			if err != nil {
 				return nil, err
 			}
-			if _, ok := universe[com]; ok {
-				return nil, fmt.Errorf("commodity %s already has a classification", com.Name())
+			if a.Weight <= 0 || a.Weight > 1 {
+				return nil, fmt.Errorf("commodity %s has invalid weight %v in class %s", com.Name(), a.Weight, class)
 			}
-			universe[com] = append(strings.Split(class, ":"), com.Name())
+			universe[com] = append(universe[com], Allocation{
+				Class:  append(strings.Split(class, ":"), com.Name()),
+				Weight: a.Weight,
+			})
+		}
+	}
+	for com, allocations := range universe {
+		var total float64
+		for _, a := range allocations {
+			total += a.Weight
+		}
+		if math.Abs(total-1) > weightTolerance {
+			return nil, fmt.Errorf("allocations of commodity %s sum up to %v, expected 1", com.Name(), total)
 		}
 	}
 	return universe, nil
 }
 
-func (un Universe) Locate(c *model.Commodity) []string {
-	class, ok := un[c]
+// Locate returns the allocations of the given commodity. Commodities which are
+// not part of the universe are fully allocated to the class Other.
+func (un Universe) Locate(c *model.Commodity) []Allocation {
+	allocations, ok := un[c]
 	if ok {
-		return class
+		return allocations
 	}
-	return []string{"Other", c.Name()}
+	return []Allocation{{Class: []string{"Other", c.Name()}, Weight: 1}}
 }
@@ -0,0 +1,72 @@
+package performance
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/model/commodity"
+)
+
+func TestLoadUniverse(t *testing.T) {
+	reg := commodity.NewCommodities()
+	aapl := reg.MustGet("AAPL")
+	vwrl := reg.MustGet("VWRL")
+
+	input := strings.Join([]string{
+		"Equities:US:",
+		"  - AAPL",
+		"Equities:World:",
+		"  - VWRL: 0.6",
+		"Bonds:",
+		"  - VWRL: 0.4",
+	}, "\n")
+
+	got, err := LoadUniverse(reg, strings.NewReader(input))
+
+	if err != nil {
+		t.Fatalf("LoadUniverse() returned unexpected error: %v", err)
+	}
+	want := []Allocation{{Class: []string{"Equities", "US", "AAPL"}, Weight: 1}}
+	if diff := cmp.Diff(want, got.Locate(aapl)); diff != "" {
+		t.Errorf("Locate(AAPL): unexpected diff (-want, +got):\n%s", diff)
+	}
+	var total float64
+	for _, a := range got.Locate(vwrl) {
+		total += a.Weight
+	}
+	if len(got.Locate(vwrl)) != 2 || total != 1 {
+		t.Errorf("Locate(VWRL) = %v, want two allocations summing up to 1", got.Locate(vwrl))
+	}
+}
+
+func TestLoadUniverseErrors(t *testing.T) {
+	tests := []struct {
+		desc  string
+		input string
+	}{
+		{
+			desc:  "duplicate classification",
+			input: "A:\n  - AAPL\nB:\n  - AAPL\n",
+		},
+		{
+			desc:  "incomplete allocation",
+			input: "A:\n  - VWRL: 0.6\nB:\n  - VWRL: 0.3\n",
+		},
+		{
+			desc:  "invalid weight",
+			input: "A:\n  - VWRL: -1\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			reg := commodity.NewCommodities()
+
+			_, err := LoadUniverse(reg, strings.NewReader(test.input))
+
+			if err == nil {
+				t.Fatalf("LoadUniverse() returned no error, want an error")
+			}
+		})
+	}
+}
@@ -41,8 +41,20 @@ func (p *Printer) Write(bs []byte) (int, error) {
 	return n, err
 }
 
-// PrintDirective prints a directive to the given Writer.
+// PrintDirective prints a directive to the given Writer. Comments of the
+// directive are printed on the lines before it.
 func (p *Printer) PrintDirective(directive model.Directive) (n int, err error) {
+	start := p.count
+	if _, err := p.printComments(directive); err != nil {
+		return p.count - start, err
+	}
+	if _, err := p.printDirective(directive); err != nil {
+		return p.count - start, err
+	}
+	return p.count - start, nil
+}
+
+func (p *Printer) printDirective(directive model.Directive) (n int, err error) {
 	switch d := directive.(type) {
 	case *model.Transaction:
 		return p.printTransaction(d)
@@ -54,10 +66,37 @@ func (p *Printer) PrintDirective(directive model.Directive) (n int, err error) {
 		return p.printAssertion(d)
 	case *model.Price:
 		return p.printPrice(d)
+	case *model.Split:
+		return p.printSplit(d)
+	case *model.Rename:
+		return p.printRename(d)
 	}
 	return 0, fmt.Errorf("unknown directive: %v", directive)
 }
 
+func (p *Printer) printComments(directive model.Directive) (n int, err error) {
+	var cs []string
+	switch d := directive.(type) {
+	case *model.Transaction:
+		cs = d.Comments
+	case *model.Open:
+		cs = d.Comments
+	case *model.Close:
+		cs = d.Comments
+	case *model.Assertion:
+		cs = d.Comments
+	case *model.Price:
+		cs = d.Comments
+	}
+	start := p.count
+	for _, c := range cs {
+		if _, err := fmt.Fprintf(p, "# %s\n", c); err != nil {
+			return p.count - start, err
+		}
+	}
+	return p.count - start, nil
+}
+
 // PrintDirectiveLn prints a directive to the given Writer, followed by a newline.
 func (p *Printer) PrintDirectiveLn(d model.Directive) (n int, err error) {
 	start := p.count
@@ -115,6 +154,14 @@ func (p *Printer) printPrice(pr *model.Price) (int, error) {
 	return fmt.Fprintf(p, "%s price %s %s %s", pr.Date.Format("2006-01-02"), pr.Commodity.Name(), pr.Price, pr.Target.Name())
 }
 
+func (p *Printer) printSplit(s *model.Split) (int, error) {
+	return fmt.Fprintf(p, "%s split %s %s", s.Date.Format("2006-01-02"), s.Commodity.Name(), s.Ratio())
+}
+
+func (p *Printer) printRename(r *model.Rename) (int, error) {
+	return fmt.Fprintf(p, "%s rename %s %s", r.Date.Format("2006-01-02"), r.Commodity.Name(), r.Target.Name())
+}
+
 func (p *Printer) printAssertion(a *model.Assertion) (int, error) {
 	start := p.count
 	if _, err := fmt.Fprintf(p, "%s balance", a.Date.Format("2006-01-02")); err != nil {
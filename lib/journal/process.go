@@ -2,6 +2,7 @@ package journal
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/sboehler/knut/lib/amounts"
 	"github.com/sboehler/knut/lib/common/compare"
@@ -10,6 +11,7 @@ import (
 	"github.com/sboehler/knut/lib/common/predicate"
 	"github.com/sboehler/knut/lib/common/set"
 	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/account"
 	"github.com/sboehler/knut/lib/model/posting"
 	"github.com/sboehler/knut/lib/model/price"
 	"github.com/sboehler/knut/lib/model/transaction"
@@ -18,20 +20,43 @@ import (
 
 // ComputePrices updates prices.
 func ComputePrices(v *model.Commodity) *Processor {
+	return PriceComputer{Valuation: v}.Compute()
+}
+
+// PriceComputer computes the prices in the valuation commodity.
+type PriceComputer struct {
+	Valuation *model.Commodity
+
+	// Preferred maps commodities to the commodity they should be priced in,
+	// even if a shorter path in the price graph exists (see price.Graph.Prefer).
+	Preferred map[*model.Commodity]*model.Commodity
+}
+
+// Compute returns a processor which updates prices.
+func (pc PriceComputer) Compute() *Processor {
+	v := pc.Valuation
 	if v == nil {
 		return nil
 	}
-	var previous price.NormalizedPrices
-	prc := make(price.Prices)
+	var (
+		previous price.NormalizedPrices
+		quotes   price.Quotes
+	)
+	graph := price.NewGraph()
+	for c, quote := range pc.Preferred {
+		graph.Prefer(c, quote)
+	}
+	var dates map[*model.Commodity]time.Time
 	return &Processor{
-		Price: func(p *model.Price) error {
-			return prc.Insert(p.Commodity, p.Price, p.Target)
-		},
+		Price: graph.Add,
 		DayEnd: func(d *Day) error {
 			if len(d.Prices) > 0 {
-				previous = prc.Normalize(v)
+				previous, quotes = graph.NormalizeWithQuotes(v)
+				dates = graph.Dates(v, quotes)
 			}
 			d.Normalized = previous
+			d.Quotes = quotes
+			d.PriceDates = dates
 			return nil
 		},
 	}
@@ -39,17 +64,73 @@ func ComputePrices(v *model.Commodity) *Processor {
 
 // Balance balances the journal.
 func Valuate(reg *model.Registry, valuation *model.Commodity) *Processor {
+	return Valuator{Registry: reg, Valuation: valuation}.Valuate()
+}
+
+// ValuateWithFX balances the journal like Valuate, but splits value adjustments
+// into a price effect, which is the price change in the quote currency, and an
+// FX effect, which is the change of the quote currency against the valuation
+// commodity. The two effects are booked to separate Price and FX subaccounts
+// of the valuation account.
+//
+// Commodities in which other commodities are quoted, or which are tagged as
+// currencies, are considered currencies and their value changes are booked
+// entirely as FX effects.
+func ValuateWithFX(reg *model.Registry, valuation *model.Commodity) *Processor {
+	return Valuator{Registry: reg, Valuation: valuation, SplitFX: true}.Valuate()
+}
+
+// Valuator valuates the journal.
+type Valuator struct {
+	Registry  *model.Registry
+	Valuation *model.Commodity
+
+	// SplitFX splits value adjustments into price and FX effects, see ValuateWithFX.
+	SplitFX bool
+
+	// MaxPriceAge is the maximum age in days of the prices used to value a
+	// commodity, including the prices of the commodities it is quoted in
+	// (see Day.PriceAge). If it is positive, valuation fails for commodities
+	// with older prices, instead of using stale prices.
+	MaxPriceAge int
+}
+
+// Valuate returns a processor which valuates the journal.
+func (v Valuator) Valuate() *Processor {
+	return valuate(v.Registry, v.Valuation, v.SplitFX, v.MaxPriceAge)
+}
+
+func valuate(reg *model.Registry, valuation *model.Commodity, splitFX bool, maxPriceAge int) *Processor {
 	if valuation == nil {
 		return nil
 	}
 
-	var prevPrices, prices price.NormalizedPrices
+	var (
+		prevPrices, prices price.NormalizedPrices
+		day                *Day
+	)
 	quantities := make(amounts.Amounts)
 
+	checkAge := func(c *model.Commodity) error {
+		if maxPriceAge <= 0 || c == valuation {
+			return nil
+		}
+		if age, ok := day.PriceAge(c); ok && age > maxPriceAge {
+			return fmt.Errorf("%s: price of %s is stale, last price on %s (%d days old)",
+				day.Date.Format("2006-01-02"), c.Name(), day.PriceDates[c].Format("2006-01-02"), age)
+		}
+		return nil
+	}
+
 	return &Processor{
 
 		DayStart: func(d *Day) error {
 			prices = d.Normalized
+			day = d
+			var currencies set.Set[*model.Commodity]
+			if splitFX {
+				currencies = d.Quotes.Currencies()
+			}
 
 			for pos, qty := range quantities {
 				if pos.Commodity == valuation {
@@ -61,6 +142,9 @@ func Valuate(reg *model.Registry, valuation *model.Commodity) *Processor {
 				if qty.IsZero() {
 					continue
 				}
+				if err := checkAge(pos.Commodity); err != nil {
+					return err
+				}
 				prevPrice, err := prevPrices.Price(pos.Commodity)
 				if err != nil {
 					return err
@@ -74,6 +158,14 @@ func Valuate(reg *model.Registry, valuation *model.Commodity) *Processor {
 					continue
 				}
 				gain := price.Multiply(delta, qty)
+				if splitFX {
+					fx, err := fxEffect(d, prevPrices, currencies, pos.Commodity, qty, gain)
+					if err != nil {
+						return err
+					}
+					d.Transactions = append(d.Transactions, splitValuation(reg, d, pos, gain, fx)...)
+					continue
+				}
 				credit := reg.Accounts().ValuationAccountFor(pos.Account)
 				d.Transactions = append(d.Transactions, transaction.Builder{
 					Date:        d.Date,
@@ -101,6 +193,9 @@ func Valuate(reg *model.Registry, valuation *model.Commodity) *Processor {
 				p.Value = p.Quantity
 				return nil
 			}
+			if err := checkAge(p.Commodity); err != nil {
+				return err
+			}
 			v, err := prices.Valuate(p.Commodity, p.Quantity)
 			if err != nil {
 				return err
@@ -116,6 +211,193 @@ func Valuate(reg *model.Registry, valuation *model.Commodity) *Processor {
 	}
 }
 
+// fxEffect computes the part of the given gain which is due to the change of the
+// quote currency of c against the valuation commodity.
+func fxEffect(d *Day, prevPrices price.NormalizedPrices, currencies set.Set[*model.Commodity], c *model.Commodity, qty, gain decimal.Decimal) (decimal.Decimal, error) {
+	if c.IsCurrency || currencies.Has(c) {
+		return gain, nil
+	}
+	quote, ok := d.Quotes[c]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("no quote currency found for %s", c.Name())
+	}
+	prevQuotePrice, err := prevPrices.Price(quote)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	quotePrice, err := d.Normalized.Price(quote)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if prevQuotePrice.IsZero() || quotePrice.Equal(prevQuotePrice) {
+		return decimal.Zero, nil
+	}
+	prevPrice, err := prevPrices.Price(c)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	// previous price of c in its quote currency
+	prevNative := prevPrice.Div(prevQuotePrice)
+	return price.Multiply(price.Multiply(prevNative, quotePrice.Sub(prevQuotePrice)), qty), nil
+}
+
+// splitValuation creates the transactions booking the price and FX effects of the
+// given gain.
+func splitValuation(reg *model.Registry, d *Day, pos amounts.Key, gain, fx decimal.Decimal) []*model.Transaction {
+	var res []*model.Transaction
+	effects := []struct {
+		account *model.Account
+		value   decimal.Decimal
+		desc    string
+	}{
+		{reg.Accounts().PriceValuationAccountFor(pos.Account), gain.Sub(fx), "price"},
+		{reg.Accounts().FXValuationAccountFor(pos.Account), fx, "FX"},
+	}
+	for _, e := range effects {
+		if e.value.IsZero() {
+			continue
+		}
+		res = append(res, transaction.Builder{
+			Date:        d.Date,
+			Description: fmt.Sprintf("Adjust value of %s in account %s (%s)", pos.Commodity.Name(), pos.Account.Name(), e.desc),
+			Postings: posting.Builder{
+				Credit:    e.account,
+				Debit:     pos.Account,
+				Commodity: pos.Commodity,
+				Value:     e.value,
+			}.Build(),
+			Targets: []*model.Commodity{pos.Commodity},
+		}.Build())
+	}
+	return res
+}
+
+// CorporateActions creates the transactions which adjust the holdings in
+// asset and liability accounts for splits and renames. The quantities are
+// booked against Equity:Equity. If the journal is valuated, the value of
+// the adjustment is moved from Equity:Equity to the valuation account of
+// each holding account, so that the change in price caused by a split or
+// rename does not show up as a gain or loss in valuated reports. knut does
+// not track lots, so only account quantities are adjusted.
+//
+// The processor must run after the checker, which tracks splits and
+// renames on its own, and after the prices have been computed.
+func CorporateActions(reg *model.Registry) *Processor {
+	quantities := make(amounts.Amounts)
+	equity := reg.Accounts().MustGet("Equity:Equity")
+
+	holdings := func(quantities amounts.Amounts, c *model.Commodity) []amounts.Key {
+		var res []amounts.Key
+		for pos, qty := range quantities {
+			if pos.Commodity == c && !qty.IsZero() {
+				res = append(res, pos)
+			}
+		}
+		compare.Sort(res, func(k1, k2 amounts.Key) compare.Order {
+			return account.Compare(k1.Account, k2.Account)
+		})
+		return res
+	}
+
+	// revaluation returns the booking which moves the value of the given
+	// changes in holdings from Equity:Equity to the valuation account, if
+	// the journal is valuated.
+	revaluation := func(d *Day, a *model.Account, c *model.Commodity, changes map[*model.Commodity]decimal.Decimal) (posting.Builders, error) {
+		if d.Normalized == nil {
+			return nil, nil
+		}
+		var value decimal.Decimal
+		for com, qty := range changes {
+			v, err := d.Normalized.Valuate(com, qty)
+			if err != nil {
+				return nil, err
+			}
+			value = value.Add(v)
+		}
+		if value.IsZero() {
+			return nil, nil
+		}
+		return posting.Builders{{
+			Credit:    reg.Accounts().ValuationAccountFor(a),
+			Debit:     equity,
+			Commodity: c,
+			Value:     value,
+		}}, nil
+	}
+
+	return &Processor{
+		DayStart: func(d *Day) error {
+			if len(d.Splits) == 0 && len(d.Renames) == 0 {
+				return nil
+			}
+			// the generated transactions are processed by the Transaction hook,
+			// so track the effect of the actions of this day separately.
+			quantities := quantities.Clone()
+			for _, s := range d.Splits {
+				for _, pos := range holdings(quantities, s.Commodity) {
+					qty := quantities[pos]
+					delta := s.Apply(qty).Sub(qty)
+					rv, err := revaluation(d, pos.Account, s.Commodity, map[*model.Commodity]decimal.Decimal{s.Commodity: delta})
+					if err != nil {
+						return err
+					}
+					d.Transactions = append(d.Transactions, transaction.Builder{
+						Date:        d.Date,
+						Description: fmt.Sprintf("Split %s %s in account %s", s.Commodity.Name(), s.Ratio(), pos.Account.Name()),
+						Postings: append(posting.Builders{{
+							Credit:    equity,
+							Debit:     pos.Account,
+							Commodity: s.Commodity,
+							Quantity:  delta,
+						}}, rv...).Build(),
+						Targets: []*model.Commodity{s.Commodity},
+					}.Build())
+					quantities[pos] = s.Apply(qty)
+				}
+			}
+			for _, r := range d.Renames {
+				for _, pos := range holdings(quantities, r.Commodity) {
+					qty := quantities[pos]
+					rv, err := revaluation(d, pos.Account, r.Target, map[*model.Commodity]decimal.Decimal{r.Commodity: qty.Neg(), r.Target: qty})
+					if err != nil {
+						return err
+					}
+					d.Transactions = append(d.Transactions, transaction.Builder{
+						Date:        d.Date,
+						Description: fmt.Sprintf("Rename %s to %s in account %s", r.Commodity.Name(), r.Target.Name(), pos.Account.Name()),
+						Postings: append(posting.Builders{
+							{
+								Credit:    pos.Account,
+								Debit:     equity,
+								Commodity: r.Commodity,
+								Quantity:  qty,
+							},
+							{
+								Credit:    equity,
+								Debit:     pos.Account,
+								Commodity: r.Target,
+								Quantity:  qty,
+							},
+						}, rv...).Build(),
+						Targets: []*model.Commodity{r.Commodity, r.Target},
+					}.Build())
+					delete(quantities, pos)
+					quantities.Add(amounts.AccountCommodityKey(pos.Account, r.Target), qty)
+				}
+			}
+			return nil
+		},
+		Transaction: func(t *model.Transaction) error {
+			for _, p := range t.Postings {
+				if p.Account.IsAL() {
+					quantities.Add(amounts.AccountCommodityKey(p.Account, p.Commodity), p.Quantity)
+				}
+			}
+			return nil
+		},
+	}
+}
+
 func Filter(part date.Partition) *Processor {
 	return &Processor{
 		DayEnd: func(d *Day) error {
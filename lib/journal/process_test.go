@@ -0,0 +1,252 @@
+package journal_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/journaltest"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/account"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/shopspring/decimal"
+)
+
+func TestValuateWithFX(t *testing.T) {
+	const text = `
+2020-01-01 open Equity:Equity
+2020-01-01 open Assets:Portfolio
+
+2020-01-01 "Buy"
+Equity:Equity Assets:Portfolio 10 AAPL
+
+2020-01-01 price USD 1 CHF
+2020-01-01 price AAPL 10 USD
+
+2020-01-02 price USD 1.1 CHF
+2020-01-02 price AAPL 12 USD
+`
+	day := date.Date(2020, 1, 2)
+	valuate := func(splitFX bool) map[string]decimal.Decimal {
+		reg := registry.New()
+		chf := reg.Commodities().MustGet("CHF")
+		j := journaltest.Build(t, reg, text)
+		err := j.Build().Process(
+			journal.ComputePrices(chf),
+			journal.Valuator{Registry: reg, Valuation: chf, SplitFX: splitFX}.Valuate(),
+		)
+		if err != nil {
+			t.Fatalf("Process() returned unexpected error: %v", err)
+		}
+		return valuations(j.Day(day))
+	}
+
+	// AAPL rises from 10 to 12 USD and USD from 1 to 1.1 CHF: the value of
+	// 10 AAPL rises from 100 to 132 CHF. The FX effect is the change in USD
+	// applied to the previous price, 10 * 10 * 0.1 = 10 CHF.
+	want := map[string]decimal.Decimal{
+		"Income:Portfolio:Price": decimal.NewFromInt(22),
+		"Income:Portfolio:FX":    decimal.NewFromInt(10),
+	}
+	got := valuate(true)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected diff (-want, +got):\n%s", diff)
+	}
+
+	unsplit := valuate(false)
+	if diff := cmp.Diff(map[string]decimal.Decimal{"Income:Portfolio": decimal.NewFromInt(32)}, unsplit); diff != "" {
+		t.Fatalf("unexpected diff (-want, +got):\n%s", diff)
+	}
+	if sum := got["Income:Portfolio:Price"].Add(got["Income:Portfolio:FX"]); !sum.Equal(unsplit["Income:Portfolio"]) {
+		t.Fatalf("price and FX effects sum up to %s, want %s", sum, unsplit["Income:Portfolio"])
+	}
+}
+
+func TestCorporateActions(t *testing.T) {
+	const text = `
+2020-01-01 open Equity:Equity
+2020-01-01 open Assets:Portfolio
+
+2020-01-01 "Buy"
+Equity:Equity Assets:Portfolio 10 AAPL
+
+2020-01-01 price AAPL 400 CHF
+
+2020-01-02 price AAPL 100 CHF
+2020-01-02 split AAPL 4:1
+
+2020-01-03 price AAPL 100 CHF
+2020-01-03 price META 110 CHF
+2020-01-03 rename AAPL META
+`
+	type position struct {
+		account, commodity string
+	}
+	run := func(valuate bool) (map[position]decimal.Decimal, map[string]decimal.Decimal) {
+		reg := registry.New()
+		var valuation *model.Commodity
+		if valuate {
+			valuation = reg.Commodities().MustGet("CHF")
+		}
+		j := journaltest.Build(t, reg, text).Build()
+		err := j.Process(
+			journal.ComputePrices(valuation),
+			journal.CorporateActions(reg),
+			journal.Valuate(reg, valuation),
+		)
+		if err != nil {
+			t.Fatalf("Process() returned unexpected error: %v", err)
+		}
+		quantities := make(map[position]decimal.Decimal)
+		values := make(map[string]decimal.Decimal)
+		for _, d := range j.Days {
+			for _, t := range d.Transactions {
+				for _, p := range t.Postings {
+					pos := position{p.Account.Name(), p.Commodity.Name()}
+					if q := quantities[pos].Add(p.Quantity); q.IsZero() {
+						delete(quantities, pos)
+					} else {
+						quantities[pos] = q
+					}
+					values[p.Account.Name()] = values[p.Account.Name()].Add(p.Value)
+				}
+			}
+		}
+		return quantities, values
+	}
+
+	t.Run("quantities", func(t *testing.T) {
+		want := map[position]decimal.Decimal{
+			{"Assets:Portfolio", "META"}: decimal.NewFromInt(40),
+			{"Equity:Equity", "META"}:    decimal.NewFromInt(-40),
+		}
+
+		got, _ := run(false)
+
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("unexpected diff (-want, +got):\n%s", diff)
+		}
+	})
+
+	t.Run("values", func(t *testing.T) {
+		// The split leaves the value unchanged, the rename increases
+		// the value of 40 shares by 10 CHF each.
+		want := map[string]decimal.Decimal{
+			"Assets:Portfolio": decimal.NewFromInt(4400),
+			"Equity:Equity":    decimal.NewFromInt(-4000),
+			"Income:Portfolio": decimal.NewFromInt(-400),
+		}
+
+		_, got := run(true)
+
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("unexpected diff (-want, +got):\n%s", diff)
+		}
+	})
+}
+
+// stalePrices has a stale USD price and daily prices of AAPL in USD.
+const stalePrices = `
+2020-01-01 open Equity:Equity
+2020-01-01 open Assets:Portfolio
+
+2020-01-01 price USD 0.9 CHF
+
+2023-05-01 "Buy"
+Equity:Equity Assets:Portfolio 10 AAPL
+
+2023-05-01 price AAPL 150 USD
+2023-05-31 price AAPL 155 USD
+2023-06-01 price AAPL 160 USD
+2023-06-01 price EUR 1 CHF
+`
+
+func TestPriceAge(t *testing.T) {
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	j := journaltest.Build(t, reg, stalePrices)
+	day := j.Day(date.Date(2023, 6, 1))
+	if err := j.Build().Process(journal.ComputePrices(chf)); err != nil {
+		t.Fatalf("Process() returned unexpected error: %v", err)
+	}
+	usdAge := int(date.Date(2023, 6, 1).Sub(date.Date(2020, 1, 1)).Hours() / 24)
+	tests := []struct {
+		commodity string
+		want      int
+		wantOK    bool
+	}{
+		{commodity: "USD", want: usdAge, wantOK: true},
+		{commodity: "AAPL", want: usdAge, wantOK: true},
+		{commodity: "EUR", want: 0, wantOK: true},
+		{commodity: "GBP"},
+	}
+	for _, test := range tests {
+		t.Run(test.commodity, func(t *testing.T) {
+			got, ok := day.PriceAge(reg.Commodities().MustGet(test.commodity))
+
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("PriceAge(%s) = %d, %t, want %d, %t", test.commodity, got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestValuatorMaxPriceAge(t *testing.T) {
+	tests := []struct {
+		desc        string
+		text        string
+		maxPriceAge int
+		wantErr     bool
+	}{
+		{
+			desc:        "no limit",
+			text:        stalePrices,
+			maxPriceAge: 0,
+		},
+		{
+			desc:        "stale quote currency",
+			text:        stalePrices,
+			maxPriceAge: 30,
+			wantErr:     true,
+		},
+		{
+			desc:        "recent prices",
+			text:        stalePrices + "\n2023-04-30 price USD 0.91 CHF\n",
+			maxPriceAge: 35,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			reg := registry.New()
+			chf := reg.Commodities().MustGet("CHF")
+			j := journaltest.Build(t, reg, test.text)
+
+			err := j.Build().Process(
+				journal.ComputePrices(chf),
+				journal.Valuator{Registry: reg, Valuation: chf, MaxPriceAge: test.maxPriceAge}.Valuate(),
+			)
+
+			if test.wantErr && err == nil {
+				t.Errorf("Process() returned no error, want an error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("Process() returned unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// valuations returns the values credited to the valuation accounts on the
+// given day, by account.
+func valuations(d *journal.Day) map[string]decimal.Decimal {
+	res := make(map[string]decimal.Decimal)
+	for _, t := range d.Transactions {
+		for _, p := range t.Postings {
+			if p.Account.Type() == account.INCOME {
+				res[p.Account.Name()] = res[p.Account.Name()].Sub(p.Value)
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,232 @@
+package rules
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v2"
+
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+// Rule assigns an account to bookings which match all of its conditions.
+// Empty conditions match everything.
+type Rule struct {
+	// Description is matched against the description of the transaction.
+	Description *Regexp `yaml:"description"`
+	// Account is matched against the known account of the booking, i.e.
+	// the side which is not to be assigned.
+	Account *Regexp `yaml:"account"`
+	// Commodity is the commodity of the booking.
+	Commodity string `yaml:"commodity"`
+	// Min and Max are the inclusive bounds of the amount, as seen from the
+	// known account: payments are negative, receipts positive.
+	Min *decimal.Decimal `yaml:"min"`
+	Max *decimal.Decimal `yaml:"max"`
+
+	// Assign is the account which is assigned to matching bookings.
+	Assign string `yaml:"assign"`
+	// Rewrite replaces the description. It may refer to submatches of the
+	// description pattern, e.g. $1 or ${name}.
+	Rewrite string `yaml:"rewrite"`
+	// Tags are appended to the description as #tag.
+	Tags []string `yaml:"tags"`
+}
+
+// Regexp is a regular expression which can be read from YAML.
+type Regexp struct {
+	*regexp.Regexp
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (r *Regexp) UnmarshalYAML(unmarshal func(any) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	rx, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	r.Regexp = rx
+	return nil
+}
+
+// Rules is an ordered list of rules. The first matching rule wins.
+type Rules []*Rule
+
+// Read reads rules from a YAML file.
+func Read(path string) (Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.SetStrict(true)
+	var rs Rules
+	if err := dec.Decode(&rs); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	for i, r := range rs {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("error reading %s: rule %d: %w", path, i+1, err)
+		}
+	}
+	return rs, nil
+}
+
+func (r *Rule) validate() error {
+	if r.Assign == "" {
+		return fmt.Errorf("missing account to assign")
+	}
+	if strings.Contains(r.Rewrite, `"`) {
+		return fmt.Errorf("invalid description %q", r.Rewrite)
+	}
+	for _, tag := range r.Tags {
+		if tag == "" || strings.ContainsAny(tag, "\" \t") {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+	return nil
+}
+
+// Find returns the first rule which matches a booking of the given
+// account, commodity and quantity in a transaction with the given
+// description, or nil.
+func (rs Rules) Find(desc, account, commodity string, quantity decimal.Decimal) *Rule {
+	for _, r := range rs {
+		if r.matches(desc, account, commodity, quantity) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (r *Rule) matches(desc, account, commodity string, quantity decimal.Decimal) bool {
+	if r.Description != nil && !r.Description.MatchString(desc) {
+		return false
+	}
+	if r.Account != nil && !r.Account.MatchString(account) {
+		return false
+	}
+	if r.Commodity != "" && r.Commodity != commodity {
+		return false
+	}
+	if r.Min != nil && quantity.LessThan(*r.Min) {
+		return false
+	}
+	if r.Max != nil && quantity.GreaterThan(*r.Max) {
+		return false
+	}
+	return true
+}
+
+// Describe returns the description with the rewrite and the tags of the
+// rule applied.
+func (r *Rule) Describe(desc string) string {
+	if r.Rewrite != "" {
+		if r.Description != nil {
+			m := r.Description.FindStringSubmatchIndex(desc)
+			desc = string(r.Description.ExpandString(nil, r.Rewrite, desc, m))
+		} else {
+			desc = r.Rewrite
+		}
+	}
+	words := strings.Fields(desc)
+	for _, tag := range r.Tags {
+		tag = "#" + strings.TrimPrefix(tag, "#")
+		if contains(words, tag) {
+			continue
+		}
+		words = append(words, tag)
+		if desc == "" {
+			desc = tag
+		} else {
+			desc += " " + tag
+		}
+	}
+	return desc
+}
+
+func contains(ss []string, s string) bool {
+	for _, s2 := range ss {
+		if s2 == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Infer replaces the given account in the bookings of a transaction for
+// which a rule matches. Bookings without a matching rule are left
+// unchanged, so that they can be inferred by other means.
+func (rs Rules) Infer(t *syntax.Transaction, account string) {
+	desc := t.Description.Content.Extract()
+	var changed bool
+	for i := range t.Bookings {
+		b := &t.Bookings[i]
+		var (
+			known    string
+			target   *syntax.Account
+			quantity decimal.Decimal
+		)
+		switch account {
+		case b.Credit.Extract():
+			known, target = b.Debit.Extract(), &b.Credit
+			quantity, _ = decimal.NewFromString(b.Quantity.Extract())
+		case b.Debit.Extract():
+			known, target = b.Credit.Extract(), &b.Debit
+			quantity, _ = decimal.NewFromString(b.Quantity.Extract())
+			quantity = quantity.Neg()
+		default:
+			continue
+		}
+		r := rs.Find(desc, known, b.Commodity.Extract(), quantity)
+		if r == nil {
+			continue
+		}
+		*target = syntax.Account{
+			Range: syntax.Range{Start: 0, End: len(r.Assign), Text: r.Assign},
+		}
+		desc, changed = r.Describe(desc), true
+	}
+	if changed {
+		t.Description.Content = syntax.Range{Start: 0, End: len(desc), Text: desc}
+	}
+}
+
+// Apply assigns accounts to the postings of a transaction which are booked
+// against the TBD account. The TBD account is compared by name, so t may
+// have been created with a different registry.
+func (rs Rules) Apply(reg *model.Registry, t *model.Transaction) error {
+	tbd := reg.Accounts().TBDAccount().Name()
+	// Postings come in pairs, see posting.Builder.
+	for i := 0; i+1 < len(t.Postings); i += 2 {
+		pairs := [][2]*model.Posting{
+			{t.Postings[i], t.Postings[i+1]},
+			{t.Postings[i+1], t.Postings[i]},
+		}
+		for _, pair := range pairs {
+			target, known := pair[0], pair[1]
+			if target.Account.Name() != tbd || known.Account.Name() == tbd {
+				continue
+			}
+			r := rs.Find(t.Description, known.Account.Name(), known.Commodity.Name(), known.Quantity)
+			if r == nil {
+				continue
+			}
+			a, err := reg.Accounts().Get(r.Assign)
+			if err != nil {
+				return err
+			}
+			target.Account, known.Other = a, a
+			t.Description = r.Describe(t.Description)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package rules
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/shopspring/decimal"
+
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model/posting"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/transaction"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/sboehler/knut/lib/syntax/parser"
+)
+
+const config = `
+- description: 'CH93 0076 2011 6238 5295 7'
+  assign: Expenses:Rent
+  rewrite: Rent
+  tags: [home]
+- description: '(?i)^migros (?P<city>\w+)'
+  account: '^Assets:Bank$'
+  commodity: CHF
+  min: -200
+  max: 0
+  assign: Expenses:Groceries
+  rewrite: 'Migros ${city}'
+`
+
+func TestInfer(t *testing.T) {
+	tests := []struct {
+		desc   string
+		target string
+		want   string
+	}{
+		{
+			desc: "match on description",
+			target: lines(
+				`2022-03-01 "Payment CH93 0076 2011 6238 5295 7"`,
+				`Assets:Bank TBD 1500 CHF`,
+			),
+			want: lines(
+				`2022-03-01 "Rent #home"`,
+				`Assets:Bank Expenses:Rent 1500 CHF`,
+			),
+		},
+		{
+			desc: "match on account, commodity and amount",
+			target: lines(
+				`2022-03-02 "MIGROS ZURICH 1234"`,
+				`Assets:Bank TBD 50 CHF`,
+				``,
+				`2022-03-02 "MIGROS ZURICH 1234"`,
+				`TBD Assets:Bank 50 CHF`,
+				``,
+				`2022-03-02 "MIGROS ZURICH 1234"`,
+				`Assets:Bank TBD 500 CHF`,
+				``,
+				`2022-03-02 "MIGROS ZURICH 1234"`,
+				`Assets:Bank TBD 50 EUR`,
+				``,
+				`2022-03-02 "MIGROS ZURICH 1234"`,
+				`Assets:Cash TBD 50 CHF`,
+			),
+			want: lines(
+				`2022-03-02 "Migros ZURICH"`,
+				`Assets:Bank Expenses:Groceries 50 CHF`,
+				``,
+				`2022-03-02 "MIGROS ZURICH 1234"`,
+				`TBD Assets:Bank 50 CHF`,
+				``,
+				`2022-03-02 "MIGROS ZURICH 1234"`,
+				`Assets:Bank TBD 500 CHF`,
+				``,
+				`2022-03-02 "MIGROS ZURICH 1234"`,
+				`Assets:Bank TBD 50 EUR`,
+				``,
+				`2022-03-02 "MIGROS ZURICH 1234"`,
+				`Assets:Cash TBD 50 CHF`,
+			),
+		},
+	}
+
+	rs := read(t, config)
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			target := parse(t, test.target)
+			for i, d := range target.Directives {
+				if trx, ok := d.Directive.(syntax.Transaction); ok {
+					rs.Infer(&trx, "TBD")
+					target.Directives[i].Directive = trx
+				}
+			}
+			var got bytes.Buffer
+
+			err := syntax.FormatFile(&got, target)
+
+			if err != nil {
+				t.Fatalf("syntax.FormatFile() returned unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(normalize(test.want), normalize(got.String())); diff != "" {
+				t.Fatalf("Infer() returned unexpected diff (-want/+got):\n%s\n", diff)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	var (
+		reg  = registry.New()
+		bank = reg.Accounts().MustGet("Assets:Bank")
+		tbd  = reg.Accounts().TBDAccount()
+		chf  = reg.Commodities().MustGet("CHF")
+		rs   = read(t, config)
+	)
+	b := journal.New()
+	b.Add(transaction.Builder{
+		Date:        date.Date(2022, 3, 2),
+		Description: "Migros Basel",
+		Postings: posting.Builder{
+			Credit:    bank,
+			Debit:     tbd,
+			Commodity: chf,
+			Quantity:  decimal.NewFromInt(20),
+		}.Build(),
+	}.Build())
+	b.Add(transaction.Builder{
+		Date:        date.Date(2022, 3, 2),
+		Description: "Unknown",
+		Postings: posting.Builder{
+			Credit:    bank,
+			Debit:     tbd,
+			Commodity: chf,
+			Quantity:  decimal.NewFromInt(20),
+		}.Build(),
+	}.Build())
+	j := b.Build()
+	for _, d := range j.Days {
+		for _, trx := range d.Transactions {
+			if err := rs.Apply(reg, trx); err != nil {
+				t.Fatalf("Apply() returned unexpected error: %v", err)
+			}
+		}
+	}
+	var got bytes.Buffer
+
+	if err := journal.Print(&got, j); err != nil {
+		t.Fatalf("journal.Print() returned unexpected error: %v", err)
+	}
+
+	want := lines(
+		`2022-03-02 "Migros Basel"`,
+		`Assets:Bank Expenses:Groceries 20 CHF`,
+		``,
+		`2022-03-02 "Unknown"`,
+		`Assets:Bank Expenses:TBD 20 CHF`,
+		``,
+	)
+	if diff := cmp.Diff(normalize(want), normalize(got.String())); diff != "" {
+		t.Fatalf("Apply() returned unexpected diff (-want/+got):\n%s\n", diff)
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config string
+	}{
+		{"missing account", `- description: foo`},
+		{"invalid regex", `- {description: '(', assign: Expenses:Foo}`},
+		{"invalid tag", `- {assign: Expenses:Foo, tags: ['a b']}`},
+		{"unknown field", `- {assign: Expenses:Foo, amount: 3}`},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.yaml")
+			if err := os.WriteFile(path, []byte(test.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Read(path)
+
+			if err == nil {
+				t.Fatalf("Read() returned no error")
+			}
+		})
+	}
+}
+
+func read(t *testing.T, config string) Rules {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() returned unexpected error: %v", err)
+	}
+	return rs
+}
+
+func lines(ss ...string) string {
+	return strings.Join(ss, "\n") + "\n"
+}
+
+// normalize collapses runs of spaces, which depend on the padding of the
+// printer.
+func normalize(s string) string {
+	var res []string
+	for _, l := range strings.Split(s, "\n") {
+		res = append(res, strings.Join(strings.Fields(l), " "))
+	}
+	return strings.Join(res, "\n")
+}
+
+func parse(t *testing.T, s string) syntax.File {
+	t.Helper()
+	p := parser.New(s, "")
+	if err := p.Advance(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := p.ParseFile()
+	if err != nil {
+		t.Fatalf("p.ParseFile() returned unexpected error: %#v", err)
+	}
+	return f
+}
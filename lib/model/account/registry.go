@@ -16,6 +16,7 @@ package account
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"unicode"
@@ -183,3 +184,52 @@ func (as *Registry) ValuationAccountFor(a *Account) *Account {
 	segments := append(as.MustGet("Income").Segments(), a.Segments()[1:]...)
 	return as.MustGet(strings.Join(segments, ":"))
 }
+
+// PriceValuationAccountFor returns the account for price effects of valuations
+// of the given Asset or Liability account.
+func (as *Registry) PriceValuationAccountFor(a *Account) *Account {
+	return as.MustGet(as.ValuationAccountFor(a).Name() + ":Price")
+}
+
+// FXValuationAccountFor returns the account for FX effects of valuations
+// of the given Asset or Liability account.
+func (as *Registry) FXValuationAccountFor(a *Account) *Account {
+	return as.MustGet(as.ValuationAccountFor(a).Name() + ":FX")
+}
+
+// Complete returns the accounts which match the given abbreviation, sorted
+// by name. Every colon-separated segment of the abbreviation must be a
+// case-insensitive prefix of the corresponding segment of the account,
+// e.g. "exp:gro" matches "Expenses:Groceries". An abbreviation without a
+// colon matches the last segment of the account.
+func (as *Registry) Complete(abbrev string) []*Account {
+	abbrev = strings.ToLower(abbrev)
+	as.mutex.RLock()
+	defer as.mutex.RUnlock()
+	var res []*Account
+	for _, a := range as.index {
+		if matchesAbbrev(a.segments, abbrev) {
+			res = append(res, a)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].name < res[j].name
+	})
+	return res
+}
+
+func matchesAbbrev(segments []string, abbrev string) bool {
+	if !strings.Contains(abbrev, ":") {
+		return strings.HasPrefix(strings.ToLower(segments[len(segments)-1]), abbrev)
+	}
+	parts := strings.Split(abbrev, ":")
+	if len(parts) != len(segments) {
+		return false
+	}
+	for i, p := range parts {
+		if !strings.HasPrefix(strings.ToLower(segments[i]), p) {
+			return false
+		}
+	}
+	return true
+}
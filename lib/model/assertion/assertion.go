@@ -16,6 +16,9 @@ type Assertion struct {
 	Src      *syntax.Assertion
 	Date     time.Time
 	Balances []Balance
+
+	// Comments are printed before the directive.
+	Comments []string
 }
 
 type Balance struct {
@@ -13,6 +13,9 @@ type Close struct {
 	Src     *syntax.Close
 	Date    time.Time
 	Account *account.Account
+
+	// Comments are printed before the directive.
+	Comments []string
 }
 
 func Create(reg *registry.Registry, c *syntax.Close) (*Close, error) {
@@ -13,6 +13,8 @@ import (
 	"github.com/sboehler/knut/lib/model/posting"
 	"github.com/sboehler/knut/lib/model/price"
 	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/model/rename"
+	"github.com/sboehler/knut/lib/model/split"
 	"github.com/sboehler/knut/lib/model/transaction"
 	"github.com/sboehler/knut/lib/syntax"
 	"github.com/sourcegraph/conc/pool"
@@ -28,6 +30,8 @@ type Close = cls.Close
 type Price = price.Price
 type Assertion = assertion.Assertion
 type Balance = assertion.Balance
+type Split = split.Split
+type Rename = rename.Rename
 
 type Registry = registry.Registry
 
@@ -38,6 +42,8 @@ var (
 	_ Directive = (*cls.Close)(nil)
 	_ Directive = (*open.Open)(nil)
 	_ Directive = (*price.Price)(nil)
+	_ Directive = (*split.Split)(nil)
+	_ Directive = (*rename.Rename)(nil)
 	_ Directive = (*transaction.Transaction)(nil)
 )
 
@@ -103,6 +109,18 @@ func ParseDirective(reg *registry.Registry, w syntax.Directive) ([]Directive, er
 			return nil, err
 		}
 		return []Directive{o}, nil
+	case syntax.Split:
+		o, err := split.Create(reg, &d)
+		if err != nil {
+			return nil, err
+		}
+		return []Directive{o}, nil
+	case syntax.Rename:
+		o, err := rename.Create(reg, &d)
+		if err != nil {
+			return nil, err
+		}
+		return []Directive{o}, nil
 	case syntax.Include:
 		return nil, nil
 	}
@@ -13,6 +13,9 @@ type Open struct {
 	Src     *syntax.Open
 	Date    time.Time
 	Account *account.Account
+
+	// Comments are printed before the directive.
+	Comments []string
 }
 
 func Create(reg *registry.Registry, o *syntax.Open) (*Open, error) {
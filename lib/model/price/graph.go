@@ -0,0 +1,134 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package price
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/shopspring/decimal"
+)
+
+type edge struct {
+	quote, commodity *commodity.Commodity
+}
+
+// Graph is a price graph which keeps track of the date of the most recent
+// price of every edge. Commodities are priced along a shortest path in the
+// graph. If several shortest paths exist, the most recently updated edges
+// are chosen, and finally edges are chosen by the name of the quote
+// commodity. Preferred edges (see Prefer) win over shortest paths.
+type Graph struct {
+	Prices Prices
+
+	updated   map[edge]time.Time
+	preferred Quotes
+}
+
+// NewGraph creates a new price graph.
+func NewGraph() *Graph {
+	return &Graph{
+		Prices:    make(Prices),
+		updated:   make(map[edge]time.Time),
+		preferred: make(Quotes),
+	}
+}
+
+// Prefer makes the graph price c in quote whenever a price of c in quote
+// exists and quote can be priced itself, even if a shorter path exists.
+func (g *Graph) Prefer(c, quote *commodity.Commodity) {
+	g.preferred[c] = quote
+}
+
+// Add adds a price to the graph.
+func (g *Graph) Add(p *Price) error {
+	if err := g.Prices.Insert(p.Commodity, p.Price, p.Target); err != nil {
+		return err
+	}
+	g.updated[edge{p.Target, p.Commodity}] = p.Date
+	g.updated[edge{p.Commodity, p.Target}] = p.Date
+	return nil
+}
+
+// NormalizeWithQuotes creates a normalized price map for the given commodity,
+// along with the commodities in which the commodities are quoted on their
+// path to t.
+func (g *Graph) NormalizeWithQuotes(t *commodity.Commodity) (NormalizedPrices, Quotes) {
+	return g.Prices.normalize(t, g.preferred, g.better)
+}
+
+// Dates returns the date of the oldest price on the path of every commodity
+// in quotes to t, where quotes are the quotes returned by NormalizeWithQuotes.
+// A normalized price is only as recent as the oldest price it is derived from.
+func (g *Graph) Dates(t *commodity.Commodity, quotes Quotes) map[*commodity.Commodity]time.Time {
+	res := make(map[*commodity.Commodity]time.Time)
+	var date func(c *commodity.Commodity) time.Time
+	date = func(c *commodity.Commodity) time.Time {
+		if d, ok := res[c]; ok {
+			return d
+		}
+		quote := quotes[c]
+		d := g.updated[edge{quote, c}]
+		if quote != t {
+			if qd := date(quote); qd.Before(d) {
+				d = qd
+			}
+		}
+		res[c] = d
+		return d
+	}
+	for c := range quotes {
+		date(c)
+	}
+	return res
+}
+
+func (g *Graph) better(quote, other, c *commodity.Commodity) bool {
+	if t1, t2 := g.updated[edge{quote, c}], g.updated[edge{other, c}]; !t1.Equal(t2) {
+		return t1.After(t2)
+	}
+	return byName(quote, other, c)
+}
+
+// Step is an edge on the path used to price a commodity.
+type Step struct {
+	// Commodity is quoted in Quote at the given Price.
+	Commodity, Quote *commodity.Commodity
+	Price            decimal.Decimal
+
+	// Date is the date of the most recent price of this edge.
+	Date time.Time
+}
+
+// Explain returns the path used to price c in t, starting at c.
+func (g *Graph) Explain(t, c *commodity.Commodity) ([]Step, error) {
+	_, quotes := g.NormalizeWithQuotes(t)
+	var res []Step
+	for current := c; current != t; {
+		quote, ok := quotes[current]
+		if !ok {
+			return nil, fmt.Errorf("no price found for %s in %s", c.Name(), t.Name())
+		}
+		res = append(res, Step{
+			Commodity: current,
+			Quote:     quote,
+			Price:     g.Prices[quote][current],
+			Date:      g.updated[edge{quote, current}],
+		})
+		current = quote
+	}
+	return res, nil
+}
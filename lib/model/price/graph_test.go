@@ -0,0 +1,196 @@
+package price
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/shopspring/decimal"
+)
+
+func TestGraphPrefersRecentEdges(t *testing.T) {
+	reg := registry.New()
+	com := reg.Commodities().MustGet("COM")
+	usd := reg.Commodities().MustGet("USD")
+	eur := reg.Commodities().MustGet("EUR")
+	chf := reg.Commodities().MustGet("CHF")
+	d1, d2 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		desc   string
+		prices []*Price
+		want   *commodity.Commodity
+	}{
+		{
+			desc: "recent USD",
+			prices: []*Price{
+				{Date: d1, Commodity: usd, Price: decimal.RequireFromString("0.9"), Target: chf},
+				{Date: d1, Commodity: eur, Price: decimal.RequireFromString("1.0"), Target: chf},
+				{Date: d1, Commodity: com, Price: decimal.RequireFromString("9"), Target: eur},
+				{Date: d2, Commodity: com, Price: decimal.RequireFromString("10"), Target: usd},
+			},
+			want: usd,
+		},
+		{
+			desc: "recent EUR",
+			prices: []*Price{
+				{Date: d1, Commodity: usd, Price: decimal.RequireFromString("0.9"), Target: chf},
+				{Date: d1, Commodity: eur, Price: decimal.RequireFromString("1.0"), Target: chf},
+				{Date: d1, Commodity: com, Price: decimal.RequireFromString("10"), Target: usd},
+				{Date: d2, Commodity: com, Price: decimal.RequireFromString("9"), Target: eur},
+			},
+			want: eur,
+		},
+		{
+			desc: "tie",
+			prices: []*Price{
+				{Date: d1, Commodity: usd, Price: decimal.RequireFromString("0.9"), Target: chf},
+				{Date: d1, Commodity: eur, Price: decimal.RequireFromString("1.0"), Target: chf},
+				{Date: d1, Commodity: com, Price: decimal.RequireFromString("10"), Target: usd},
+				{Date: d1, Commodity: com, Price: decimal.RequireFromString("9"), Target: eur},
+			},
+			want: eur,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			g := NewGraph()
+			for _, p := range test.prices {
+				if err := g.Add(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, quotes := g.NormalizeWithQuotes(chf)
+
+			if quotes[com] != test.want {
+				t.Errorf("quote of COM = %s, want %s", quotes[com].Name(), test.want.Name())
+			}
+		})
+	}
+}
+
+func TestGraphExplain(t *testing.T) {
+	reg := registry.New()
+	com := reg.Commodities().MustGet("COM")
+	usd := reg.Commodities().MustGet("USD")
+	chf := reg.Commodities().MustGet("CHF")
+	d1, d2 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	g := NewGraph()
+	g.Add(&Price{Date: d1, Commodity: usd, Price: decimal.RequireFromString("0.9"), Target: chf})
+	g.Add(&Price{Date: d2, Commodity: com, Price: decimal.RequireFromString("10"), Target: usd})
+
+	got, err := g.Explain(chf, com)
+
+	if err != nil {
+		t.Fatalf("g.Explain() returned unexpected error: %v", err)
+	}
+	want := []Step{
+		{Commodity: com, Quote: usd, Price: decimal.RequireFromString("10"), Date: d2},
+		{Commodity: usd, Quote: chf, Price: decimal.RequireFromString("0.9"), Date: d1},
+	}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b *commodity.Commodity) bool { return a == b })); diff != "" {
+		t.Errorf("g.Explain() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+	if _, err := g.Explain(chf, reg.Commodities().MustGet("XYZ")); err == nil {
+		t.Errorf("g.Explain(XYZ) returned no error, want an error")
+	}
+}
+
+func TestGraphDates(t *testing.T) {
+	reg := registry.New()
+	com := reg.Commodities().MustGet("COM")
+	usd := reg.Commodities().MustGet("USD")
+	eur := reg.Commodities().MustGet("EUR")
+	chf := reg.Commodities().MustGet("CHF")
+	d1, d2, d3 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 6, 2, 0, 0, 0, 0, time.UTC)
+	g := NewGraph()
+	g.Add(&Price{Date: d1, Commodity: usd, Price: decimal.RequireFromString("0.9"), Target: chf})
+	g.Add(&Price{Date: d2, Commodity: com, Price: decimal.RequireFromString("10"), Target: usd})
+	g.Add(&Price{Date: d3, Commodity: chf, Price: decimal.RequireFromString("1.05"), Target: eur})
+
+	_, quotes := g.NormalizeWithQuotes(chf)
+	got := g.Dates(chf, quotes)
+
+	want := map[*commodity.Commodity]time.Time{
+		usd: d1,
+		com: d1,
+		eur: d3,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("g.Dates() returned unexpected diff (-want/+got):\n%s", diff)
+	}
+}
+
+func TestGraphPrefer(t *testing.T) {
+	reg := registry.New()
+	usd := reg.Commodities().MustGet("USD")
+	eur := reg.Commodities().MustGet("EUR")
+	chf := reg.Commodities().MustGet("CHF")
+	d1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		desc      string
+		prices    []*Price
+		preferred map[*commodity.Commodity]*commodity.Commodity
+		want      *commodity.Commodity
+		wantPrice decimal.Decimal
+	}{
+		{
+			desc: "shortest path",
+			prices: []*Price{
+				{Date: d1, Commodity: usd, Price: decimal.RequireFromString("0.9"), Target: chf},
+				{Date: d1, Commodity: eur, Price: decimal.RequireFromString("1.1"), Target: usd},
+				{Date: d1, Commodity: eur, Price: decimal.RequireFromString("0.95"), Target: chf},
+			},
+			want:      chf,
+			wantPrice: decimal.RequireFromString("0.95"),
+		},
+		{
+			desc: "preferred edge wins over shorter path",
+			prices: []*Price{
+				{Date: d1, Commodity: usd, Price: decimal.RequireFromString("0.9"), Target: chf},
+				{Date: d1, Commodity: eur, Price: decimal.RequireFromString("1.1"), Target: usd},
+				{Date: d1, Commodity: eur, Price: decimal.RequireFromString("0.95"), Target: chf},
+			},
+			preferred: map[*commodity.Commodity]*commodity.Commodity{eur: usd},
+			want:      usd,
+			wantPrice: decimal.RequireFromString("0.99"),
+		},
+		{
+			desc: "preferred quote is only priced through the commodity",
+			prices: []*Price{
+				{Date: d1, Commodity: eur, Price: decimal.RequireFromString("1.1"), Target: usd},
+				{Date: d1, Commodity: eur, Price: decimal.RequireFromString("0.95"), Target: chf},
+			},
+			preferred: map[*commodity.Commodity]*commodity.Commodity{eur: usd},
+			want:      chf,
+			wantPrice: decimal.RequireFromString("0.95"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			g := NewGraph()
+			for _, p := range test.prices {
+				if err := g.Add(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for c, quote := range test.preferred {
+				g.Prefer(c, quote)
+			}
+
+			prices, quotes := g.NormalizeWithQuotes(chf)
+
+			if quotes[eur] != test.want {
+				t.Errorf("quote of EUR = %s, want %s", quotes[eur].Name(), test.want.Name())
+			}
+			if !prices[eur].Equal(test.wantPrice) {
+				t.Errorf("price of EUR = %s, want %s", prices[eur], test.wantPrice)
+			}
+			if _, ok := prices[usd]; !ok {
+				t.Errorf("USD has no price")
+			}
+		})
+	}
+}
@@ -16,6 +16,9 @@ type Price struct {
 	Commodity *commodity.Commodity
 	Price     decimal.Decimal
 	Target    *commodity.Commodity
+
+	// Comments are printed before the directive.
+	Comments []string
 }
 
 func Create(reg *registry.Registry, p *syntax.Price) (*Price, error) {
@@ -18,6 +18,7 @@ import (
 	"fmt"
 
 	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/common/set"
 	"github.com/sboehler/knut/lib/model/commodity"
 	"github.com/shopspring/decimal"
 )
@@ -46,21 +47,99 @@ func (ps Prices) addPrice(target, commodity *commodity.Commodity, price decimal.
 
 // Normalize creates a normalized price map for the given commodity.
 func (ps Prices) Normalize(t *commodity.Commodity) NormalizedPrices {
-	res := NormalizedPrices{t: one}
-	ps.normalize(t, res)
+	res, _ := ps.NormalizeWithQuotes(t)
 	return res
 }
 
-// normalize recursively computes prices by traversing the price graph.
-// res must already contain a price for c.
-func (ps Prices) normalize(c *commodity.Commodity, res NormalizedPrices) {
-	for neighbor, price := range ps[c] {
-		if _, done := res[neighbor]; done {
-			continue
+// NormalizeWithQuotes creates a normalized price map for the given commodity,
+// along with the commodities in which the commodities are quoted on their
+// path to t.
+func (ps Prices) NormalizeWithQuotes(t *commodity.Commodity) (NormalizedPrices, Quotes) {
+	return ps.normalize(t, nil, byName)
+}
+
+// normalize computes prices by a breadth-first traversal of the price graph,
+// so that every commodity is priced along a shortest path to t. If several
+// shortest paths exist, the edge (quote, c) is chosen over (other, c) if
+// better(quote, other, c) holds.
+//
+// A commodity c with a preferred quote in preferred is priced in that quote
+// if a price exists and the quote can be priced in t, even if the resulting
+// path is longer than a shortest path.
+func (ps Prices) normalize(t *commodity.Commodity, preferred Quotes, better func(quote, other, c *commodity.Commodity) bool) (NormalizedPrices, Quotes) {
+	res, quotes := NormalizedPrices{t: one}, make(Quotes)
+	frontier := []*commodity.Commodity{t}
+	// deferred holds commodities waiting for their preferred quote to be priced,
+	// along with the quote of their shortest path.
+	deferred := make(Quotes)
+	for len(frontier) > 0 || len(deferred) > 0 {
+		next := make(Quotes)
+		force := len(frontier) == 0
+		if force {
+			// the preferred quotes can not be priced, fall back to shortest paths
+			next, deferred = deferred, make(Quotes)
+		}
+		for _, quote := range frontier {
+			for c := range ps[quote] {
+				if _, done := res[c]; done {
+					continue
+				}
+				if other, ok := next[c]; !ok || quote == preferred[c] || other != preferred[c] && better(quote, other, c) {
+					next[c] = quote
+				}
+			}
+		}
+		frontier = frontier[:0]
+		if !force {
+			// decide before pricing, as the preferred quote must have been
+			// priced in an earlier round
+			for c, quote := range next {
+				if ps.waitsFor(res, preferred[c], quote, c) {
+					if _, ok := deferred[c]; !ok {
+						deferred[c] = quote
+					}
+					delete(next, c)
+				}
+			}
 		}
-		res[neighbor] = Multiply(price, res[c])
-		ps.normalize(neighbor, res)
+		for c, quote := range next {
+			delete(deferred, c)
+			res[c] = Multiply(ps[quote][c], res[quote])
+			quotes[c] = quote
+			frontier = append(frontier, c)
+		}
+	}
+	return res, quotes
+}
+
+// waitsFor returns whether c, reached through quote, waits for its preferred
+// quote to be priced.
+func (ps Prices) waitsFor(res NormalizedPrices, preferred, quote, c *commodity.Commodity) bool {
+	if preferred == nil || preferred == quote {
+		return false
 	}
+	if _, ok := ps[preferred][c]; !ok {
+		return false
+	}
+	_, done := res[preferred]
+	return !done
+}
+
+func byName(quote, other, _ *commodity.Commodity) bool {
+	return quote.Name() < other.Name()
+}
+
+// Quotes maps commodities to the commodity in which they are quoted
+// on their path to the target of a normalization.
+type Quotes map[*commodity.Commodity]*commodity.Commodity
+
+// Currencies returns the commodities in which other commodities are quoted.
+func (qs Quotes) Currencies() set.Set[*commodity.Commodity] {
+	res := set.New[*commodity.Commodity]()
+	for _, q := range qs {
+		res.Add(q)
+	}
+	return res
 }
 
 // NormalizedPrices is a map representing the price of
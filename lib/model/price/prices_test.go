@@ -134,3 +134,47 @@ func TestNormalize(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeWithQuotes(t *testing.T) {
+	reg := registry.New()
+	com1 := reg.Commodities().MustGet("COM1")
+	com2 := reg.Commodities().MustGet("COM2")
+	com3 := reg.Commodities().MustGet("COM3")
+	pr := make(Prices)
+	pr.Insert(com1, decimal.RequireFromString("4.0"), com2)
+	pr.Insert(com2, decimal.RequireFromString("2.0"), com3)
+
+	_, got := pr.NormalizeWithQuotes(com3)
+
+	want := Quotes{com1: com2, com2: com3}
+	if len(got) != len(want) {
+		t.Fatalf("NormalizeWithQuotes() = %v, want %v", got, want)
+	}
+	for c, q := range want {
+		if got[c] != q {
+			t.Errorf("quote of %s = %v, want %v", c, got[c], q)
+		}
+	}
+}
+
+func TestNormalizeShortestPath(t *testing.T) {
+	reg := registry.New()
+	com1 := reg.Commodities().MustGet("COM1")
+	com2 := reg.Commodities().MustGet("COM2")
+	com3 := reg.Commodities().MustGet("COM3")
+	pr := make(Prices)
+	pr.Insert(com1, decimal.RequireFromString("4.0"), com2)
+	pr.Insert(com2, decimal.RequireFromString("2.0"), com3)
+	pr.Insert(com1, decimal.RequireFromString("7.0"), com3)
+
+	for i := 0; i < 10; i++ {
+		got, quotes := pr.NormalizeWithQuotes(com3)
+
+		if want := decimal.RequireFromString("7"); !got[com1].Equal(want) {
+			t.Fatalf("price of COM1 = %s, want %s", got[com1], want)
+		}
+		if quotes[com1] != com3 {
+			t.Fatalf("quote of COM1 = %s, want COM3", quotes[com1])
+		}
+	}
+}
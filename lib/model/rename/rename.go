@@ -0,0 +1,41 @@
+package rename
+
+import (
+	"time"
+
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+// Rename represents a change of the symbol of a commodity, for example
+// after a ticker or ISIN change. Holdings of Commodity are moved to Target.
+type Rename struct {
+	Src               *syntax.Rename
+	Date              time.Time
+	Commodity, Target *commodity.Commodity
+}
+
+func Create(reg *registry.Registry, r *syntax.Rename) (*Rename, error) {
+	date, err := r.Date.Parse()
+	if err != nil {
+		return nil, err
+	}
+	com, err := reg.Commodities().Create(r.Commodity)
+	if err != nil {
+		return nil, err
+	}
+	tgt, err := reg.Commodities().Create(r.Target)
+	if err != nil {
+		return nil, err
+	}
+	if com == tgt {
+		return nil, syntax.Error{Range: r.Range, Message: "cannot rename a commodity to itself"}
+	}
+	return &Rename{
+		Src:       r,
+		Date:      date,
+		Commodity: com,
+		Target:    tgt,
+	}, nil
+}
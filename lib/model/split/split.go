@@ -0,0 +1,58 @@
+package split
+
+import (
+	"time"
+
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/syntax"
+	"github.com/shopspring/decimal"
+)
+
+// Split represents a stock split, where each Denominator units of
+// a commodity become Numerator units.
+type Split struct {
+	Src                    *syntax.Split
+	Date                   time.Time
+	Commodity              *commodity.Commodity
+	Numerator, Denominator decimal.Decimal
+}
+
+// Apply returns the quantity after the split.
+func (s *Split) Apply(qty decimal.Decimal) decimal.Decimal {
+	return qty.Mul(s.Numerator).Div(s.Denominator)
+}
+
+// Ratio returns the split ratio in the form N:D.
+func (s *Split) Ratio() string {
+	return s.Numerator.String() + ":" + s.Denominator.String()
+}
+
+func Create(reg *registry.Registry, s *syntax.Split) (*Split, error) {
+	date, err := s.Date.Parse()
+	if err != nil {
+		return nil, err
+	}
+	com, err := reg.Commodities().Create(s.Commodity)
+	if err != nil {
+		return nil, err
+	}
+	num, err := s.Numerator.Parse()
+	if err != nil {
+		return nil, err
+	}
+	den, err := s.Denominator.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if !num.IsPositive() || !den.IsPositive() {
+		return nil, syntax.Error{Range: s.Range, Message: "split ratio must be positive"}
+	}
+	return &Split{
+		Src:         s,
+		Date:        date,
+		Commodity:   com,
+		Numerator:   num,
+		Denominator: den,
+	}, nil
+}
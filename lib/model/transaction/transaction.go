@@ -20,6 +20,10 @@ type Transaction struct {
 	Description string
 	Postings    []*posting.Posting
 	Targets     []*commodity.Commodity
+
+	// Comments are printed before the transaction, e.g. the metadata of
+	// an imported transaction.
+	Comments []string
 }
 
 // Less defines an order on transactions.
@@ -45,6 +49,7 @@ type Builder struct {
 	Description string
 	Postings    []*posting.Posting
 	Targets     []*commodity.Commodity
+	Comments    []string
 }
 
 // Build builds a transactions.
@@ -55,6 +60,7 @@ func (tb Builder) Build() *Transaction {
 		Description: tb.Description,
 		Postings:    tb.Postings,
 		Targets:     tb.Targets,
+		Comments:    tb.Comments,
 	}
 }
 
@@ -0,0 +1,260 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ecb implements a quote provider for the euro foreign exchange
+// reference rates published by the European Central Bank. It understands
+// the daily and historical files in XML and CSV format, the latter also
+// wrapped in a zip archive.
+package ecb
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sboehler/knut/lib/quotes"
+)
+
+// HistoryURL is the URL of the full history of reference rates.
+const HistoryURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml"
+
+// Rate is the price of one EUR in a currency on a given day.
+type Rate struct {
+	Date     time.Time
+	Currency string
+	Rate     float64
+}
+
+// Client provides the rates of a source as quotes. The quote for
+// a symbol is the price of one EUR in the currency of the symbol.
+type Client struct {
+	src string
+
+	// rates caches the rates of the source once they have been read
+	// successfully. Errors are not cached, so that a failed read can be
+	// retried.
+	mutex sync.Mutex
+	rates map[string][]quotes.Quote
+}
+
+var _ quotes.Provider = (*Client)(nil)
+
+// New creates a client for the given source, which is either an HTTP(S)
+// URL or the path to a local file. The source is read only once, when it
+// is read successfully.
+func New(src string) *Client {
+	if src == "" {
+		src = HistoryURL
+	}
+	return &Client{src: src}
+}
+
+// Fetch fetches a set of quotes.
+func (c *Client) Fetch(ctx context.Context, sym string, t0, t1 time.Time) ([]quotes.Quote, error) {
+	rates, err := c.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	qs, ok := rates[sym]
+	if !ok {
+		return nil, fmt.Errorf("no rates found for currency %s in %s", sym, c.src)
+	}
+	t0 = time.Date(t0.Year(), t0.Month(), t0.Day(), 0, 0, 0, 0, time.UTC)
+	var res []quotes.Quote
+	for _, q := range qs {
+		if q.Date.Before(t0) || q.Date.After(t1) {
+			continue
+		}
+		res = append(res, q)
+	}
+	return res, nil
+}
+
+func (c *Client) load(ctx context.Context) (map[string][]quotes.Quote, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.rates != nil {
+		return c.rates, nil
+	}
+	rates, err := Read(ctx, c.src)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string][]quotes.Quote)
+	for _, r := range rates {
+		res[r.Currency] = append(res[r.Currency], quotes.Quote{
+			Date:  r.Date,
+			Close: r.Rate,
+		})
+	}
+	c.rates = res
+	return res, nil
+}
+
+// Read reads the rates from the given source, which is either an HTTP(S)
+// URL or the path to a local file.
+func Read(ctx context.Context, src string) ([]Rate, error) {
+	var r io.Reader
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		resp, err := quotes.Get(ctx, src)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching data from URL %s: %w", src, err)
+		}
+		defer resp.Body.Close()
+		r = resp.Body
+	} else {
+		f, err := os.Open(src)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	rates, err := Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", src, err)
+	}
+	return rates, nil
+}
+
+// Parse parses reference rates in XML, CSV or zipped CSV format.
+func Parse(r io.Reader) ([]Rate, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimSpace(b)
+	switch {
+	case bytes.HasPrefix(b, []byte("PK")):
+		return parseZip(b)
+	case bytes.HasPrefix(b, []byte("<")):
+		return parseXML(bytes.NewReader(b))
+	default:
+		return parseCSV(bytes.NewReader(b))
+	}
+}
+
+type xmlEnvelope struct {
+	Cube struct {
+		Days []struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string `xml:"currency,attr"`
+				Rate     string `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+func parseXML(r io.Reader) ([]Rate, error) {
+	var env xmlEnvelope
+	if err := xml.NewDecoder(r).Decode(&env); err != nil {
+		return nil, err
+	}
+	var res []Rate
+	for _, day := range env.Cube.Days {
+		date, err := time.Parse("2006-01-02", day.Time)
+		if err != nil {
+			return nil, err
+		}
+		for _, rate := range day.Rates {
+			f, err := strconv.ParseFloat(rate.Rate, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rate %q for %s on %s: %w", rate.Rate, rate.Currency, day.Time, err)
+			}
+			res = append(res, Rate{Date: date, Currency: rate.Currency, Rate: f})
+		}
+	}
+	return res, nil
+}
+
+// dateFormats are the date formats of the historical and the daily CSV files.
+var dateFormats = []string{"2006-01-02", "02 January 2006"}
+
+func parseCSV(r io.Reader) ([]Rate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(header) == 0 || strings.TrimSpace(header[0]) != "Date" {
+		return nil, fmt.Errorf("invalid header: %v", header)
+	}
+	var res []Rate
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		date, err := parseDate(strings.TrimSpace(rec[0]))
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i < len(rec) && i < len(header); i++ {
+			cur, val := strings.TrimSpace(header[i]), strings.TrimSpace(rec[i])
+			if cur == "" || val == "" || val == "N/A" {
+				continue
+			}
+			f, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rate %q for %s on %s: %w", val, cur, rec[0], err)
+			}
+			res = append(res, Rate{Date: date, Currency: cur, Rate: f})
+		}
+	}
+	return res, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, f := range dateFormats {
+		if d, err := time.Parse(f, s); err == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func parseZip(b []byte) ([]Rate, error) {
+	z, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range z.File {
+		if path.Ext(f.Name) != ".csv" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return parseCSV(r)
+	}
+	return nil, fmt.Errorf("no CSV file found in zip archive")
+}
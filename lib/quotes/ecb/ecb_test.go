@@ -0,0 +1,177 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ecb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/quotes"
+)
+
+func TestRead(t *testing.T) {
+	history := []Rate{
+		{Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), Currency: "USD", Rate: 1.0921},
+		{Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), Currency: "CHF", Rate: 0.9314},
+		{Date: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), Currency: "USD", Rate: 1.0953},
+		{Date: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), Currency: "CHF", Rate: 0.9323},
+		{Date: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Currency: "USD", Rate: 1.0919},
+		{Date: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Currency: "CHF", Rate: 0.9299},
+	}
+	tests := []struct {
+		file string
+		only []string
+		want []Rate
+	}{
+		{
+			file: "eurofxref-daily.xml",
+			want: []Rate{
+				{Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), Currency: "USD", Rate: 1.0921},
+				{Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), Currency: "JPY", Rate: 158.30},
+				{Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), Currency: "GBP", Rate: 0.86008},
+				{Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), Currency: "CHF", Rate: 0.9314},
+			},
+		},
+		{
+			file: "eurofxref.csv",
+			want: []Rate{
+				{Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), Currency: "USD", Rate: 1.0921},
+				{Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), Currency: "JPY", Rate: 158.30},
+				{Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), Currency: "CHF", Rate: 0.9314},
+			},
+		},
+		{
+			file: "eurofxref-hist.xml",
+			want: history,
+		},
+		{
+			file: "eurofxref-hist.csv",
+			only: []string{"USD", "CHF"},
+			want: history,
+		},
+		{
+			file: "eurofxref-hist.zip",
+			only: []string{"USD", "CHF"},
+			want: history,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			got, err := Read(context.Background(), filepath.Join("testdata", test.file))
+
+			if err != nil {
+				t.Fatalf("Read() returned unexpected error: %v", err)
+			}
+			if test.only != nil {
+				got = filterRates(got, test.only)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Read() returned difference (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func filterRates(rates []Rate, currencies []string) []Rate {
+	var res []Rate
+	for _, r := range rates {
+		for _, c := range currencies {
+			if r.Currency == c {
+				res = append(res, r)
+			}
+		}
+	}
+	return res
+}
+
+func TestFetch(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "eurofxref-hist.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(b)
+	}))
+	defer srv.Close()
+	client := New(srv.URL)
+	t0, t1 := time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	usd, err := client.Fetch(context.Background(), "USD", t0, t1)
+	if err != nil {
+		t.Fatalf("client.Fetch() returned unexpected error: %v", err)
+	}
+	chf, err := client.Fetch(context.Background(), "CHF", t0, t1)
+	if err != nil {
+		t.Fatalf("client.Fetch() returned unexpected error: %v", err)
+	}
+	_, err = client.Fetch(context.Background(), "XYZ", t0, t1)
+
+	if err == nil {
+		t.Errorf("client.Fetch(XYZ) returned no error, want an error")
+	}
+	if requests != 1 {
+		t.Errorf("client made %d requests, want 1", requests)
+	}
+	wantUSD := []quotes.Quote{
+		{Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), Close: 1.0921},
+		{Date: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), Close: 1.0953},
+	}
+	if diff := cmp.Diff(wantUSD, usd); diff != "" {
+		t.Errorf("client.Fetch(USD) returned difference (-want, +got):\n%s", diff)
+	}
+	if len(chf) != 2 {
+		t.Errorf("client.Fetch(CHF) returned %d quotes, want 2", len(chf))
+	}
+}
+
+func TestFetchRetriesAfterError(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "eurofxref-daily.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(b)
+	}))
+	defer srv.Close()
+	client := New(srv.URL)
+	t0, t1 := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := client.Fetch(context.Background(), "USD", t0, t1); err == nil {
+		t.Fatalf("client.Fetch() returned no error, want an error")
+	}
+	if _, err := client.Fetch(context.Background(), "USD", t0, t1); err != nil {
+		t.Fatalf("client.Fetch() returned unexpected error after a failed read: %v", err)
+	}
+	if _, err := client.Fetch(context.Background(), "CHF", t0, t1); err != nil {
+		t.Fatalf("client.Fetch() returned unexpected error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("client.Fetch() made %d requests, want 2", requests)
+	}
+}
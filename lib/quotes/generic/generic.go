@@ -0,0 +1,255 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package generic implements a quote provider for HTTP endpoints returning
+// JSON or CSV, described by a configuration.
+package generic
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sboehler/knut/lib/quotes"
+)
+
+// Config describes an HTTP endpoint.
+type Config struct {
+	// URL is a text/template for the URL. It is executed with the fields
+	// Symbol (string), From and To (time.Time).
+	URL string `yaml:"url"`
+
+	// Format is either "json" or "csv".
+	Format string `yaml:"format"`
+
+	// DateFormat is the layout of dates in the response, in the format
+	// of the time package. The special value "unix" denotes unix
+	// timestamps in seconds. Defaults to "2006-01-02".
+	DateFormat string `yaml:"date_format"`
+
+	// Path is the dot-separated path to the array of quotes in a JSON
+	// response. An empty path denotes the root.
+	Path string `yaml:"path"`
+	// Date and Close are the dot-separated paths to the date and the
+	// closing price within an element of the JSON array of quotes.
+	Date  string `yaml:"date"`
+	Close string `yaml:"close"`
+
+	// Delimiter is the CSV delimiter, defaults to ",".
+	Delimiter string `yaml:"delimiter"`
+	// Skip is the number of CSV header rows to skip.
+	Skip int `yaml:"skip"`
+	// DateColumn and CloseColumn are the zero-based CSV column indexes.
+	DateColumn  int `yaml:"date_column"`
+	CloseColumn int `yaml:"close_column"`
+}
+
+// Client is a client for a configured HTTP endpoint.
+type Client struct {
+	cfg Config
+	url *template.Template
+}
+
+var _ quotes.Provider = (*Client)(nil)
+
+// New creates a new client for the given configuration.
+func New(cfg Config) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("missing URL")
+	}
+	t, err := template.New("url").Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL template %q: %w", cfg.URL, err)
+	}
+	switch cfg.Format {
+	case "json":
+		if cfg.Date == "" || cfg.Close == "" {
+			return nil, fmt.Errorf("JSON format requires date and close paths")
+		}
+	case "csv":
+		if cfg.Delimiter == "" {
+			cfg.Delimiter = ","
+		}
+		if len([]rune(cfg.Delimiter)) != 1 {
+			return nil, fmt.Errorf("invalid CSV delimiter %q", cfg.Delimiter)
+		}
+	default:
+		return nil, fmt.Errorf("invalid format %q, expected json or csv", cfg.Format)
+	}
+	if cfg.DateFormat == "" {
+		cfg.DateFormat = "2006-01-02"
+	}
+	return &Client{cfg: cfg, url: t}, nil
+}
+
+// Fetch fetches a set of quotes
+func (c *Client) Fetch(ctx context.Context, sym string, t0, t1 time.Time) ([]quotes.Quote, error) {
+	var u bytes.Buffer
+	err := c.url.Execute(&u, struct {
+		Symbol   string
+		From, To time.Time
+	}{sym, t0, t1})
+	if err != nil {
+		return nil, fmt.Errorf("error creating URL for symbol %s: %w", sym, err)
+	}
+	resp, err := quotes.Get(ctx, u.String())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching data from URL %s: %w", u.String(), err)
+	}
+	defer resp.Body.Close()
+	var res []quotes.Quote
+	if c.cfg.Format == "json" {
+		res, err = c.decodeJSON(resp.Body)
+	} else {
+		res, err = c.decodeCSV(resp.Body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding response for symbol %s (url: %s): %w", sym, u.String(), err)
+	}
+	return filter(res, t0, t1), nil
+}
+
+func (c *Client) decodeJSON(r io.Reader) ([]quotes.Quote, error) {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	var body any
+	if err := d.Decode(&body); err != nil {
+		return nil, err
+	}
+	elems, err := lookup(body, c.cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := elems.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an array at path %q, got %T", c.cfg.Path, elems)
+	}
+	var res []quotes.Quote
+	for _, elem := range arr {
+		date, err := lookup(elem, c.cfg.Date)
+		if err != nil {
+			return nil, err
+		}
+		price, err := lookup(elem, c.cfg.Close)
+		if err != nil {
+			return nil, err
+		}
+		q, err := c.parseQuote(fmt.Sprint(date), fmt.Sprint(price))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, q)
+	}
+	return res, nil
+}
+
+func (c *Client) decodeCSV(r io.Reader) ([]quotes.Quote, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = []rune(c.cfg.Delimiter)[0]
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	var res []quotes.Quote
+	for i := 0; ; i++ {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if i < c.cfg.Skip {
+			continue
+		}
+		if c.cfg.DateColumn >= len(rec) || c.cfg.CloseColumn >= len(rec) {
+			return nil, fmt.Errorf("record %v has too few columns", rec)
+		}
+		q, err := c.parseQuote(rec[c.cfg.DateColumn], rec[c.cfg.CloseColumn])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, q)
+	}
+	return res, nil
+}
+
+func (c *Client) parseQuote(date, price string) (quotes.Quote, error) {
+	var (
+		d   time.Time
+		err error
+	)
+	if c.cfg.DateFormat == "unix" {
+		var ts int64
+		if ts, err = strconv.ParseInt(date, 10, 64); err == nil {
+			d = time.Unix(ts, 0).UTC()
+		}
+	} else {
+		d, err = time.Parse(c.cfg.DateFormat, date)
+	}
+	if err != nil {
+		return quotes.Quote{}, fmt.Errorf("invalid date %q: %w", date, err)
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(price), 64)
+	if err != nil {
+		return quotes.Quote{}, fmt.Errorf("invalid price %q: %w", price, err)
+	}
+	return quotes.Quote{
+		Date:  time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC),
+		Close: f,
+	}, nil
+}
+
+// lookup returns the value at the given dot-separated path.
+func lookup(v any, path string) (any, error) {
+	if path == "" {
+		return v, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch t := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = t[key]; !ok {
+				return nil, fmt.Errorf("key %q not found in path %q", key, path)
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, fmt.Errorf("invalid index %q in path %q", key, path)
+			}
+			v = t[i]
+		default:
+			return nil, fmt.Errorf("cannot resolve %q in path %q", key, path)
+		}
+	}
+	return v, nil
+}
+
+// filter removes invalid quotes and quotes outside of the given range.
+func filter(qs []quotes.Quote, t0, t1 time.Time) []quotes.Quote {
+	t0 = time.Date(t0.Year(), t0.Month(), t0.Day(), 0, 0, 0, 0, time.UTC)
+	var res []quotes.Quote
+	for _, q := range qs {
+		if q.Close <= 0 || q.Date.Before(t0) || q.Date.After(t1) {
+			continue
+		}
+		res = append(res, q)
+	}
+	return res
+}
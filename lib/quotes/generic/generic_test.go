@@ -0,0 +1,134 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package generic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/quotes"
+)
+
+func TestFetch(t *testing.T) {
+	want := []quotes.Quote{
+		{Date: time.Date(2019, 11, 7, 0, 0, 0, 0, time.UTC), Close: 1308.86},
+		{Date: time.Date(2019, 11, 8, 0, 0, 0, 0, time.UTC), Close: 1311.37},
+	}
+	tests := []struct {
+		desc     string
+		response string
+		cfg      Config
+	}{
+		{
+			desc:     "json",
+			response: `{"data": {"prices": [{"d": "2019-11-07", "p": 1308.86}, {"d": "2019-11-08", "p": "1311.37"}]}}`,
+			cfg: Config{
+				Format: "json",
+				Path:   "data.prices",
+				Date:   "d",
+				Close:  "p",
+			},
+		},
+		{
+			desc:     "json with unix timestamps",
+			response: `[{"t": 1573084800, "v": {"close": 1308.86}}, {"t": 1573171200, "v": {"close": 1311.37}}]`,
+			cfg: Config{
+				Format:     "json",
+				DateFormat: "unix",
+				Date:       "t",
+				Close:      "v.close",
+			},
+		},
+		{
+			desc:     "csv",
+			response: "Date;Open;Close\n07.11.2019;1294.28;1308.86\n08.11.2019;1305.28;1311.37\n09.11.2019;1311.37;0\n",
+			cfg: Config{
+				Format:      "csv",
+				DateFormat:  "02.01.2006",
+				Delimiter:   ";",
+				Skip:        1,
+				DateColumn:  0,
+				CloseColumn: 2,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var gotPath string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.RequestURI()
+				w.Write([]byte(test.response))
+			}))
+			defer srv.Close()
+			test.cfg.URL = srv.URL + `/quotes/{{.Symbol}}?from={{.From.Format "2006-01-02"}}&to={{.To.Unix}}`
+			client, err := New(test.cfg)
+			if err != nil {
+				t.Fatalf("New() returned unexpected error: %v", err)
+			}
+
+			got, err := client.Fetch(context.Background(), "GOOG", time.Date(2019, 11, 7, 0, 0, 0, 0, time.UTC), time.Date(2019, 11, 9, 0, 0, 0, 0, time.UTC))
+
+			if err != nil {
+				t.Fatalf("client.Fetch() returned unexpected error: %v", err)
+			}
+			if wantPath := "/quotes/GOOG?from=2019-11-07&to=1573257600"; gotPath != wantPath {
+				t.Errorf("client.Fetch() requested %q, want %q", gotPath, wantPath)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("client.Fetch() returned difference (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	tests := []Config{
+		{Format: "json", Date: "d", Close: "c"},
+		{URL: "http://localhost", Format: "xml"},
+		{URL: "http://localhost", Format: "json", Date: "d"},
+		{URL: "http://localhost", Format: "csv", Delimiter: ";;"},
+	}
+	for _, cfg := range tests {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) returned no error, want an error", cfg)
+		}
+	}
+}
+
+func TestFetchCanceled(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+	client, err := New(Config{URL: srv.URL, Format: "csv"})
+	if err != nil {
+		t.Fatalf("New() returned unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.Fetch(ctx, "GOOG", time.Time{}, time.Time{}); err == nil {
+		t.Fatalf("client.Fetch() returned no error for a stuck server, want an error")
+	}
+}
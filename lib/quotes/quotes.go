@@ -0,0 +1,92 @@
+// Copyright 2021 Silvio Böhler
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package quotes defines the interface implemented by quote providers.
+package quotes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Quote represents a quote on a given day.
+type Quote struct {
+	Date     time.Time
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	AdjClose float64
+	Volume   int
+}
+
+// Provider fetches quotes for a symbol.
+type Provider interface {
+	// Fetch fetches the quotes for the given symbol between t0 and t1.
+	Fetch(ctx context.Context, sym string, t0, t1 time.Time) ([]Quote, error)
+}
+
+// Timeout is the timeout of HTTP requests of quote providers.
+const Timeout = 30 * time.Second
+
+var client = &http.Client{Timeout: Timeout}
+
+// Get issues a GET request to the given URL, which is canceled if the
+// context is done or the request takes longer than Timeout. Responses
+// with a status other than 200 OK are returned as a *StatusError.
+func Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return resp, nil
+}
+
+// StatusError is the error for an HTTP response with a status other
+// than 200 OK.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return e.Status
+}
+
+// IsTransient returns whether the given error is likely to go away if
+// the request is repeated. These are network errors, timeouts, and
+// responses with status 429 or 5xx. Other errors, such as unknown
+// symbols or invalid responses, are permanent.
+func IsTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
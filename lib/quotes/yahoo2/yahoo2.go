@@ -15,45 +15,41 @@
 package yahoo2
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"net/url"
 	"path"
 	"time"
+
+	"github.com/sboehler/knut/lib/quotes"
 )
 
 const yahooURL string = "https://query2.finance.yahoo.com/v8/finance/chart"
 
 // Quote represents a quote on a given day.
-type Quote struct {
-	Date     time.Time
-	Open     float64
-	High     float64
-	Low      float64
-	Close    float64
-	AdjClose float64
-	Volume   int
-}
+type Quote = quotes.Quote
 
 // Client is a client for Yahoo! quotes.
 type Client struct {
 	url string
 }
 
+var _ quotes.Provider = (*Client)(nil)
+
 // New creates a new client with the default URL.
 func New() Client {
 	return Client{yahooURL}
 }
 
 // Fetch fetches a set of quotes
-func (c *Client) Fetch(sym string, t0, t1 time.Time) ([]Quote, error) {
+func (c *Client) Fetch(ctx context.Context, sym string, t0, t1 time.Time) ([]Quote, error) {
 	u, err := createURL(c.url, sym, t0, t1)
 	if err != nil {
 		return nil, fmt.Errorf("error creating URL for symbol %s: %w", sym, err)
 	}
-	resp, err := http.Get(u.String())
+	resp, err := quotes.Get(ctx, u.String())
 	if err != nil {
 		return nil, fmt.Errorf("error fetching data from URL %s: %w", u.String(), err)
 	}
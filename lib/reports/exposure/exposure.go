@@ -0,0 +1,151 @@
+package exposure
+
+import (
+	"time"
+
+	"github.com/sboehler/knut/lib/common/compare"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/performance"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/reports/weights"
+)
+
+// Unmapped is the name of the group of commodities which are neither mapped
+// to currencies nor currencies themselves.
+const Unmapped = "(unmapped)"
+
+type Query struct {
+	Partition date.Partition
+	Exposures performance.Exposures
+	Valuation *model.Commodity
+}
+
+// Execute computes the currency weights at the end of every period into w. If
+// fx is not nil, the contribution of exchange rate changes to the portfolio
+// return is computed as well, based on the currency weights at the beginning of
+// each period.
+//
+// Commodities which are not mapped are considered currencies if they are the
+// valuation commodity, tagged as currencies or used to quote other
+// commodities, as in journal.ValuateWithFX. Other commodities, e.g. unmapped
+// securities, are grouped as Unmapped and do not contribute to FX returns.
+func (q Query) Execute(j *journal.Builder, w *weights.Report, fx *Report) *journal.Processor {
+	days := set.FromSlice(j.Days(q.Partition.EndDates()))
+	var (
+		prevWeights map[*model.Commodity]float64
+		prevPrices  map[*model.Commodity]float64
+	)
+	return &journal.Processor{
+		DayEnd: func(d *journal.Day) error {
+			if !days.Has(d) {
+				return nil
+			}
+			var total float64
+			for _, v := range d.Performance.V1 {
+				total += v
+			}
+			currencies := d.Quotes.Currencies()
+			currentWeights := make(map[*model.Commodity]float64)
+			for com, v := range d.Performance.V1 {
+				exposures, ok := q.Exposures.Locate(com)
+				if !ok && (com == q.Valuation || currencies.Has(com)) {
+					exposures, ok = []performance.Exposure{{Currency: com, Weight: 1}}, true
+				}
+				if !ok {
+					w.Add([]string{Unmapped, com.Name()}, d.Date, v/total)
+					continue
+				}
+				for _, e := range exposures {
+					weight := e.Weight * v / total
+					w.Add([]string{e.Currency.Name(), com.Name()}, d.Date, weight)
+					currentWeights[e.Currency] += weight
+				}
+			}
+			if fx == nil {
+				return nil
+			}
+			currentPrices := make(map[*model.Commodity]float64)
+			for cur := range currentWeights {
+				if p, err := d.Normalized.Price(cur); err == nil {
+					currentPrices[cur], _ = p.Float64()
+				}
+			}
+			if prevWeights != nil {
+				fx.dates.Add(d.Date)
+				for cur, weight := range prevWeights {
+					if cur == q.Valuation {
+						continue
+					}
+					p0, ok0 := prevPrices[cur]
+					p1, ok1 := currentPrices[cur]
+					if !ok0 || !ok1 || p0 == 0 {
+						continue
+					}
+					fx.Add(cur, d.Date, weight*(p1/p0-1))
+				}
+			}
+			prevWeights, prevPrices = currentWeights, currentPrices
+			return nil
+		},
+	}
+}
+
+// Report holds the contributions of currencies to portfolio returns.
+type Report struct {
+	dates         set.Set[time.Time]
+	contributions map[*model.Commodity]map[time.Time]float64
+}
+
+func NewReport() *Report {
+	return &Report{
+		dates:         set.New[time.Time](),
+		contributions: make(map[*model.Commodity]map[time.Time]float64),
+	}
+}
+
+func (r *Report) Add(c *model.Commodity, date time.Time, contribution float64) {
+	dict.GetDefault(r.contributions, c, func() map[time.Time]float64 {
+		return make(map[time.Time]float64)
+	})[date] += contribution
+	r.dates.Add(date)
+}
+
+// Render renders the report.
+func (r *Report) Render() *table.Table {
+	dates := r.dates.Sorted(compare.Time)
+	tbl := table.New(1, len(dates))
+	tbl.AddSeparatorRow()
+	header := tbl.AddRow()
+	header.AddText("FX contribution", table.Center)
+	for _, date := range dates {
+		header.AddText(date.Format("2006-01-02"), table.Center)
+	}
+	tbl.AddSeparatorRow()
+	totals := make(map[time.Time]float64)
+	for _, cur := range dict.SortedKeys(r.contributions, commodity.Compare) {
+		row := tbl.AddRow()
+		row.AddIndented(cur.Name(), 0)
+		for _, date := range dates {
+			c, ok := r.contributions[cur][date]
+			if !ok {
+				row.AddEmpty()
+				continue
+			}
+			row.AddPercent(c)
+			totals[date] += c
+		}
+	}
+	tbl.AddSeparatorRow()
+	row := tbl.AddRow()
+	row.AddText("Total", table.Left)
+	for _, date := range dates {
+		row.AddPercent(totals[date])
+	}
+	tbl.AddSeparatorRow()
+	return tbl
+}
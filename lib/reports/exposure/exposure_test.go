@@ -0,0 +1,136 @@
+package exposure
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/journaltest"
+	"github.com/sboehler/knut/lib/journal/performance"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/sboehler/knut/lib/reports/weights"
+)
+
+const testJournal = `
+2020-01-01 open Equity:Equity
+2020-01-01 open Assets:Portfolio
+
+2020-01-01 "Deposit"
+Equity:Equity Assets:Portfolio 100 CHF
+
+2020-01-01 "Buy"
+Equity:Equity Assets:Portfolio 10 AAPL
+
+2020-01-01 price USD 1 CHF
+2020-01-01 price AAPL 10 USD
+
+2020-01-02 price USD 1.1 CHF
+`
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		desc      string
+		exposures string
+		want      []string
+	}{
+		{
+			desc:      "mapped security",
+			exposures: "AAPL: USD",
+			want: []string{
+				"+-----------+------------+------------+",
+				"| Commodity | 2020-01-01 | 2020-01-02 |",
+				"+-----------+------------+------------+",
+				"| USD       |      50.0% |      52.4% |",
+				"|   AAPL    |      50.0% |      52.4% |",
+				"| CHF       |      50.0% |      47.6% |",
+				"|   CHF     |      50.0% |      47.6% |",
+				"+-----------+------------+------------+",
+				"",
+				"",
+				"+-----------------+------------+",
+				"| FX contribution | 2020-01-02 |",
+				"+-----------------+------------+",
+				"| USD             |       5.0% |",
+				"+-----------------+------------+",
+				"| Total           |       5.0% |",
+				"+-----------------+------------+",
+				"",
+				"",
+			},
+		},
+		{
+			// the price return of AAPL must not be reported as an FX contribution
+			desc:      "unmapped security",
+			exposures: "VWRL: USD",
+			want: []string{
+				"+------------+------------+------------+",
+				"| Commodity  | 2020-01-01 | 2020-01-02 |",
+				"+------------+------------+------------+",
+				"| (unmapped) |      50.0% |      52.4% |",
+				"|   AAPL     |      50.0% |      52.4% |",
+				"| CHF        |      50.0% |      47.6% |",
+				"|   CHF      |      50.0% |      47.6% |",
+				"+------------+------------+------------+",
+				"",
+				"",
+				"+-----------------+------------+",
+				"| FX contribution | 2020-01-02 |",
+				"+-----------------+------------+",
+				"+-----------------+------------+",
+				"| Total           |       0.0% |",
+				"+-----------------+------------+",
+				"",
+				"",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			reg := registry.New()
+			chf := reg.Commodities().MustGet("CHF")
+			exposures, err := performance.LoadExposures(reg.Commodities(), strings.NewReader(test.exposures))
+			if err != nil {
+				t.Fatal(err)
+			}
+			j := journaltest.Build(t, reg, testJournal)
+			partition := date.NewPartition(j.Period(), date.Daily, 0)
+			calculator := &performance.Calculator{
+				Context:         reg,
+				Valuation:       chf,
+				AccountFilter:   predicate.True[*model.Account],
+				CommodityFilter: predicate.True[*model.Commodity],
+			}
+			rep := weights.NewReport()
+			fx := NewReport()
+
+			err = j.Build().Process(
+				journal.ComputePrices(chf),
+				journal.Valuate(reg, chf),
+				calculator.ComputeValues(),
+				Query{Partition: partition, Exposures: exposures, Valuation: chf}.Execute(j, rep, fx),
+			)
+
+			if err != nil {
+				t.Fatalf("Process() returned unexpected error: %v", err)
+			}
+			var got bytes.Buffer
+			renderer := table.TextRenderer{Round: 1}
+			if err := renderer.Render(new(weights.Renderer).Render(rep), &got); err != nil {
+				t.Fatal(err)
+			}
+			got.WriteString("\n")
+			if err := renderer.Render(fx.Render(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(strings.Join(test.want, "\n"), got.String()); diff != "" {
+				t.Fatalf("unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package prices
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/sboehler/knut/lib/common/compare"
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/dict"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/common/set"
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/commodity"
+	"github.com/sboehler/knut/lib/model/price"
+	"github.com/shopspring/decimal"
+)
+
+// Interpolation determines the price on days without a price.
+type Interpolation int
+
+const (
+	// Previous uses the most recent price, as in valuations.
+	Previous Interpolation = iota
+	// Linear interpolates linearly between the most recent and the next
+	// price of a commodity.
+	Linear
+)
+
+// ParseInterpolation parses an interpolation.
+func ParseInterpolation(s string) (Interpolation, error) {
+	switch s {
+	case "previous":
+		return Previous, nil
+	case "linear":
+		return Linear, nil
+	}
+	return 0, fmt.Errorf("invalid interpolation %q, expected previous or linear", s)
+}
+
+type Query struct {
+	Partition   date.Partition
+	Commodities predicate.Predicate[*model.Commodity]
+	Valuation   *model.Commodity
+}
+
+// Execute collects the normalized prices into r. It requires the prices
+// to be computed by journal.ComputePrices.
+func (q Query) Execute(j *journal.Builder, r *Report) *journal.Processor {
+	if q.Commodities == nil {
+		q.Commodities = predicate.True[*model.Commodity]
+	}
+	days := set.FromSlice(j.Days(q.Partition.EndDates()))
+	return &journal.Processor{
+		DayEnd: func(d *journal.Day) error {
+			// commodities with a price on this day are observed.
+			observed := set.New[*model.Commodity]()
+			for _, p := range d.Prices {
+				observed.Add(p.Commodity)
+				observed.Add(p.Target)
+			}
+			for c, p := range d.Normalized {
+				if c == q.Valuation || !q.Commodities(c) {
+					continue
+				}
+				if observed.Has(c) {
+					r.observations[c] = append(r.observations[c], observation{d.Date, p})
+				}
+				if days.Has(d) {
+					dict.GetDefault(r.effective, d.Date, newNormalizedPrices)[c] = p
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newNormalizedPrices() price.NormalizedPrices {
+	return make(price.NormalizedPrices)
+}
+
+type observation struct {
+	date  time.Time
+	price decimal.Decimal
+}
+
+// Report holds normalized prices.
+type Report struct {
+	valuation     *model.Commodity
+	interpolation Interpolation
+
+	effective    map[time.Time]price.NormalizedPrices
+	observations map[*model.Commodity][]observation
+}
+
+// NewReport creates a new report.
+func NewReport(valuation *model.Commodity, interpolation Interpolation) *Report {
+	return &Report{
+		valuation:     valuation,
+		interpolation: interpolation,
+		effective:     make(map[time.Time]price.NormalizedPrices),
+		observations:  make(map[*model.Commodity][]observation),
+	}
+}
+
+func (r *Report) dates() []time.Time {
+	return dict.SortedKeys(r.effective, compare.Time)
+}
+
+func (r *Report) commodities() []*model.Commodity {
+	cs := set.New[*model.Commodity]()
+	for _, np := range r.effective {
+		for c := range np {
+			cs.Add(c)
+		}
+	}
+	return cs.Sorted(commodity.Compare)
+}
+
+// Price returns the price of c on the given date.
+func (r *Report) Price(c *model.Commodity, t time.Time) (decimal.Decimal, bool) {
+	p, ok := r.effective[t][c]
+	if !ok || r.interpolation == Previous {
+		return p, ok
+	}
+	obs := r.observations[c]
+	i := sort.Search(len(obs), func(i int) bool { return obs[i].date.After(t) })
+	if i == 0 || i == len(obs) {
+		return p, ok
+	}
+	prev, next := obs[i-1], obs[i]
+	if prev.date.Equal(t) {
+		return prev.price, true
+	}
+	f := decimal.NewFromFloat(t.Sub(prev.date).Hours() / next.date.Sub(prev.date).Hours())
+	return price.Multiply(next.price.Sub(prev.price), f).Add(prev.price), true
+}
+
+// Render renders the report, with a row per date and a column per commodity.
+func (r *Report) Render() *table.Table {
+	dates, commodities := r.dates(), r.commodities()
+	tbl := table.New(1, len(commodities))
+	tbl.AddSeparatorRow()
+	header := tbl.AddRow()
+	header.AddText("Date", table.Center)
+	for _, c := range commodities {
+		header.AddText(c.Name(), table.Center)
+	}
+	tbl.AddSeparatorRow()
+	for _, t := range dates {
+		row := tbl.AddRow()
+		row.AddText(t.Format("2006-01-02"), table.Left)
+		for _, c := range commodities {
+			p, ok := r.Price(c, t)
+			if !ok {
+				row.AddEmpty()
+				continue
+			}
+			row.AddDecimal(p)
+		}
+	}
+	tbl.AddSeparatorRow()
+	return tbl
+}
+
+type jsonPrice struct {
+	Date      string      `json:"date"`
+	Commodity string      `json:"commodity"`
+	Price     json.Number `json:"price"`
+	Target    string      `json:"target"`
+}
+
+// WriteJSON writes the report as a JSON array of prices.
+func (r *Report) WriteJSON(w io.Writer) error {
+	res := []jsonPrice{}
+	for _, t := range r.dates() {
+		for _, c := range r.commodities() {
+			p, ok := r.Price(c, t)
+			if !ok {
+				continue
+			}
+			res = append(res, jsonPrice{
+				Date:      t.Format("2006-01-02"),
+				Commodity: c.Name(),
+				Price:     json.Number(p.String()),
+				Target:    r.valuation.Name(),
+			})
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
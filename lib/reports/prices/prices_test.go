@@ -0,0 +1,67 @@
+package prices
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/journaltest"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+	"github.com/shopspring/decimal"
+)
+
+const testJournal = `
+2023-01-01 price AAPL 100 CHF
+2023-01-03 price USD 0.9 CHF
+2023-01-05 price AAPL 140 CHF
+`
+
+func TestPriceInterpolation(t *testing.T) {
+	d1 := date.Date(2023, 1, 1)
+	d2 := date.Date(2023, 1, 2)
+	d5 := date.Date(2023, 1, 5)
+	d6 := date.Date(2023, 1, 6)
+	tests := []struct {
+		interpolation Interpolation
+		date          time.Time
+		want          string
+	}{
+		{Previous, d1, "100"},
+		{Previous, d2, "100"},
+		{Previous, d5, "140"},
+		{Previous, d6, "140"},
+		{Linear, d1, "100"},
+		{Linear, d2, "110"},
+		{Linear, d5, "140"},
+		{Linear, d6, "140"},
+	}
+	for _, test := range tests {
+		reg := registry.New()
+		chf := reg.Commodities().MustGet("CHF")
+		aapl := reg.Commodities().MustGet("AAPL")
+		usd := reg.Commodities().MustGet("USD")
+		j := journaltest.Build(t, reg, testJournal)
+		partition := date.NewPartition(date.Period{Start: d1, End: d6}, date.Daily, 0)
+		r := NewReport(chf, test.interpolation)
+		query := Query{
+			Partition:   partition,
+			Commodities: func(c *model.Commodity) bool { return c != usd },
+			Valuation:   chf,
+		}.Execute(j, r)
+
+		err := j.Build().Process(journal.ComputePrices(chf), query)
+
+		if err != nil {
+			t.Fatalf("Process() returned unexpected error: %v", err)
+		}
+		got, ok := r.Price(aapl, test.date)
+		if !ok || !got.Equal(decimal.RequireFromString(test.want)) {
+			t.Errorf("Price(%v, %s) = %s, %t, want %s", test.interpolation, test.date.Format("2006-01-02"), got, ok, test.want)
+		}
+		if p, ok := r.Price(usd, test.date); ok {
+			t.Errorf("Price(USD, %s) = %s, want no price", test.date.Format("2006-01-02"), p)
+		}
+	}
+}
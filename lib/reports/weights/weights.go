@@ -32,12 +32,14 @@ func (q Query) Execute(j *journal.Builder, r *Report) *journal.Processor {
 				total += v
 			}
 			for com, v := range d.Performance.V1 {
-				ss := q.Universe.Locate(com)
-				level, suffix, ok := q.Mapping.Level(strings.Join(ss, ":"))
-				if ok && level < len(ss)-suffix {
-					ss = append(ss[:level], ss[len(ss)-suffix:]...)
+				for _, a := range q.Universe.Locate(com) {
+					ss := a.Class
+					level, suffix, ok := q.Mapping.Level(strings.Join(ss, ":"))
+					if ok && level < len(ss)-suffix {
+						ss = append(append([]string{}, ss[:level]...), ss[len(ss)-suffix:]...)
+					}
+					r.Add(ss, d.Date, a.Weight*v/total)
 				}
-				r.Add(ss, d.Date, v/total)
 			}
 			return nil
 		},
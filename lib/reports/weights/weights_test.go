@@ -0,0 +1,74 @@
+package weights
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/sboehler/knut/lib/common/date"
+	"github.com/sboehler/knut/lib/common/predicate"
+	"github.com/sboehler/knut/lib/journal"
+	"github.com/sboehler/knut/lib/journal/journaltest"
+	"github.com/sboehler/knut/lib/journal/performance"
+	"github.com/sboehler/knut/lib/model"
+	"github.com/sboehler/knut/lib/model/registry"
+)
+
+const testJournal = `
+2020-01-01 open Equity:Equity
+2020-01-01 open Assets:Portfolio
+
+2020-01-01 "Deposit"
+Equity:Equity Assets:Portfolio 100 CHF
+
+2020-01-01 "Buy"
+Equity:Equity Assets:Portfolio 10 VWRL
+
+2020-01-01 price VWRL 10 CHF
+`
+
+func TestExecuteSplitsAllocations(t *testing.T) {
+	reg := registry.New()
+	chf := reg.Commodities().MustGet("CHF")
+	universe, err := performance.LoadUniverse(reg.Commodities(), strings.NewReader(strings.Join([]string{
+		"Equities:World:",
+		"  - VWRL: 0.6",
+		"Bonds:",
+		"  - VWRL: 0.4",
+	}, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := journaltest.Build(t, reg, testJournal)
+	partition := date.NewPartition(j.Period(), date.Daily, 0)
+	calculator := &performance.Calculator{
+		Context:         reg,
+		Valuation:       chf,
+		AccountFilter:   predicate.True[*model.Account],
+		CommodityFilter: predicate.True[*model.Commodity],
+	}
+	rep := NewReport()
+
+	err = j.Build().Process(
+		journal.ComputePrices(chf),
+		journal.Valuate(reg, chf),
+		calculator.ComputeValues(),
+		Query{Partition: partition, Universe: universe}.Execute(j, rep),
+	)
+
+	if err != nil {
+		t.Fatalf("Process() returned unexpected error: %v", err)
+	}
+	// the value of VWRL is split 60/40 between its classes
+	want := map[string]float64{
+		"Equities:World:VWRL": 0.3,
+		"Bonds:VWRL":          0.2,
+		"Other:CHF":           0.5,
+	}
+	for path, w := range want {
+		got := rep.weights.GetOrCreate(strings.Split(path, ":")).Value.Weights[date.Date(2020, 1, 1)]
+		if math.Abs(got-w) > 1e-9 {
+			t.Errorf("weight of %s = %v, want %v", path, got, w)
+		}
+	}
+}
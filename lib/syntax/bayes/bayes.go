@@ -15,7 +15,10 @@
 package bayes
 
 import (
+	"encoding/json"
+	"io"
 	"math"
+	"sort"
 	"strings"
 
 	"github.com/sboehler/knut/lib/common/dict"
@@ -30,6 +33,14 @@ type Model struct {
 	countByTokenAndAccount map[token]countByAccount
 
 	account string
+
+	// MinConfidence is the minimum probability of the best candidate
+	// account. Bookings below the threshold keep the original account.
+	MinConfidence float64
+
+	// Explain, if set, is called with the ranked candidates for every
+	// booking whose account is inferred.
+	Explain func(t *syntax.Transaction, b *syntax.Booking, cs []Candidate)
 }
 
 type token string
@@ -46,23 +57,39 @@ func NewModel(account string) *Model {
 
 // Update updates the model with the given transaction.
 func (m *Model) Update(t *syntax.Transaction) {
-	for i, b := range t.Bookings {
-		if b.Credit.Macro || b.Debit.Macro {
-			continue
-		}
-		credit := b.Credit.Extract()
-		debit := b.Debit.Extract()
-		if credit == "" || debit == "" {
-			continue
-		}
-		if credit == m.account || debit == m.account {
-			continue
-		}
-		m.update(t, &t.Bookings[i], credit, debit)
-		m.update(t, &t.Bookings[i], debit, credit)
+	for i := range t.Bookings {
+		m.UpdateBooking(t, &t.Bookings[i])
 	}
 }
 
+// UpdateBooking updates the model with a single booking of the given
+// transaction.
+func (m *Model) UpdateBooking(t *syntax.Transaction, b *syntax.Booking) {
+	if b.Credit.Macro || b.Debit.Macro {
+		return
+	}
+	credit := b.Credit.Extract()
+	debit := b.Debit.Extract()
+	if credit == "" || debit == "" {
+		return
+	}
+	if credit == m.account || debit == m.account {
+		return
+	}
+	m.update(t, b, credit, debit)
+	m.update(t, b, debit, credit)
+}
+
+// Accounts returns the accounts known to the model, sorted by name.
+func (m *Model) Accounts() []string {
+	res := make([]string, 0, len(m.countByAccount))
+	for a := range m.countByAccount {
+		res = append(res, a)
+	}
+	sort.Strings(res)
+	return res
+}
+
 func (m *Model) update(t *syntax.Transaction, b *syntax.Booking, account, other string) {
 	m.count++
 	m.countByAccount[account]++
@@ -84,46 +111,141 @@ func (m *Model) Infer(t *syntax.Transaction) {
 		credit := t.Bookings[i].Credit.Extract()
 		debit := t.Bookings[i].Debit.Extract()
 		if credit == m.account {
-			t.Bookings[i].Credit = m.inferAccount(t, &t.Bookings[i], debit)
+			m.inferAccount(t, &t.Bookings[i], &t.Bookings[i].Credit, debit)
 		}
 		if debit == m.account {
-			t.Bookings[i].Debit = m.inferAccount(t, &t.Bookings[i], credit)
+			m.inferAccount(t, &t.Bookings[i], &t.Bookings[i].Debit, credit)
 		}
 	}
 }
 
-func (m *Model) inferAccount(t *syntax.Transaction, b *syntax.Booking, other string) syntax.Account {
+func (m *Model) inferAccount(t *syntax.Transaction, b *syntax.Booking, account *syntax.Account, other string) {
+	cs := m.Candidates(t, b, other)
+	if m.Explain != nil {
+		m.Explain(t, b, cs)
+	}
+	if len(cs) == 0 || cs[0].Probability < m.MinConfidence {
+		return
+	}
+	*account = syntax.Account{
+		Range: syntax.Range{Start: 0, End: len(cs[0].Account), Text: cs[0].Account},
+	}
+}
+
+// Candidate is a candidate account for a booking.
+type Candidate struct {
+	Account string
+	// Score is the logarithm of the unnormalized probability.
+	Score float64
+	// Probability is the probability of the account, relative to all
+	// other candidates.
+	Probability float64
+	// Tokens are the tokens of the booking, ordered by their influence on
+	// the score, most influential first.
+	Tokens []Token
+}
+
+// Token is a token with its contribution to the score of a candidate,
+// relative to an unknown token.
+type Token struct {
+	Token string
+	Score float64
+}
+
+// Candidates returns the candidate accounts for the given booking, best
+// candidate first. other is the known account of the booking.
+func (m *Model) Candidates(t *syntax.Transaction, b *syntax.Booking, other string) []Candidate {
 	var (
 		tokens = tokenize(t, b, other)
+		res    []Candidate
 		max    = math.Inf(-1)
-		best   string
 	)
 	for candidate := range m.countByAccount {
 		if candidate == other {
 			continue // the other account of this booking is not a valid candidate
 		}
-		score := m.scoreCandidate(candidate, tokens)
-		if score > max {
-			best = candidate
-			max = score
+		c := m.scoreCandidate(candidate, tokens)
+		if c.Score > max {
+			max = c.Score
 		}
+		res = append(res, c)
+	}
+	var total float64
+	for i := range res {
+		res[i].Probability = math.Exp(res[i].Score - max)
+		total += res[i].Probability
 	}
-	return syntax.Account{
-		Range: syntax.Range{Start: 0, End: len(best), Text: best},
+	for i := range res {
+		res[i].Probability /= total
 	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return res[i].Account < res[j].Account
+	})
+	return res
 }
 
-func (m *Model) scoreCandidate(candidate string, tokens set.Set[token]) float64 {
+func (m *Model) scoreCandidate(candidate string, tokens set.Set[token]) Candidate {
 	count := float64(m.countByAccount[candidate])
-	score := math.Log(count / float64(m.count))
+	res := Candidate{
+		Account: candidate,
+		Score:   math.Log(count / float64(m.count)),
+	}
+	unknown := math.Log(1.0 / float64(m.count))
 	for token := range tokens {
+		score := unknown
 		if countForToken, ok := m.countByTokenAndAccount[token][candidate]; ok {
-			score += math.Log(float64(countForToken) / count)
-		} else {
-			score += math.Log(1.0 / float64(m.count))
+			score = math.Log(float64(countForToken) / count)
 		}
+		res.Score += score
+		res.Tokens = append(res.Tokens, Token{Token: string(token), Score: score - unknown})
+	}
+	sort.Slice(res.Tokens, func(i, j int) bool {
+		if res.Tokens[i].Score != res.Tokens[j].Score {
+			return res.Tokens[i].Score > res.Tokens[j].Score
+		}
+		return res.Tokens[i].Token < res.Tokens[j].Token
+	})
+	return res
+}
+
+type model struct {
+	Count                  int                       `json:"count"`
+	CountByAccount         map[string]int            `json:"accounts"`
+	CountByTokenAndAccount map[string]map[string]int `json:"tokens"`
+}
+
+// Save writes the trained model to w.
+func (m *Model) Save(w io.Writer) error {
+	res := model{
+		Count:                  m.count,
+		CountByAccount:         m.countByAccount,
+		CountByTokenAndAccount: make(map[string]map[string]int, len(m.countByTokenAndAccount)),
+	}
+	for t, c := range m.countByTokenAndAccount {
+		res.CountByTokenAndAccount[string(t)] = c
+	}
+	return json.NewEncoder(w).Encode(res)
+}
+
+// Load reads a model written by Save. The given account is the account
+// to be replaced by Infer.
+func Load(r io.Reader, account string) (*Model, error) {
+	var mdl model
+	if err := json.NewDecoder(r).Decode(&mdl); err != nil {
+		return nil, err
+	}
+	m := NewModel(account)
+	m.count = mdl.Count
+	for a, c := range mdl.CountByAccount {
+		m.countByAccount[a] = c
+	}
+	for t, c := range mdl.CountByTokenAndAccount {
+		m.countByTokenAndAccount[token(t)] = c
 	}
-	return score
+	return m, nil
 }
 
 func tokenize(t *syntax.Transaction, b *syntax.Booking, other string) set.Set[token] {
@@ -2,6 +2,7 @@ package bayes
 
 import (
 	"bytes"
+	"math"
 	"strings"
 	"testing"
 
@@ -99,3 +100,70 @@ func parse(t *testing.T, s string) syntax.File {
 	}
 	return f
 }
+
+func TestCandidates(t *testing.T) {
+	training := parse(t, lines(
+		`2022-03-03 "Migros Zurich"`,
+		`A Groceries 40 CHF`,
+		``,
+		`2022-03-04 "Migros Basel"`,
+		`A Groceries 60 CHF`,
+		``,
+		`2022-03-05 "SBB Zurich"`,
+		`A Transport 20 CHF`,
+		``,
+	))
+	target := parse(t, lines(
+		`2022-03-06 "Migros Bern"`,
+		`A TBD 50 CHF`,
+	))
+	model := NewModel("TBD")
+	for _, d := range training.Directives {
+		if t, ok := d.Directive.(syntax.Transaction); ok {
+			model.Update(&t)
+		}
+	}
+	trx := target.Directives[0].Directive.(syntax.Transaction)
+
+	got := model.Candidates(&trx, &trx.Bookings[0], "A")
+
+	var (
+		accounts []string
+		total    float64
+	)
+	for _, c := range got {
+		accounts = append(accounts, c.Account)
+		total += c.Probability
+	}
+	if diff := cmp.Diff([]string{"Groceries", "Transport"}, accounts); diff != "" {
+		t.Fatalf("Candidates() returned unexpected diff (-want/+got):\n%s\n", diff)
+	}
+	if math.Abs(total-1) > 1e-9 {
+		t.Fatalf("Candidates() returned probabilities summing to %f, want 1", total)
+	}
+	for _, c := range got {
+		for _, tk := range c.Tokens {
+			if tk.Token == "migros" && (tk.Score > 0) != (c.Account == "Groceries") {
+				t.Fatalf("Candidates() returned score %f for token %q and account %s", tk.Score, tk.Token, c.Account)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := model.Save(&buf); err != nil {
+		t.Fatalf("Save() returned unexpected error: %v", err)
+	}
+	loaded, err := Load(&buf, "TBD")
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(got, loaded.Candidates(&trx, &trx.Bookings[0], "A")); diff != "" {
+		t.Fatalf("Candidates() of the loaded model returned unexpected diff (-want/+got):\n%s\n", diff)
+	}
+
+	model.MinConfidence = 0.999
+	model.Infer(&trx)
+	if got := trx.Bookings[0].Debit.Extract(); got != "TBD" {
+		t.Fatalf("Infer() assigned %q below the minimum confidence", got)
+	}
+}
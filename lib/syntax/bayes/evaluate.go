@@ -0,0 +1,251 @@
+package bayes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sboehler/knut/lib/common/table"
+	"github.com/sboehler/knut/lib/syntax"
+)
+
+// Prediction is the predicted account of a booking whose account is known.
+type Prediction struct {
+	Transaction *syntax.Transaction
+	Booking     *syntax.Booking
+
+	// Known is the account which is given, Actual the account which is
+	// predicted.
+	Known, Actual string
+
+	// Predicted is the best candidate account, or empty if there is no
+	// candidate above the minimum confidence.
+	Predicted   string
+	Probability float64
+
+	// ActualProbability is the probability of the actual account.
+	ActualProbability float64
+}
+
+// Correct returns whether the prediction is correct.
+func (p Prediction) Correct() bool {
+	return p.Predicted == p.Actual
+}
+
+// Evaluate predicts the accounts of the bookings of the given transactions,
+// which must not have been used to train the model. As in imported
+// statements, the known account of a booking is always an asset or a
+// liability account.
+func (m *Model) Evaluate(ts []*syntax.Transaction) []Prediction {
+	var res []Prediction
+	for _, t := range ts {
+		for i := range t.Bookings {
+			b := &t.Bookings[i]
+			if b.Credit.Macro || b.Debit.Macro {
+				continue
+			}
+			credit, debit := b.Credit.Extract(), b.Debit.Extract()
+			if credit == m.account || debit == m.account {
+				continue
+			}
+			if isAL(credit) {
+				res = append(res, m.predict(t, b, credit, debit))
+			}
+			if isAL(debit) {
+				res = append(res, m.predict(t, b, debit, credit))
+			}
+		}
+	}
+	return res
+}
+
+func (m *Model) predict(t *syntax.Transaction, b *syntax.Booking, known, actual string) Prediction {
+	p := Prediction{
+		Transaction: t,
+		Booking:     b,
+		Known:       known,
+		Actual:      actual,
+	}
+	cs := m.Candidates(t, b, known)
+	if len(cs) > 0 && cs[0].Probability >= m.MinConfidence {
+		p.Predicted, p.Probability = cs[0].Account, cs[0].Probability
+	}
+	for _, c := range cs {
+		if c.Account == actual {
+			p.ActualProbability = c.Probability
+		}
+	}
+	return p
+}
+
+func isAL(account string) bool {
+	return strings.HasPrefix(account, "Assets:") || strings.HasPrefix(account, "Liabilities:")
+}
+
+// Evaluation summarizes predictions.
+type Evaluation struct {
+	Predictions []Prediction
+}
+
+// Summary renders the accuracy per account and in total. Recall is the
+// fraction of the bookings of an account which were predicted correctly,
+// precision the fraction of the predictions of an account which were
+// correct.
+func (e Evaluation) Summary() *table.Table {
+	type summary struct {
+		count, correct, predicted int
+		confusions                map[string]int
+	}
+	var (
+		byAccount = make(map[string]*summary)
+		correct   int
+	)
+	get := func(a string) *summary {
+		s, ok := byAccount[a]
+		if !ok {
+			s = &summary{confusions: make(map[string]int)}
+			byAccount[a] = s
+		}
+		return s
+	}
+	for _, p := range e.Predictions {
+		s := get(p.Actual)
+		s.count++
+		if p.Predicted != "" {
+			get(p.Predicted).predicted++
+		}
+		if p.Correct() {
+			s.correct++
+			correct++
+		} else {
+			s.confusions[orUnassigned(p.Predicted)]++
+		}
+	}
+	accounts := make([]string, 0, len(byAccount))
+	for a, s := range byAccount {
+		if s.count > 0 {
+			accounts = append(accounts, a)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if byAccount[accounts[i]].count != byAccount[accounts[j]].count {
+			return byAccount[accounts[i]].count > byAccount[accounts[j]].count
+		}
+		return accounts[i] < accounts[j]
+	})
+
+	tbl := table.New(1, 1, 1, 1, 1, 1)
+	tbl.AddSeparatorRow()
+	tbl.AddRow().
+		AddText("Account", table.Center).
+		AddText("Count", table.Center).
+		AddText("Correct", table.Center).
+		AddText("Recall", table.Center).
+		AddText("Precision", table.Center).
+		AddText("Confused with", table.Center)
+	tbl.AddSeparatorRow()
+	for _, a := range accounts {
+		s := byAccount[a]
+		tbl.AddRow().
+			AddText(a, table.Left).
+			AddText(fmt.Sprint(s.count), table.Right).
+			AddText(fmt.Sprint(s.correct), table.Right).
+			AddText(percent(s.correct, s.count), table.Right).
+			AddText(percent(s.correct, s.predicted), table.Right).
+			AddText(confusions(s.confusions, 3), table.Left)
+	}
+	tbl.AddSeparatorRow()
+	tbl.AddRow().
+		AddText("Total", table.Left).
+		AddText(fmt.Sprint(len(e.Predictions)), table.Right).
+		AddText(fmt.Sprint(correct), table.Right).
+		AddText(percent(correct, len(e.Predictions)), table.Right).
+		AddEmpty().
+		AddEmpty()
+	tbl.AddSeparatorRow()
+	return tbl
+}
+
+// Misclassifications renders the given number of worst misclassifications,
+// i.e. the wrong predictions with the highest confidence.
+func (e Evaluation) Misclassifications(n int) *table.Table {
+	var wrong []Prediction
+	for _, p := range e.Predictions {
+		if !p.Correct() {
+			wrong = append(wrong, p)
+		}
+	}
+	sort.SliceStable(wrong, func(i, j int) bool {
+		return wrong[i].Probability > wrong[j].Probability
+	})
+	tbl := table.New(1, 1, 1, 1, 1, 1)
+	tbl.AddSeparatorRow()
+	tbl.AddRow().
+		AddText("Transaction", table.Center).
+		AddText("Amount", table.Center).
+		AddEmpty().
+		AddText("Account", table.Center).
+		AddText("Predicted", table.Center).
+		AddText("Actual", table.Center)
+	tbl.AddSeparatorRow()
+	for i, p := range wrong {
+		if i == n {
+			break
+		}
+		tbl.AddRow().
+			AddText(fmt.Sprintf("%s \"%s\"", p.Transaction.Date.Extract(), p.Transaction.Description.Content.Extract()), table.Left).
+			AddText(p.Booking.Quantity.Extract(), table.Right).
+			AddText(p.Booking.Commodity.Extract(), table.Left).
+			AddText(p.Known, table.Left).
+			AddText(withProbability(orUnassigned(p.Predicted), p.Probability), table.Left).
+			AddText(withProbability(p.Actual, p.ActualProbability), table.Left)
+	}
+	tbl.AddSeparatorRow()
+	return tbl
+}
+
+func orUnassigned(account string) string {
+	if account == "" {
+		return "(unassigned)"
+	}
+	return account
+}
+
+func withProbability(account string, p float64) string {
+	if p == 0 {
+		return account
+	}
+	return fmt.Sprintf("%s %s", account, percentOf(p))
+}
+
+func confusions(cs map[string]int, n int) string {
+	accounts := make([]string, 0, len(cs))
+	for a := range cs {
+		accounts = append(accounts, a)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if cs[accounts[i]] != cs[accounts[j]] {
+			return cs[accounts[i]] > cs[accounts[j]]
+		}
+		return accounts[i] < accounts[j]
+	})
+	var res []string
+	for i, a := range accounts {
+		if i == n {
+			break
+		}
+		res = append(res, fmt.Sprintf("%s (%d)", a, cs[a]))
+	}
+	return strings.Join(res, ", ")
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return ""
+	}
+	return percentOf(float64(n) / float64(total))
+}
+
+func percentOf(f float64) string {
+	return fmt.Sprintf("%.1f%%", 100*f)
+}
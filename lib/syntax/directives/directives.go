@@ -125,6 +125,19 @@ type Price struct {
 	Price             Decimal
 }
 
+type Split struct {
+	Range
+	Date                   Date
+	Commodity              Commodity
+	Numerator, Denominator Decimal
+}
+
+type Rename struct {
+	Range
+	Date              Date
+	Commodity, Target Commodity
+}
+
 type Include struct {
 	Range
 	IncludePath QuotedString
@@ -93,7 +93,7 @@ func (p *Parser) parseDirective() (directives.Directive, error) {
 				return directives.SetRange(&dir, s.Range()), s.Annotate(err)
 			}
 		} else {
-			r, err := p.ReadAlternative([]string{"open", "close", "balance", "price"})
+			r, err := p.ReadAlternative([]string{"open", "close", "balance", "price", "split", "rename"})
 			if err != nil {
 				return directives.SetRange(&dir, s.Range()), s.Annotate(err)
 			}
@@ -117,6 +117,14 @@ func (p *Parser) parseDirective() (directives.Directive, error) {
 				if dir.Directive, err = p.parsePrice(s, date); err != nil {
 					return directives.SetRange(&dir, s.Range()), s.Annotate(err)
 				}
+			case "split":
+				if dir.Directive, err = p.parseSplit(s, date); err != nil {
+					return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+				}
+			case "rename":
+				if dir.Directive, err = p.parseRename(s, date); err != nil {
+					return directives.SetRange(&dir, s.Range()), s.Annotate(err)
+				}
 			}
 		}
 	}
@@ -246,6 +254,48 @@ func (p *Parser) parsePrice(s scanner.Scope, date directives.Date) (directives.P
 	return directives.SetRange(&price, s.Range()), err
 }
 
+func (p *Parser) parseSplit(s scanner.Scope, date directives.Date) (directives.Split, error) {
+	s.UpdateDesc("parsing `split` directive")
+	var (
+		split = directives.Split{Date: date}
+		err   error
+	)
+	if split.Commodity, err = p.parseCommodity(); err != nil {
+		return directives.SetRange(&split, s.Range()), s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return directives.SetRange(&split, s.Range()), s.Annotate(err)
+	}
+	if split.Numerator, err = p.parseDecimal(); err != nil {
+		return directives.SetRange(&split, s.Range()), s.Annotate(err)
+	}
+	if _, err := p.ReadCharacter(':'); err != nil {
+		return directives.SetRange(&split, s.Range()), s.Annotate(err)
+	}
+	if split.Denominator, err = p.parseDecimal(); err != nil {
+		return directives.SetRange(&split, s.Range()), s.Annotate(err)
+	}
+	return directives.SetRange(&split, s.Range()), nil
+}
+
+func (p *Parser) parseRename(s scanner.Scope, date directives.Date) (directives.Rename, error) {
+	s.UpdateDesc("parsing `rename` directive")
+	var (
+		rename = directives.Rename{Date: date}
+		err    error
+	)
+	if rename.Commodity, err = p.parseCommodity(); err != nil {
+		return directives.SetRange(&rename, s.Range()), s.Annotate(err)
+	}
+	if _, err := p.readWhitespace1(); err != nil {
+		return directives.SetRange(&rename, s.Range()), s.Annotate(err)
+	}
+	if rename.Target, err = p.parseCommodity(); err != nil {
+		return directives.SetRange(&rename, s.Range()), s.Annotate(err)
+	}
+	return directives.SetRange(&rename, s.Range()), nil
+}
+
 func (p *Parser) parseCommodity() (directives.Commodity, error) {
 	var (
 		commodity directives.Commodity
@@ -1371,6 +1371,35 @@ func TestParseDirective(t *testing.T) {
 					}
 				},
 			},
+			{
+				text: "2020-08-31 split AAPL 4:1",
+				want: func(s string) directives.Directive {
+					return directives.Directive{
+						Range: Range{End: 25, Text: s},
+						Directive: directives.Split{
+							Range:       Range{End: 25, Text: s},
+							Date:        directives.Date{Range: directives.Range{End: 10, Text: s}},
+							Commodity:   directives.Commodity{Range: directives.Range{Start: 17, End: 21, Text: s}},
+							Numerator:   directives.Decimal{Range: directives.Range{Start: 22, End: 23, Text: s}},
+							Denominator: directives.Decimal{Range: directives.Range{Start: 24, End: 25, Text: s}},
+						},
+					}
+				},
+			},
+			{
+				text: "2022-06-09 rename FB META",
+				want: func(s string) directives.Directive {
+					return directives.Directive{
+						Range: Range{End: 25, Text: s},
+						Directive: directives.Rename{
+							Range:     Range{End: 25, Text: s},
+							Date:      directives.Date{Range: directives.Range{End: 10, Text: s}},
+							Commodity: directives.Commodity{Range: directives.Range{Start: 18, End: 20, Text: s}},
+							Target:    directives.Commodity{Range: Range{Start: 21, End: 25, Text: s}},
+						},
+					}
+				},
+			},
 		},
 		desc: "p.parseDirective()",
 		fn: func(p *Parser) (directives.Directive, error) {
@@ -61,6 +61,10 @@ func (p *Printer) printDirective(directive directives.Directive) error {
 		return p.printInclude(d)
 	case directives.Price:
 		return p.printPrice(d)
+	case directives.Split:
+		return p.printSplit(d)
+	case directives.Rename:
+		return p.printRename(d)
 	}
 	return fmt.Errorf("unknown directive: %v", directive)
 }
@@ -122,6 +126,16 @@ func (p *Printer) printPrice(pr directives.Price) error {
 	return err
 }
 
+func (p *Printer) printSplit(s directives.Split) error {
+	_, err := fmt.Fprintf(p, "%s split %s %s:%s", s.Date.Extract(), s.Commodity.Extract(), s.Numerator.Extract(), s.Denominator.Extract())
+	return err
+}
+
+func (p *Printer) printRename(r directives.Rename) error {
+	_, err := fmt.Fprintf(p, "%s rename %s %s", r.Date.Extract(), r.Commodity.Extract(), r.Target.Extract())
+	return err
+}
+
 func (p *Printer) printInclude(i directives.Include) error {
 	_, err := fmt.Fprintf(p, "include \"%s\"", i.IncludePath.Content.Extract())
 	return err
@@ -165,6 +165,17 @@ func TestPrintFile(t *testing.T) {
 				`2022-03-03 balance XYZ:ABC:3 -0.3 CHF`,
 			),
 		},
+		{
+			desc: "print corporate actions",
+			text: lines(
+				`2020-08-31  split   AAPL  4:1`,
+				`2022-06-09  rename   FB    META`,
+			),
+			want: lines(
+				`2020-08-31 split AAPL 4:1`,
+				`2022-06-09 rename FB META`,
+			),
+		},
 		{
 			desc: "print price",
 			text: lines(
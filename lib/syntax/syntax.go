@@ -51,6 +51,10 @@ type Balance = directives.Balance
 
 type Price = directives.Price
 
+type Split = directives.Split
+
+type Rename = directives.Rename
+
 type Include = directives.Include
 
 type Range = directives.Range
@@ -21,8 +21,16 @@ import (
 	"github.com/sboehler/knut/cmd"
 
 	// enable importers here
+	_ "github.com/sboehler/knut/cmd/importer/beancount"
+	_ "github.com/sboehler/knut/cmd/importer/camt"
 	_ "github.com/sboehler/knut/cmd/importer/cumulus"
+	_ "github.com/sboehler/knut/cmd/importer/generic"
+	_ "github.com/sboehler/knut/cmd/importer/ibflex"
 	_ "github.com/sboehler/knut/cmd/importer/interactivebrokers"
+	_ "github.com/sboehler/knut/cmd/importer/ledger"
+	_ "github.com/sboehler/knut/cmd/importer/mt940"
+	_ "github.com/sboehler/knut/cmd/importer/ofx"
+	_ "github.com/sboehler/knut/cmd/importer/qif"
 	_ "github.com/sboehler/knut/cmd/importer/postfinance"
 	_ "github.com/sboehler/knut/cmd/importer/revolut"
 	_ "github.com/sboehler/knut/cmd/importer/revolut2"